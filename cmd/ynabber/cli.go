@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// options are the command line flags, they override the environment
+type options struct {
+	config  string
+	dryRun  bool
+	readers string
+	writers string
+	verbose bool
+	force   bool
+}
+
+// parseFlags parses the command line arguments in args, usage and errors are
+// written to output. The config file defaults to YNABBER_CONFIG.
+func parseFlags(args []string, output io.Writer) (options, error) {
+	var o options
+	flags := flag.NewFlagSet("ynabber", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&o.config, "config", os.Getenv("YNABBER_CONFIG"), "YAML or JSON `file` with settings, same as YNABBER_CONFIG")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "build the YNAB transactions without sending them, same as YNAB_DRY_RUN")
+	flags.StringVar(&o.readers, "readers", "", "comma separated `list` of readers to run, overrides YNABBER_READERS")
+	flags.StringVar(&o.writers, "writers", "", "comma separated `list` of writers to run, overrides YNABBER_WRITERS")
+	flags.BoolVar(&o.verbose, "verbose", false, "print more log statements, same as YNABBER_DEBUG")
+	flags.BoolVar(&o.force, "force", false, "run regardless of YNABBER_MIN_INTERVAL")
+	if err := flags.Parse(args); err != nil {
+		return o, err
+	}
+	if flags.NArg() > 0 {
+		err := fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+		fmt.Fprintln(output, err)
+		flags.Usage()
+		return o, err
+	}
+	return o, nil
+}
+
+// apply overrides the settings in cfg that are set by flags
+func (o options) apply(cfg *ynabber.Config) {
+	if o.readers != "" {
+		cfg.Readers = list(o.readers)
+	}
+	if o.writers != "" {
+		cfg.Writers = list(o.writers)
+	}
+	cfg.YNAB.DryRun = cfg.YNAB.DryRun || o.dryRun
+	cfg.Debug = cfg.Debug || o.verbose
+	cfg.Force = cfg.Force || o.force
+}
+
+// list splits the comma separated s and drops empty entries
+func list(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// cli runs ynabber from the command line with args and returns the exit code,
+// 0 on success, 1 if the run failed and 2 if the flags are invalid. With
+// Config.Interval set ynabber runs repeatedly until SIGINT or SIGTERM, a
+// failing run is only logged and the exit code is 0 once stopped.
+func cli(ctx context.Context, args []string, output io.Writer) int {
+	o, err := parseFlags(args, output)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(o.config)
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		return 1
+	}
+	o.apply(&cfg)
+
+	if cfg.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", cfg.MetricsAddr)
+		if err != nil {
+			slog.Error("Failed to serve metrics", "error", err)
+			return 1
+		}
+		defer runMetrics.serve(listener).Close()
+		slog.Info("Serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+	}
+
+	// Cancel the running writers on interrupt so the run stops cleanly
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Interval <= 0 {
+		if _, err := handle(ctx, cfg); err != nil {
+			slog.Error("Run failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	// Don't keep retrying a config that can never work
+	if err := cfg.Validate(); err != nil {
+		slog.Error("Invalid config", "error", err)
+		return 1
+	}
+	slog.Info("Running on an interval", "interval", cfg.Interval)
+	loop(ctx, cfg.Interval, func(ctx context.Context) {
+		if _, err := handle(ctx, cfg); err != nil {
+			slog.Error("Run failed", "error", err)
+		}
+	})
+	slog.Info("Stopped")
+	return 0
+}
+
+// loop calls run right away and then every interval until ctx is done. A run
+// that takes longer than interval delays the next one instead of overlapping.
+func loop(ctx context.Context, interval time.Duration, run func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestParseFlags(t *testing.T) {
+	t.Setenv("YNABBER_CONFIG", "env.yaml")
+
+	o, err := parseFlags(nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.config != "env.yaml" {
+		t.Errorf("config = %q, want the YNABBER_CONFIG default", o.config)
+	}
+
+	o, err = parseFlags([]string{"-config", "flag.yaml", "-dry-run", "-readers", "csv", "-writers", "json, ynab", "-verbose"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := ynabber.Config{Readers: ynabber.List{"nordigen"}, Writers: ynabber.List{"ynab"}}
+	o.apply(&cfg)
+	if o.config != "flag.yaml" {
+		t.Errorf("config = %q, want flag.yaml", o.config)
+	}
+	if !reflect.DeepEqual(cfg.Readers, ynabber.List{"csv"}) || !reflect.DeepEqual(cfg.Writers, ynabber.List{"json", "ynab"}) {
+		t.Errorf("got readers %v and writers %v", cfg.Readers, cfg.Writers)
+	}
+	if !cfg.YNAB.DryRun || !cfg.Debug || cfg.Force {
+		t.Errorf("got dry run %t, debug %t and force %t", cfg.YNAB.DryRun, cfg.Debug, cfg.Force)
+	}
+}
+
+func TestCLIExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "help", args: []string{"-h"}, want: 0},
+		{name: "unknown flag", args: []string{"-nope"}, want: 2},
+		{name: "arguments", args: []string{"cica"}, want: 2},
+		{name: "missing config file", args: []string{"-config", "/nonexistent.yaml"}, want: 1},
+		{name: "invalid config", args: []string{"-config", "", "-readers", "csv", "-writers", "webhook"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cli(context.Background(), tt.args, io.Discard); got != tt.want {
+				t.Errorf("got exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	loop(ctx, time.Millisecond, func(context.Context) {
+		runs += 1
+		if runs == 3 {
+			cancel()
+		}
+	})
+	if runs != 3 {
+		t.Errorf("got %d runs, want 3", runs)
+	}
+}
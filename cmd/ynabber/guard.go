@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// lastRunStore returns a clean path to the file storing the last run time
+func lastRunStore(cfg ynabber.Config) string {
+	return path.Clean(fmt.Sprintf("%s/last_run", cfg.DataDir))
+}
+
+// lastRun returns the time of the last run or the zero time if ynabber has
+// not run before
+func lastRun(file string) (time.Time, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+}
+
+// recordRun stores t as the time of the last run
+func recordRun(file string, t time.Time) error {
+	return os.WriteFile(file, []byte(t.Format(time.RFC3339)), 0644)
+}
+
+// tooSoon reports whether a run at now is within the minimum interval of the
+// last successful run. The run is recorded by recordRun once it succeeds, so
+// a failing run can be retried right away.
+func tooSoon(cfg ynabber.Config, now time.Time) (bool, time.Duration, error) {
+	last, err := lastRun(lastRunStore(cfg))
+	if err != nil {
+		return false, 0, fmt.Errorf("reading last run: %w", err)
+	}
+
+	since := now.Sub(last)
+	if !cfg.Force && !last.IsZero() && since < cfg.MinInterval {
+		return true, since, nil
+	}
+	return false, since, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestTooSoon(t *testing.T) {
+	cfg := ynabber.Config{DataDir: t.TempDir(), MinInterval: time.Hour}
+	now := time.Date(2023, 2, 24, 12, 0, 0, 0, time.UTC)
+
+	// First run is never too soon
+	skip, _, err := tooSoon(cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skip {
+		t.Fatal("first run skipped")
+	}
+
+	// A run isn't recorded until it succeeds, so a failed run is retried
+	skip, _, err = tooSoon(cfg, now.Add(5*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skip {
+		t.Fatal("run after unrecorded run skipped")
+	}
+	if err := recordRun(lastRunStore(cfg), now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second run within the interval is skipped and not recorded
+	skip, since, err := tooSoon(cfg, now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skip || since != 10*time.Minute {
+		t.Fatalf("got skip = %v, since = %s, want skip after 10m", skip, since)
+	}
+
+	// Forced runs are never skipped
+	cfg.Force = true
+	skip, _, err = tooSoon(cfg, now.Add(20*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skip {
+		t.Fatal("forced run skipped")
+	}
+	cfg.Force = false
+	if err := recordRun(lastRunStore(cfg), now.Add(20*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The forced run is recorded as the last run once it succeeds
+	skip, since, err = tooSoon(cfg, now.Add(70*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skip || since != 50*time.Minute {
+		t.Fatalf("got skip = %v, since = %s, want skip after 50m", skip, since)
+	}
+
+	// Runs after the interval are allowed
+	skip, _, err = tooSoon(cfg, now.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skip {
+		t.Fatal("run after interval skipped")
+	}
+}
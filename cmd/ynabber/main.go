@@ -2,94 +2,312 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/carlmjohnson/versioninfo"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/martinohansen/ynabber"
-	"github.com/martinohansen/ynabber/reader/nordigen"
-	"github.com/martinohansen/ynabber/writer/json"
-	"github.com/martinohansen/ynabber/writer/ynab"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 )
 
 type MyEvent struct {
 	Name string `json:"name"`
+
+	// Force runs regardless of YNABBER_MIN_INTERVAL, useful for manual
+	// invocations
+	Force bool `json:"force"`
 }
 
 func HandleLambdaRequest(ctx context.Context, event *MyEvent) (*string, error) {
-	log.Println("Version:", versioninfo.Short())
+	cfg, err := loadConfig(os.Getenv("YNABBER_CONFIG"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Force = cfg.Force || event.Force
+	message, err := handle(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
 
-	// Read config from env
+// loadConfig reads the config from the file at path, if any, and the
+// environment. Environment variables override the file.
+func loadConfig(path string) (ynabber.Config, error) {
 	var cfg ynabber.Config
-	err := envconfig.Process("", &cfg)
+	if path != "" {
+		if err := ynabber.LoadConfigFile(path); err != nil {
+			return cfg, fmt.Errorf("failed to load config file: %w", err)
+		}
+	}
+	if err := envconfig.Process("", &cfg); err != nil {
+		return cfg, err
+	}
+	cfg.YNAB.Cleared = strings.ToLower(cfg.YNAB.Cleared)
+	return cfg, nil
+}
+
+// handle runs ynabber once with cfg and returns a message describing the
+// outcome
+func handle(ctx context.Context, cfg ynabber.Config) (string, error) {
+	// Check the config before anything reaches out to the network
+	if err := cfg.Validate(); err != nil {
+		return "", fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	logger, err := cfg.Logger(os.Stderr)
 	if err != nil {
-		log.Fatal(err.Error())
+		return "", err
 	}
+	slog.SetDefault(logger)
+	slog.Info("Starting ynabber", "version", versioninfo.Short())
 
-	// Check that some values are valid
-	cfg.YNAB.Cleared = strings.ToLower(cfg.YNAB.Cleared)
-	if cfg.YNAB.Cleared != "cleared" &&
-		cfg.YNAB.Cleared != "uncleared" &&
-		cfg.YNAB.Cleared != "reconciled" {
-		log.Fatal("YNAB_CLEARED must be one of cleared, uncleared or reconciled")
+	if err := validateStages(&cfg); err != nil {
+		return "", err
 	}
 
-	if cfg.Debug {
-		log.Printf("Config: %+v\n", cfg)
+	slog.Debug("Loaded config", "config", cfg)
+
+	if cfg.MinInterval > 0 {
+		skip, since, err := tooSoon(cfg, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if skip {
+			message := fmt.Sprintf("Run skipped, ran %.0f minutes ago", since.Minutes())
+			slog.Info(message)
+			return message, nil
+		}
+	}
+
+	// Without pipelines all readers are written to all writers
+	defaultPipeline := ynabber.PipelineConfig{
+		Readers:      cfg.Readers,
+		Transformers: enabledTransformers(&cfg),
+		Writers:      cfg.Writers,
 	}
 
 	ynabber := ynabber.Ynabber{}
-	for _, reader := range cfg.Readers {
-		switch reader {
-		case "nordigen":
-			ynabber.Readers = append(ynabber.Readers, nordigen.NewReader(&cfg))
-		default:
-			log.Fatalf("Unknown reader: %s", reader)
+	ynabber.Notifiers, err = newNotifiers(&cfg, cfg.Notifiers)
+	if err != nil {
+		return "", fmt.Errorf("invalid YNABBER_NOTIFIERS: %w", err)
+	}
+	if len(cfg.Pipelines) > 0 {
+		if err := validatePipelines(cfg.Pipelines); err != nil {
+			return "", fmt.Errorf("invalid YNABBER_PIPELINES: %w", err)
 		}
+		for i, p := range cfg.Pipelines {
+			pipeline, err := newPipeline(&cfg, ynabber.Notifiers, p)
+			if err != nil {
+				return "", fmt.Errorf("failed to create pipeline %d: %w", i, err)
+			}
+			ynabber.Pipelines = append(ynabber.Pipelines, pipeline)
+		}
+	} else {
+		pipeline, err := newPipeline(&cfg, ynabber.Notifiers, defaultPipeline)
+		if err != nil {
+			return "", err
+		}
+		ynabber.Readers = pipeline.Readers
+		ynabber.Transformers = pipeline.Transformers
+		ynabber.Writers = pipeline.Writers
 	}
-	for _, writer := range cfg.Writers {
-		switch writer {
-		case "ynab":
-			ynabber.Writers = append(ynabber.Writers, ynab.Writer{Config: &cfg})
-		case "json":
-			ynabber.Writers = append(ynabber.Writers, json.Writer{})
-		default:
-			log.Fatalf("Unknown writer: %s", writer)
+
+	if cfg.Tracing {
+		shutdown, err := setupTracing(ctx)
+		if err != nil {
+			return "", fmt.Errorf("tracing: %w", err)
 		}
+		defer func() {
+			if err := shutdown(context.Background()); err != nil {
+				slog.Warn("Failed to flush traces", "error", err)
+			}
+		}()
 	}
 
-	err = run(ynabber)
+	start := time.Now()
+	s, err := run(ctx, ynabber)
+	runMetrics.record(s, err, start, time.Now())
+	recordState(cfg, s, time.Now())
+	if cfg.NotifyOnRun {
+		if err := ynabber.Notifiers.Notify(s.Message(err)); err != nil {
+			slog.Warn("Failed to send run summary", "error", err)
+		}
+	}
 	if err != nil {
-		return nil, err
-	} else {
-		message := fmt.Sprintf("Run succeeded")
-		log.Printf("%s", message)
-		return &message, nil
+		return "", err
 	}
+	if cfg.MinInterval > 0 {
+		if err := recordRun(lastRunStore(cfg), start); err != nil {
+			slog.Warn("Failed to record run", "error", err)
+		}
+	}
+	message := "Run succeeded"
+	slog.Info(message)
+	return message, nil
 }
 
-func run(y ynabber.Ynabber) error {
+// run runs all pipelines of y and returns a summary of what happened
+func run(ctx context.Context, y ynabber.Ynabber) (s summary, err error) {
+	ctx, span := tracer.Start(ctx, "run")
+	defer func() { endSpan(span, 0, err) }()
+
+	pipelines := y.Pipelines
+	if len(pipelines) == 0 {
+		pipelines = []ynabber.Pipeline{{
+			Readers:      y.Readers,
+			Transformers: y.Transformers,
+			Writers:      y.Writers,
+		}}
+	}
+
+	// Retry what the writers failed to write in previous runs before reading
+	// new transactions. A failing flush doesn't stop the run since the
+	// writer keeps the transactions for the next one.
+	for _, p := range pipelines {
+		for _, writer := range p.Writers {
+			flusher, ok := writer.(ynabber.Flusher)
+			if !ok {
+				continue
+			}
+			flushCtx, span := startSpan(ctx, "flush", writer)
+			err := flusher.Flush(flushCtx)
+			endSpan(span, 0, err)
+			if err != nil {
+				slog.Error("Failed to flush", "writer", stageName(writer), "error", err)
+			}
+		}
+	}
+
+	// A failing pipeline doesn't stop the others
+	var errs []error
+	for i, p := range pipelines {
+		if err := runPipeline(ctx, p, &s); err != nil {
+			if len(pipelines) > 1 {
+				err = fmt.Errorf("pipeline %d: %w", i, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// runPipeline reads transactions from all readers of p, transforms them and
+// writes them to all writers of p. A failing reader or writer doesn't stop
+// the others, instead the errors are joined. The transactions a failing
+// reader did read, for example from the banks that didn't fail, are still
+// written. The outcome of each reader and writer is added to s.
+func runPipeline(ctx context.Context, p ynabber.Pipeline, s *summary) error {
 	var transactions []ynabber.Transaction
+	var errs []error
 
-	// Read transactions from all readers
-	for _, reader := range y.Readers {
-		t, err := reader.Bulk()
+	// Read transactions from all readers that are due
+	due, read := 0, 0
+	accounts := map[string]bool{}
+	var committers []ynabber.Reader
+	for _, reader := range p.Readers {
+		readCtx, span := startSpan(ctx, "reader", reader)
+		t, err := reader.Bulk(ynabber.WithAccountsRead(readCtx, accounts))
+		if errors.Is(err, errNotDue) {
+			endSpan(span, 0, nil)
+			s.Read = append(s.Read, stage{Name: stageName(reader), Skipped: true})
+			continue
+		}
+		endSpan(span, len(t), err)
+		s.Read = append(s.Read, stage{Name: stageName(reader), Transactions: len(t), Err: err})
 		if err != nil {
-			return fmt.Errorf("reading: %w", err)
+			slog.Error("Failed to read", "reader", stageName(reader), "error", err, "read", len(t))
+			errs = append(errs, fmt.Errorf("reading: %w", err))
+			if len(t) == 0 {
+				continue
+			}
 		}
+		if _, ok := reader.(ynabber.Committer); ok && err == nil {
+			committers = append(committers, reader)
+		}
+		due += 1
+		read += len(t)
 		transactions = append(transactions, t...)
 	}
+	if due == 0 && len(p.Readers) > 0 {
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		slog.Info("No readers are due, skipping writers")
+		return nil
+	}
+
+	// Readers with overlapping accounts return the same transactions
+	transactions, duplicates := ynabber.Dedup(transactions)
+	if duplicates > 0 {
+		slog.Info("Removed duplicate transactions read more than once", "count", duplicates)
+	}
 
-	// Write transactions to all writers
-	for _, writer := range y.Writers {
-		err := writer.Bulk(transactions)
+	// Keep the payee, memo and date as read for the writers, then
+	// transform transactions in the order the transformers are defined
+	for i, v := range transactions {
+		transactions[i].Original = &ynabber.Original{Payee: v.Payee, Memo: v.Memo, Date: v.Date}
+	}
+	for _, transformer := range p.Transformers {
+		transformCtx, span := startSpan(ctx, "transformer", transformer)
+		before := len(transactions)
+		if c, ok := transformer.(ynabber.ContextTransformer); ok {
+			transactions = c.TransformContext(transformCtx, transactions)
+		} else {
+			transactions = transformer.Transform(transactions)
+		}
+		endSpan(span, len(transactions), nil)
+		if removed := before - len(transactions); removed > 0 {
+			s.Removed = append(s.Removed, stage{Name: stageName(transformer), Transactions: removed})
+		}
+	}
+
+	s.Skipped += read - len(transactions)
+
+	// Write transactions to all writers, the ledger is last and only written
+	// if the others succeeded so it never has transactions they are missing
+	for _, writer := range p.Writers {
+		if _, ok := writer.(ledgerWriter); ok && len(errs) > 0 {
+			slog.Warn("Not writing to the ledger since other stages failed", "writer", stageName(writer))
+			s.Written = append(s.Written, stage{Name: stageName(writer), Skipped: true})
+			continue
+		}
+		writeCtx, span := startSpan(ctx, "writer", writer)
+		err := writer.Bulk(writeCtx, transactions)
+		endSpan(span, len(transactions), err)
+		s.Written = append(s.Written, stage{Name: stageName(writer), Transactions: len(transactions), Err: err})
 		if err != nil {
-			return fmt.Errorf("writing: %w", err)
+			slog.Error("Failed to write", "writer", stageName(writer), "error", err)
+			errs = append(errs, fmt.Errorf("writing: %w", err))
 		}
 	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Everything read is written, so the readers that read this run can
+	// record it as done. A failure only means it's read again next time.
+	for _, reader := range committers {
+		if err := reader.(ynabber.Committer).Commit(ctx); err != nil {
+			slog.Warn("Failed to commit", "reader", stageName(reader), "error", err)
+		}
+	}
+
+	if s.Synced == nil {
+		s.Synced = map[string]int{}
+	}
+	for iban := range accounts {
+		if _, ok := s.Synced[iban]; !ok {
+			s.Synced[iban] = 0
+		}
+	}
+	for _, t := range transactions {
+		s.Synced[t.Account.IBAN] += 1
+	}
 	return nil
 }
 
@@ -98,7 +316,6 @@ func main() {
 	if isLambda {
 		lambda.Start(HandleLambdaRequest)
 	} else {
-		event := &MyEvent{Name: "cica"}
-		HandleLambdaRequest(context.TODO(), event)
+		os.Exit(cli(context.Background(), os.Args[1:], os.Stderr))
 	}
 }
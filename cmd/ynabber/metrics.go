@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus metrics of the runs, served on
+// YNABBER_METRICS_ADDR
+type metrics struct {
+	registry *prometheus.Registry
+
+	read        *prometheus.CounterVec
+	sent        *prometheus.CounterVec
+	failed      *prometheus.CounterVec
+	skipped     prometheus.Counter
+	runs        *prometheus.CounterVec
+	duration    prometheus.Histogram
+	lastSuccess prometheus.Gauge
+}
+
+// runMetrics is recorded by every run, the metrics are only served if
+// YNABBER_METRICS_ADDR is set
+var runMetrics = newMetrics()
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		read: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ynabber_transactions_read_total",
+			Help: "Transactions read by reader.",
+		}, []string{"reader"}),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ynabber_transactions_sent_total",
+			Help: "Transactions written by writer.",
+		}, []string{"writer"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ynabber_transactions_failed_total",
+			Help: "Transactions a writer failed to write.",
+		}, []string{"writer"}),
+		skipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ynabber_transactions_skipped_total",
+			Help: "Transactions read but dropped before writing, for example duplicates or excluded by memo.",
+		}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ynabber_runs_total",
+			Help: "Runs by result, either success or failure.",
+		}, []string{"result"}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "ynabber_run_duration_seconds",
+			Help:    "Duration of the runs.",
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600},
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ynabber_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run.",
+		}),
+	}
+	m.registry.MustRegister(m.read, m.sent, m.failed, m.skipped, m.runs, m.duration, m.lastSuccess)
+	return m
+}
+
+// record adds the outcome of a run that started at start and ended at end
+func (m *metrics) record(s summary, err error, start, end time.Time) {
+	for _, r := range s.Read {
+		m.read.WithLabelValues(r.Name).Add(float64(r.Transactions))
+	}
+	for _, w := range s.Written {
+		if w.Err != nil {
+			m.failed.WithLabelValues(w.Name).Add(float64(w.Transactions))
+		} else {
+			m.sent.WithLabelValues(w.Name).Add(float64(w.Transactions))
+		}
+	}
+	m.skipped.Add(float64(s.Skipped))
+	m.duration.Observe(end.Sub(start).Seconds())
+	if err != nil {
+		m.runs.WithLabelValues("failure").Inc()
+		return
+	}
+	m.runs.WithLabelValues("success").Inc()
+	m.lastSuccess.Set(float64(end.Unix()))
+}
+
+// serve serves the metrics on /metrics of listener in the background
+func (m *metrics) serve(listener net.Listener) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server failed", "error", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsRecord(t *testing.T) {
+	m := newMetrics()
+	start := time.Unix(1700000000, 0)
+
+	m.record(summary{
+		Read:    []stage{{Name: "nordigen.Reader", Transactions: 5}},
+		Written: []stage{{Name: "ynab.Writer", Transactions: 4}, {Name: "json.Writer", Transactions: 4, Err: errors.New("disk full")}},
+		Skipped: 1,
+	}, errors.New("writing: disk full"), start, start.Add(2*time.Second))
+	m.record(summary{
+		Read:    []stage{{Name: "nordigen.Reader", Transactions: 2}},
+		Written: []stage{{Name: "ynab.Writer", Transactions: 2}},
+	}, nil, start.Add(time.Hour), start.Add(time.Hour+time.Second))
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "read", got: testutil.ToFloat64(m.read.WithLabelValues("nordigen.Reader")), want: 7},
+		{name: "sent", got: testutil.ToFloat64(m.sent.WithLabelValues("ynab.Writer")), want: 6},
+		{name: "failed", got: testutil.ToFloat64(m.failed.WithLabelValues("json.Writer")), want: 4},
+		{name: "skipped", got: testutil.ToFloat64(m.skipped), want: 1},
+		{name: "successes", got: testutil.ToFloat64(m.runs.WithLabelValues("success")), want: 1},
+		{name: "failures", got: testutil.ToFloat64(m.runs.WithLabelValues("failure")), want: 1},
+		{name: "last success", got: testutil.ToFloat64(m.lastSuccess), want: float64(start.Add(time.Hour + time.Second).Unix())},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestMetricsServe(t *testing.T) {
+	m := newMetrics()
+	m.skipped.Add(3)
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.serve(listener).Close()
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ynabber_transactions_skipped_total 3"; !strings.Contains(string(b), want) {
+		t.Errorf("metrics %q don't contain %q", b, want)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/notifier/email"
+	"github.com/martinohansen/ynabber/notifier/slack"
+	"github.com/martinohansen/ynabber/notifier/telegram"
+	"github.com/martinohansen/ynabber/reader/csv"
+	"github.com/martinohansen/ynabber/reader/nordigen"
+	"github.com/martinohansen/ynabber/reader/ofx"
+	"github.com/martinohansen/ynabber/reader/plaid"
+	"github.com/martinohansen/ynabber/transformer"
+	"github.com/martinohansen/ynabber/writer/actual"
+	csvwriter "github.com/martinohansen/ynabber/writer/csv"
+	"github.com/martinohansen/ynabber/writer/firefly"
+	"github.com/martinohansen/ynabber/writer/json"
+	"github.com/martinohansen/ynabber/writer/s3"
+	"github.com/martinohansen/ynabber/writer/sqlite"
+	"github.com/martinohansen/ynabber/writer/webhook"
+	"github.com/martinohansen/ynabber/writer/ynab"
+)
+
+// readers creates the readers by the name used in the config
+var readers = map[string]func(*ynabber.Config, ynabber.Notifier) (ynabber.Reader, error){
+	"nordigen": func(cfg *ynabber.Config, n ynabber.Notifier) (ynabber.Reader, error) {
+		return nordigen.NewReader(cfg, n), nil
+	},
+	"csv": func(cfg *ynabber.Config, n ynabber.Notifier) (ynabber.Reader, error) {
+		if cfg.CSV.InputDir == "" {
+			return nil, fmt.Errorf("CSV_INPUT_DIR is not set")
+		}
+		return csv.Reader{Config: cfg}, nil
+	},
+	"ofx": func(cfg *ynabber.Config, n ynabber.Notifier) (ynabber.Reader, error) {
+		if cfg.OFX.Dir == "" {
+			return nil, fmt.Errorf("OFX_DIR is not set")
+		}
+		return ofx.NewReader(cfg), nil
+	},
+	"plaid": func(cfg *ynabber.Config, n ynabber.Notifier) (ynabber.Reader, error) {
+		return plaid.Reader{Config: cfg}, nil
+	},
+}
+
+// transformers creates the transformers by the name used in the config
+var transformers = map[string]func(*ynabber.Config) (ynabber.Transformer, error){
+	"exclude_memo": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.NewMemoExclude(
+			cfg.Transform.ExcludeMemo,
+			cfg.Transform.ExcludeMemoWholeWord,
+		)
+	},
+	"card_numbers": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.CardNumber{}, nil
+	},
+	"payee_references": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.NewReference(
+			cfg.Transform.PayeeReferencePattern,
+			cfg.Transform.PayeeReferenceMemo,
+		)
+	},
+	"payee_processors": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.NewProcessor(
+			cfg.Transform.PayeeProcessors,
+			cfg.Transform.PayeeProcessorMap,
+		)
+	},
+	"payee_file": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		if cfg.Transform.PayeeFile == "" {
+			return nil, fmt.Errorf("YNABBER_PAYEE_FILE is not set")
+		}
+		return transformer.PayeeFile{
+			Path:   cfg.Transform.PayeeFile,
+			Prefix: cfg.Transform.PayeeFilePrefix,
+		}, nil
+	},
+	"coalesce": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		if cfg.Transform.CoalesceWindow <= 0 {
+			return nil, fmt.Errorf("YNABBER_COALESCE_WINDOW is not set")
+		}
+		return transformer.Coalesce{Window: cfg.Transform.CoalesceWindow, Now: time.Now}, nil
+	},
+	"memo_tags": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.MemoTag{Tags: cfg.Transform.MemoTags}, nil
+	},
+	"future_transactions": func(cfg *ynabber.Config) (ynabber.Transformer, error) {
+		return transformer.NewFuture(cfg.Transform.FutureTransactions)
+	},
+}
+
+// writers creates the writers by the name used in the config
+var writers = map[string]func(*ynabber.Config) (ynabber.Writer, error){
+	"ynab": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		return ynab.NewWriter(cfg)
+	},
+	"json": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		return json.Writer{Config: cfg}, nil
+	},
+	"csv": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		if cfg.CSV.Path == "" {
+			return nil, fmt.Errorf("CSV_PATH is not set")
+		}
+		return csvwriter.Writer{Config: cfg}, nil
+	},
+	"s3": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		return s3.NewWriter(cfg)
+	},
+	"actual": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		if cfg.Actual.URL == "" || cfg.Actual.BudgetID == "" {
+			return nil, fmt.Errorf("ACTUAL_URL and ACTUAL_BUDGETID must be set")
+		}
+		return actual.Writer{Config: cfg}, nil
+	},
+	"firefly": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		if cfg.Firefly.URL == "" || cfg.Firefly.Token == "" {
+			return nil, fmt.Errorf("FIREFLY_URL and FIREFLY_TOKEN must be set")
+		}
+		return firefly.Writer{Config: cfg}, nil
+	},
+	"webhook": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		if cfg.Webhook.URL == "" {
+			return nil, fmt.Errorf("WEBHOOK_URL is not set")
+		}
+		return webhook.Writer{Config: cfg}, nil
+	},
+	"sqlite": func(cfg *ynabber.Config) (ynabber.Writer, error) {
+		if cfg.SQLite.Path == "" {
+			return nil, fmt.Errorf("SQLITE_PATH is not set")
+		}
+		return sqlite.Writer{Config: cfg}, nil
+	},
+}
+
+// notifiers creates the notifiers by the name used in the config
+var notifiers = map[string]func(*ynabber.Config) (ynabber.Notifier, error){
+	"email": func(cfg *ynabber.Config) (ynabber.Notifier, error) {
+		return email.NewNotifier(cfg)
+	},
+	"slack": func(cfg *ynabber.Config) (ynabber.Notifier, error) {
+		return slack.NewNotifier(cfg)
+	},
+	"telegram": func(cfg *ynabber.Config) (ynabber.Notifier, error) {
+		// A missing token only fails when notifying, which is logged since
+		// the message is in the log as well
+		return telegram.Notifier{Config: cfg}, nil
+	},
+}
+
+// newNotifiers creates the notifiers named by names
+func newNotifiers(cfg *ynabber.Config, names []string) (ynabber.Notifiers, error) {
+	var n ynabber.Notifiers
+	for _, name := range names {
+		create, ok := notifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown notifier: %s", name)
+		}
+		notifier, err := create(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", name, err)
+		}
+		n = append(n, notifier)
+	}
+	return n, nil
+}
+
+// transformingWriter applies its own transformers to a copy of the
+// transactions before writing them with Writer
+type transformingWriter struct {
+	ynabber.Writer
+	Transformers []ynabber.Transformer
+}
+
+// Bulk transforms a copy of t and writes it, t is left untouched for the
+// other writers
+func (w transformingWriter) Bulk(ctx context.Context, t []ynabber.Transaction) error {
+	c := make([]ynabber.Transaction, len(t))
+	copy(c, t)
+	for _, transformer := range w.Transformers {
+		c = transformer.Transform(c)
+	}
+	return w.Writer.Bulk(ctx, c)
+}
+
+// Accepts reports whether Writer writes t after the transformers, t is
+// accepted if the transformers drop it or Writer writes everything
+func (w transformingWriter) Accepts(t ynabber.Transaction) bool {
+	accepter, ok := w.Writer.(ynabber.Accepter)
+	if !ok {
+		return true
+	}
+	c := []ynabber.Transaction{t}
+	for _, transformer := range w.Transformers {
+		c = transformer.Transform(c)
+	}
+	for _, v := range c {
+		if !accepter.Accepts(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush flushes Writer if it keeps transactions
+func (w transformingWriter) Flush(ctx context.Context) error {
+	if flusher, ok := w.Writer.(ynabber.Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// enabledTransformers returns the names of the transformers enabled by the
+// settings in cfg in the order they run
+func enabledTransformers(cfg *ynabber.Config) []string {
+	var names []string
+	if len(cfg.Transform.ExcludeMemo) > 0 {
+		names = append(names, "exclude_memo")
+	}
+	if cfg.Transform.StripCardNumbers {
+		names = append(names, "card_numbers")
+	}
+	if cfg.Transform.StripPayeeReferences {
+		names = append(names, "payee_references")
+	}
+	if cfg.Transform.PayeeProcessors || len(cfg.Transform.PayeeProcessorMap) > 0 {
+		names = append(names, "payee_processors")
+	}
+	if cfg.Transform.PayeeFile != "" {
+		names = append(names, "payee_file")
+	}
+	if cfg.Transform.CoalesceWindow > 0 {
+		names = append(names, "coalesce")
+	}
+	if len(cfg.Transform.MemoTags) > 0 {
+		names = append(names, "memo_tags")
+	}
+	if cfg.Transform.FutureTransactions != transformer.FutureKeep {
+		names = append(names, "future_transactions")
+	}
+	return names
+}
+
+// validatePipelines checks that the pipelines only use known stages, that
+// each has at least one reader and writer and that no reader is shared
+func validatePipelines(pipelines []ynabber.PipelineConfig) error {
+	seen := map[string]int{}
+	for i, p := range pipelines {
+		if len(p.Readers) == 0 {
+			return fmt.Errorf("pipeline %d: no readers", i)
+		}
+		if len(p.Writers) == 0 {
+			return fmt.Errorf("pipeline %d: no writers", i)
+		}
+		for _, name := range p.Readers {
+			if _, ok := readers[name]; !ok {
+				return fmt.Errorf("pipeline %d: unknown reader: %s", i, name)
+			}
+			if j, ok := seen[name]; ok {
+				return fmt.Errorf("pipeline %d: reader %s is already used by pipeline %d", i, name, j)
+			}
+			seen[name] = i
+		}
+		for _, name := range p.Transformers {
+			if _, ok := transformers[name]; !ok {
+				return fmt.Errorf("pipeline %d: unknown transformer: %s", i, name)
+			}
+		}
+		for _, name := range p.Writers {
+			if _, ok := writers[name]; !ok {
+				return fmt.Errorf("pipeline %d: unknown writer: %s", i, name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateStages checks that there is at least one reader and writer to run
+// when no pipelines are defined, a run without would silently do nothing
+func validateStages(cfg *ynabber.Config) error {
+	if len(cfg.Pipelines) > 0 {
+		return nil
+	}
+	if len(cfg.Readers) == 0 {
+		return fmt.Errorf("YNABBER_READERS is empty, valid options are: %s", strings.Join(names(readers), ", "))
+	}
+	if len(cfg.Writers) == 0 {
+		return fmt.Errorf("YNABBER_WRITERS is empty, valid options are: %s", strings.Join(names(writers), ", "))
+	}
+	return nil
+}
+
+// names returns the sorted names of registry
+func names[T any](registry map[string]T) []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newPipeline creates the stages named by p
+func newPipeline(cfg *ynabber.Config, n ynabber.Notifier, p ynabber.PipelineConfig) (ynabber.Pipeline, error) {
+	var pipeline ynabber.Pipeline
+	for _, name := range p.Readers {
+		create, ok := readers[name]
+		if !ok {
+			return pipeline, fmt.Errorf("unknown reader: %s", name)
+		}
+		r, err := create(cfg, n)
+		if err != nil {
+			return pipeline, fmt.Errorf("reader %s: %w", name, err)
+		}
+		pipeline.Readers = append(pipeline.Readers, newScheduledReader(cfg, name, r))
+	}
+	for _, name := range p.Transformers {
+		create, ok := transformers[name]
+		if !ok {
+			return pipeline, fmt.Errorf("unknown transformer: %s", name)
+		}
+		t, err := create(cfg)
+		if err != nil {
+			return pipeline, fmt.Errorf("transformer %s: %w", name, err)
+		}
+		pipeline.Transformers = append(pipeline.Transformers, t)
+	}
+	for _, name := range p.Writers {
+		create, ok := writers[name]
+		if !ok {
+			return pipeline, fmt.Errorf("unknown writer: %s", name)
+		}
+		w, err := create(cfg)
+		if err != nil {
+			return pipeline, fmt.Errorf("writer %s: %w", name, err)
+		}
+		if names := cfg.WriterTransformers[name]; len(names) > 0 {
+			tw := transformingWriter{Writer: w}
+			for _, transformerName := range names {
+				create, ok := transformers[transformerName]
+				if !ok {
+					return pipeline, fmt.Errorf("writer %s: unknown transformer: %s", name, transformerName)
+				}
+				t, err := create(cfg)
+				if err != nil {
+					return pipeline, fmt.Errorf("writer %s: transformer %s: %w", name, transformerName, err)
+				}
+				tw.Transformers = append(tw.Transformers, t)
+			}
+			w = tw
+		}
+		pipeline.Writers = append(pipeline.Writers, w)
+	}
+	if cfg.SQLite.Dedup {
+		useLedger(cfg, &pipeline, p.Writers)
+	}
+	return pipeline, nil
+}
+
+// ledgerWriter is the sqlite writer when it's used as the ledger of what has
+// been written, it's only written if all other writers succeeded
+type ledgerWriter struct {
+	ynabber.Writer
+	Config *ynabber.Config
+
+	// Others are the other writers of the pipeline, only the transactions
+	// they all accept are recorded
+	Others []ynabber.Writer
+}
+
+// Bulk records the transactions of t accepted by all other writers. Nothing
+// is recorded on dry runs since nothing is sent to YNAB, otherwise the next
+// run would skip the transactions.
+func (w ledgerWriter) Bulk(ctx context.Context, t []ynabber.Transaction) error {
+	if w.Config.YNAB.DryRun {
+		slog.Info("Dry run: not writing the ledger", "writer", stageName(w.Writer))
+		return nil
+	}
+	accepted := make([]ynabber.Transaction, 0, len(t))
+	for _, v := range t {
+		if w.accepted(v) {
+			accepted = append(accepted, v)
+		}
+	}
+	if skipped := len(t) - len(accepted); skipped > 0 {
+		slog.Info("Not recording transactions the other writers skipped in the ledger", "count", skipped)
+	}
+	return w.Writer.Bulk(ctx, accepted)
+}
+
+// accepted reports whether all other writers accept t
+func (w ledgerWriter) accepted(t ynabber.Transaction) bool {
+	for _, writer := range w.Others {
+		if accepter, ok := writer.(ynabber.Accepter); ok && !accepter.Accepts(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// useLedger makes the sqlite writer of pipeline, named in names, the ledger.
+// Transactions already in it are skipped after all other transformers and
+// it's moved to write last. Pipelines without it are left as is.
+func useLedger(cfg *ynabber.Config, pipeline *ynabber.Pipeline, names []string) {
+	for i, name := range names {
+		if name != "sqlite" {
+			continue
+		}
+		ledger := pipeline.Writers[i]
+		others := append(pipeline.Writers[:i:i], pipeline.Writers[i+1:]...)
+		pipeline.Writers = append(others, ledgerWriter{Writer: ledger, Config: cfg, Others: others})
+		pipeline.Transformers = append(pipeline.Transformers, sqlite.Ledger{Config: cfg})
+		return
+	}
+}
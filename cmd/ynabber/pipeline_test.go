@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/sqlite"
+)
+
+type recordingWriter struct{ t *[]ynabber.Transaction }
+
+func (w recordingWriter) Bulk(_ context.Context, t []ynabber.Transaction) error {
+	*w.t = append(*w.t, t...)
+	return nil
+}
+
+type payeeTransformer struct{ payee ynabber.Payee }
+
+func (p payeeTransformer) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	for i := range t {
+		t[i].Payee = p.payee
+	}
+	return t
+}
+
+func TestValidatePipelines(t *testing.T) {
+	tests := []struct {
+		name      string
+		pipelines []ynabber.PipelineConfig
+		wantErr   bool
+	}{
+		{
+			name: "valid",
+			pipelines: []ynabber.PipelineConfig{
+				{Readers: []string{"nordigen"}, Transformers: []string{"card_numbers"}, Writers: []string{"ynab", "json"}},
+			},
+		},
+		{
+			name:      "no readers",
+			pipelines: []ynabber.PipelineConfig{{Writers: []string{"ynab"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "no writers",
+			pipelines: []ynabber.PipelineConfig{{Readers: []string{"nordigen"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown transformer",
+			pipelines: []ynabber.PipelineConfig{{Readers: []string{"nordigen"}, Transformers: []string{"foo"}, Writers: []string{"ynab"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown writer",
+			pipelines: []ynabber.PipelineConfig{{Readers: []string{"nordigen"}, Writers: []string{"foo"}}},
+			wantErr:   true,
+		},
+		{
+			name: "shared reader",
+			pipelines: []ynabber.PipelineConfig{
+				{Readers: []string{"nordigen"}, Writers: []string{"ynab"}},
+				{Readers: []string{"nordigen"}, Writers: []string{"json"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePipelines(tt.pipelines)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnabledTransformers(t *testing.T) {
+	cfg := ynabber.Config{}
+	cfg.Transform.StripCardNumbers = true
+	cfg.Transform.PayeeFile = "payees.csv"
+	cfg.Transform.FutureTransactions = "keep"
+
+	got := enabledTransformers(&cfg)
+	want := []string{"card_numbers", "payee_file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, name := range got {
+		if _, ok := transformers[name]; !ok {
+			t.Errorf("unknown transformer: %s", name)
+		}
+	}
+}
+
+func TestRunPipelines(t *testing.T) {
+	var a, b []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Pipelines: []ynabber.Pipeline{
+			{
+				Readers:      []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "a"}}}},
+				Transformers: []ynabber.Transformer{payeeTransformer{payee: "A"}},
+				Writers:      []ynabber.Writer{recordingWriter{t: &a}},
+			},
+			{
+				Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "b"}}}},
+				Writers: []ynabber.Writer{recordingWriter{t: &b}},
+			},
+		},
+	}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []ynabber.Transaction{{ID: "a", Payee: "A", Original: &ynabber.Original{}}}; !reflect.DeepEqual(a, want) {
+		t.Errorf("pipeline 0: got %+v, want %+v", a, want)
+	}
+	if want := []ynabber.Transaction{{ID: "b", Original: &ynabber.Original{}}}; !reflect.DeepEqual(b, want) {
+		t.Errorf("pipeline 1: got %+v, want %+v", b, want)
+	}
+}
+
+// flushingWriter records when it's flushed and written to in events
+type flushingWriter struct{ events *[]string }
+
+func (w flushingWriter) Flush(context.Context) error {
+	*w.events = append(*w.events, "flush")
+	return nil
+}
+
+func (w flushingWriter) Bulk(context.Context, []ynabber.Transaction) error {
+	*w.events = append(*w.events, "write")
+	return nil
+}
+
+// recordingReader records when it's read from in events
+type recordingReader struct{ events *[]string }
+
+func (r recordingReader) Bulk(context.Context) ([]ynabber.Transaction, error) {
+	*r.events = append(*r.events, "read")
+	return nil, nil
+}
+
+func TestRunFlushesBeforeReading(t *testing.T) {
+	var events []string
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{recordingReader{events: &events}},
+		Writers: []ynabber.Writer{flushingWriter{events: &events}},
+	}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"flush", "read", "write"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v, want %v", events, want)
+	}
+}
+
+func TestWriterTransformers(t *testing.T) {
+	var transformed, raw []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "a", Payee: "RAW"}}}},
+		Writers: []ynabber.Writer{
+			transformingWriter{
+				Writer:       recordingWriter{t: &transformed},
+				Transformers: []ynabber.Transformer{payeeTransformer{payee: "Clean"}},
+			},
+			recordingWriter{t: &raw},
+		},
+	}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []ynabber.Transaction{{ID: "a", Payee: "Clean", Original: &ynabber.Original{Payee: "RAW"}}}; !reflect.DeepEqual(transformed, want) {
+		t.Errorf("transformed writer: got %+v, want %+v", transformed, want)
+	}
+	if want := []ynabber.Transaction{{ID: "a", Payee: "RAW", Original: &ynabber.Original{Payee: "RAW"}}}; !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw writer: got %+v, want %+v", raw, want)
+	}
+}
+
+func TestNewPipelineWriterTransformers(t *testing.T) {
+	cfg := ynabber.Config{WriterTransformers: ynabber.StageMap{"json": {"card_numbers"}}}
+	p, err := newPipeline(&cfg, nil, ynabber.PipelineConfig{Writers: []string{"json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Writers[0].(transformingWriter); !ok {
+		t.Errorf("got %T, want transformingWriter", p.Writers[0])
+	}
+
+	cfg.WriterTransformers = ynabber.StageMap{"json": {"foo"}}
+	if _, err := newPipeline(&cfg, nil, ynabber.PipelineConfig{Writers: []string{"json"}}); err == nil {
+		t.Error("want error for unknown transformer")
+	}
+}
+
+func TestNewNotifiers(t *testing.T) {
+	cfg := ynabber.Config{Email: ynabber.Email{Host: "smtp.example.com", To: []string{"me@example.com"}}}
+	n, err := newNotifiers(&cfg, []string{"email", "telegram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n) != 2 {
+		t.Errorf("got %d notifiers, want 2", len(n))
+	}
+
+	if n, err := newNotifiers(&cfg, nil); err != nil || len(n) != 0 {
+		t.Errorf("got %v, %v, want no notifiers", n, err)
+	}
+	if _, err := newNotifiers(&cfg, []string{"pigeon"}); err == nil {
+		t.Error("want error for unknown notifier")
+	}
+	if _, err := newNotifiers(&ynabber.Config{}, []string{"email"}); err == nil {
+		t.Error("want error for unconfigured notifier")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Bulk(context.Context) ([]ynabber.Transaction, error) {
+	return nil, errors.New("bank is down")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Bulk(context.Context, []ynabber.Transaction) error {
+	return errors.New("YNAB is down")
+}
+
+func TestRunContinuesAfterFailures(t *testing.T) {
+	var written []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{failingReader{}, fakeReader{t: []ynabber.Transaction{{ID: "a"}}}},
+		Writers: []ynabber.Writer{failingWriter{}, recordingWriter{t: &written}},
+	}
+	_, err := run(context.Background(), y)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	for _, want := range []string{"bank is down", "YNAB is down"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+	if want := []ynabber.Transaction{{ID: "a", Original: &ynabber.Original{}}}; !reflect.DeepEqual(written, want) {
+		t.Errorf("got %+v, want %+v", written, want)
+	}
+}
+
+// partialReader is a reader with many banks where one of them fails
+type partialReader struct{}
+
+func (partialReader) Bulk(context.Context) ([]ynabber.Transaction, error) {
+	return []ynabber.Transaction{{ID: "a"}}, errors.New("bank NORDEA: requisition expired")
+}
+
+func TestRunKeepsPartialReads(t *testing.T) {
+	var written []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{partialReader{}},
+		Writers: []ynabber.Writer{recordingWriter{t: &written}},
+	}
+	s, err := run(context.Background(), y)
+	if err == nil || !strings.Contains(err.Error(), "requisition expired") {
+		t.Errorf("got error %v, want the failing bank", err)
+	}
+	if want := []ynabber.Transaction{{ID: "a", Original: &ynabber.Original{}}}; !reflect.DeepEqual(written, want) {
+		t.Errorf("got %+v, want %+v", written, want)
+	}
+	if len(s.Synced) != 0 {
+		t.Errorf("got synced %v, want none for a failed run", s.Synced)
+	}
+}
+
+func TestUseLedger(t *testing.T) {
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{Path: "ynabber.db", Dedup: true}}
+	p, err := newPipeline(&cfg, nil, ynabber.PipelineConfig{Writers: []string{"sqlite", "json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Writers) != 2 {
+		t.Fatalf("got %d writers, want 2", len(p.Writers))
+	}
+	if _, ok := p.Writers[1].(ledgerWriter); !ok {
+		t.Errorf("got last writer %T, want the ledger", p.Writers[1])
+	}
+	if len(p.Transformers) != 1 {
+		t.Fatalf("got %d transformers, want the ledger", len(p.Transformers))
+	}
+	if _, ok := p.Transformers[0].(sqlite.Ledger); !ok {
+		t.Errorf("got transformer %T, want the ledger", p.Transformers[0])
+	}
+
+	// Pipelines without the sqlite writer are left as is
+	p, err = newPipeline(&cfg, nil, ynabber.PipelineConfig{Writers: []string{"json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Transformers) != 0 {
+		t.Errorf("got %d transformers, want none", len(p.Transformers))
+	}
+}
+
+func TestRunSkipsLedgerAfterFailure(t *testing.T) {
+	var ledger []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "a"}}}},
+		Writers: []ynabber.Writer{failingWriter{}, ledgerWriter{Writer: recordingWriter{t: &ledger}, Config: &ynabber.Config{}}},
+	}
+	s, err := run(context.Background(), y)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if len(ledger) != 0 {
+		t.Errorf("got %+v written to the ledger, want none", ledger)
+	}
+	if !s.Written[1].Skipped {
+		t.Errorf("got %+v, want the ledger skipped", s.Written[1])
+	}
+}
+
+func TestLedgerDryRun(t *testing.T) {
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{Path: filepath.Join(t.TempDir(), "ynabber.db"), Dedup: true}}
+	cfg.YNAB.DryRun = true
+	var written []ynabber.Transaction
+	p := ynabber.Pipeline{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{Account: ynabber.Account{IBAN: "DK1"}, ID: "a"}}}},
+		Writers: []ynabber.Writer{recordingWriter{t: &written}, sqlite.Writer{Config: &cfg}},
+	}
+	useLedger(&cfg, &p, []string{"json", "sqlite"})
+	y := ynabber.Ynabber{Pipelines: []ynabber.Pipeline{p}}
+
+	// The dry run doesn't record the transaction, so the real run writes it
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	cfg.YNAB.DryRun = false
+	written = nil
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 {
+		t.Fatalf("got %d transactions written after the dry run, want 1", len(written))
+	}
+
+	// The real run records it, so the next run skips it
+	written = nil
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 0 {
+		t.Errorf("got %+v written again, want none", written)
+	}
+}
+
+// rejectingWriter accepts only the transactions with an ID in accept
+type rejectingWriter struct {
+	recordingWriter
+	accept map[ynabber.ID]bool
+}
+
+func (w rejectingWriter) Accepts(t ynabber.Transaction) bool { return w.accept[t.ID] }
+
+func TestLedgerRecordsAccepted(t *testing.T) {
+	var ledger, written []ynabber.Transaction
+	writer := rejectingWriter{recordingWriter: recordingWriter{t: &written}, accept: map[ynabber.ID]bool{"a": true}}
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "a"}, {ID: "b"}}}},
+		Writers: []ynabber.Writer{
+			writer,
+			ledgerWriter{Writer: recordingWriter{t: &ledger}, Config: &ynabber.Config{}, Others: []ynabber.Writer{writer}},
+		},
+	}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger) != 1 || ledger[0].ID != "a" {
+		t.Errorf("got %+v in the ledger, want only the accepted transaction", ledger)
+	}
+}
+
+// committingReader counts its commits
+type committingReader struct {
+	fakeReader
+	commits *int
+}
+
+func (r committingReader) Commit(context.Context) error {
+	*r.commits += 1
+	return nil
+}
+
+func TestRunCommitsAfterWriting(t *testing.T) {
+	var commits int
+	reader := committingReader{fakeReader: fakeReader{t: []ynabber.Transaction{{ID: "a"}}}, commits: &commits}
+
+	y := ynabber.Ynabber{Readers: []ynabber.Reader{reader}, Writers: []ynabber.Writer{failingWriter{}}}
+	if _, err := run(context.Background(), y); err == nil {
+		t.Fatal("want error")
+	}
+	if commits != 0 {
+		t.Errorf("got %d commits after failing write, want none", commits)
+	}
+
+	y.Writers = []ynabber.Writer{fakeWriter{}}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if commits != 1 {
+		t.Errorf("got %d commits, want 1", commits)
+	}
+}
+
+// TestRunRereadsWindow pins that there is no high-water mark: every run reads
+// the whole window of the reader, so transactions posted during missed runs
+// are read by the next one and those read before are deduplicated.
+func TestRunRereadsWindow(t *testing.T) {
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{Path: filepath.Join(t.TempDir(), "ynabber.db"), Dedup: true}}
+	account := ynabber.Account{IBAN: "DK1"}
+	day := func(d int) ynabber.Transaction {
+		return ynabber.Transaction{Account: account, ID: ynabber.ID(fmt.Sprint(d)), Date: time.Date(2023, 2, d, 0, 0, 0, 0, time.UTC)}
+	}
+
+	var written []ynabber.Transaction
+	read := func(window ...ynabber.Transaction) {
+		p := ynabber.Pipeline{
+			Readers: []ynabber.Reader{fakeReader{t: window}},
+			Writers: []ynabber.Writer{recordingWriter{t: &written}, sqlite.Writer{Config: &cfg}},
+		}
+		useLedger(&cfg, &p, []string{"json", "sqlite"})
+		written = nil
+		if _, err := run(context.Background(), ynabber.Ynabber{Pipelines: []ynabber.Pipeline{p}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read(day(1), day(2))
+	// Runs were missed, the next window overlaps the first and covers the
+	// gap
+	read(day(1), day(2), day(3), day(4))
+
+	var got []ynabber.ID
+	for _, v := range written {
+		got = append(got, v.ID)
+	}
+	if want := []ynabber.ID{"3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v written after the gap, want %v", got, want)
+	}
+}
+
+func TestValidateStages(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ynabber.Config
+		want string
+	}{
+		{name: "valid", cfg: ynabber.Config{Readers: []string{"csv"}, Writers: []string{"json"}}},
+		{name: "no readers", cfg: ynabber.Config{Writers: []string{"json"}}, want: "YNABBER_READERS is empty, valid options are: csv, nordigen"},
+		{name: "no writers", cfg: ynabber.Config{Readers: []string{"csv"}}, want: "YNABBER_WRITERS is empty"},
+		{name: "pipelines", cfg: ynabber.Config{Pipelines: ynabber.PipelineConfigs{{Readers: []string{"csv"}, Writers: []string{"json"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStages(&tt.cfg)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
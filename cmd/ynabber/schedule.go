@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// errNotDue is returned by a scheduled reader that is within its interval
+var errNotDue = errors.New("not due")
+
+// scheduledReader reads from Reader at most once every Interval, the time of
+// the last read is kept in Store to survive between runs
+type scheduledReader struct {
+	ynabber.Reader
+	Name     string
+	Interval time.Duration
+	Store    string
+
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// newScheduledReader returns reader scheduled by the interval configured for
+// name, reader is returned as is without an interval
+func newScheduledReader(cfg *ynabber.Config, name string, reader ynabber.Reader) ynabber.Reader {
+	interval, ok := cfg.ReaderIntervals[name]
+	if !ok || interval <= 0 {
+		return reader
+	}
+	return scheduledReader{
+		Reader:   reader,
+		Name:     name,
+		Interval: interval,
+		Store:    path.Clean(fmt.Sprintf("%s/last_run_%s", cfg.DataDir, name)),
+		Now:      time.Now,
+	}
+}
+
+// Bulk reads from the reader if its interval has passed since the last
+// successful read, otherwise errNotDue is returned
+func (s scheduledReader) Bulk(ctx context.Context) ([]ynabber.Transaction, error) {
+	last, err := lastRun(s.Store)
+	if err != nil {
+		return nil, fmt.Errorf("reading last run of %s: %w", s.Name, err)
+	}
+
+	now := s.Now()
+	if next := last.Add(s.Interval); !last.IsZero() && now.Before(next) {
+		slog.Info("Skipping reader, not due yet", "reader", s.Name, "next", next.Sub(now).Round(time.Second))
+		return nil, errNotDue
+	}
+
+	// A partial read is passed on but isn't recorded so the reader is due
+	// again on the next run
+	t, err := s.Reader.Bulk(ctx)
+	if err != nil {
+		return t, err
+	}
+	return t, recordRun(s.Store, now)
+}
+
+// Commit commits Reader if it keeps track of what it has read
+func (s scheduledReader) Commit(ctx context.Context) error {
+	if committer, ok := s.Reader.(ynabber.Committer); ok {
+		return committer.Commit(ctx)
+	}
+	return nil
+}
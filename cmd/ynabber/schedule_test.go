@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// countingReader counts the number of reads
+type countingReader struct{ n *int }
+
+func (r countingReader) Bulk(context.Context) ([]ynabber.Transaction, error) {
+	*r.n += 1
+	return []ynabber.Transaction{{}}, nil
+}
+
+func TestScheduledReader(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2023, 11, 4, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	var csvReads, nordigenReads int
+	cfg := ynabber.Config{
+		DataDir:         dir,
+		ReaderIntervals: ynabber.DurationMap{"csv": time.Minute, "nordigen": 12 * time.Hour},
+	}
+	csv := newScheduledReader(&cfg, "csv", countingReader{n: &csvReads}).(scheduledReader)
+	csv.Now = func() time.Time { return now }
+	nordigen := newScheduledReader(&cfg, "nordigen", countingReader{n: &nordigenReads}).(scheduledReader)
+	nordigen.Now = func() time.Time { return now }
+
+	// Run every 30 seconds for a day
+	for now = start; now.Before(start.Add(24 * time.Hour)); now = now.Add(30 * time.Second) {
+		for _, r := range []scheduledReader{csv, nordigen} {
+			if _, err := r.Bulk(context.Background()); err != nil && !errors.Is(err, errNotDue) {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if want := 24 * 60; csvReads != want {
+		t.Errorf("csv: got %d reads, want %d", csvReads, want)
+	}
+	if want := 2; nordigenReads != want {
+		t.Errorf("nordigen: got %d reads, want %d", nordigenReads, want)
+	}
+}
+
+func TestScheduledReaderPartial(t *testing.T) {
+	cfg := ynabber.Config{DataDir: t.TempDir(), ReaderIntervals: ynabber.DurationMap{"nordigen": time.Hour}}
+	r := newScheduledReader(&cfg, "nordigen", partialReader{}).(scheduledReader)
+
+	// The partial read is passed on and the reader stays due
+	for i := 0; i < 2; i++ {
+		got, err := r.Bulk(context.Background())
+		if err == nil || errors.Is(err, errNotDue) {
+			t.Fatalf("read %d: got error %v, want the failing bank", i, err)
+		}
+		if len(got) != 1 {
+			t.Errorf("read %d: got %d transactions, want 1", i, len(got))
+		}
+	}
+}
+
+func TestScheduledReaderWithoutInterval(t *testing.T) {
+	var n int
+	reader := countingReader{n: &n}
+	got := newScheduledReader(&ynabber.Config{}, "csv", reader)
+	if !reflect.DeepEqual(got, ynabber.Reader(reader)) {
+		t.Errorf("got %T, want reader as is", got)
+	}
+}
+
+func TestRunSkipsWritersWhenNoReaderIsDue(t *testing.T) {
+	var n int
+	cfg := ynabber.Config{
+		DataDir:         t.TempDir(),
+		ReaderIntervals: ynabber.DurationMap{"csv": time.Hour},
+	}
+	var written []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{newScheduledReader(&cfg, "csv", countingReader{n: &n})},
+		Writers: []ynabber.Writer{recordingWriter{t: &written}},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := run(context.Background(), y); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 1 || len(written) != 1 {
+		t.Errorf("got %d reads and %d written, want 1 of each", n, len(written))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// stage is the outcome of a single reader or writer during a run
+type stage struct {
+	Name         string
+	Transactions int
+	Skipped      bool
+	Err          error
+}
+
+// summary is the outcome of a run, it's sent to the notifiers when
+// YNABBER_NOTIFY_ON_RUN is set
+type summary struct {
+	Read    []stage
+	Written []stage
+	// Skipped is the number of transactions read but dropped before they
+	// were written, by deduplication or by transformers
+	Skipped int
+	// Removed are the transformers that dropped transactions and how many
+	Removed []stage
+	// Synced is the number of transactions by IBAN of the accounts written
+	// by all writers of their pipeline without error. Accounts the readers
+	// read without new transactions are included with none.
+	Synced map[string]int
+}
+
+// stageName names v by its type, wrappers are named after what they wrap
+func stageName(v any) string {
+	switch v := v.(type) {
+	case scheduledReader:
+		return stageName(v.Reader)
+	case transformingWriter:
+		return stageName(v.Writer)
+	case ledgerWriter:
+		return stageName(v.Writer)
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Message returns the summary as a message for the notifiers, err is the
+// error returned by the run
+func (s summary) Message(err error) string {
+	var b strings.Builder
+	if err != nil {
+		b.WriteString("Ynabber run failed")
+	} else {
+		b.WriteString("Ynabber run succeeded")
+	}
+	for _, r := range s.Read {
+		switch {
+		case r.Skipped:
+			fmt.Fprintf(&b, "\nSkipped %s, not due", r.Name)
+		case r.Err != nil && r.Transactions > 0:
+			fmt.Fprintf(&b, "\nRead %d transaction(s) from %s but some failed: %s", r.Transactions, r.Name, r.Err)
+		case r.Err != nil:
+			fmt.Fprintf(&b, "\nFailed to read from %s: %s", r.Name, r.Err)
+		default:
+			fmt.Fprintf(&b, "\nRead %d transaction(s) from %s", r.Transactions, r.Name)
+		}
+	}
+	if s.Skipped > 0 {
+		fmt.Fprintf(&b, "\nSkipped %d transaction(s), duplicates or removed by transformers", s.Skipped)
+	}
+	for _, r := range s.Removed {
+		fmt.Fprintf(&b, "\nRemoved %d transaction(s) by %s", r.Transactions, r.Name)
+	}
+	for _, w := range s.Written {
+		if w.Skipped {
+			fmt.Fprintf(&b, "\nSkipped %s, the other stages failed", w.Name)
+			continue
+		}
+		if w.Err != nil {
+			fmt.Fprintf(&b, "\nFailed to write %d transaction(s) to %s: %s", w.Transactions, w.Name, w.Err)
+		} else {
+			fmt.Fprintf(&b, "\nWrote %d transaction(s) to %s", w.Transactions, w.Name)
+		}
+	}
+	return b.String()
+}
+
+// recordState updates the state file with the accounts synced in s at now.
+// Failing to do so is only logged since the transactions are written. Dry
+// runs aren't recorded since nothing is sent to YNAB.
+func recordState(cfg ynabber.Config, s summary, now time.Time) {
+	if cfg.YNAB.DryRun {
+		return
+	}
+	if err := ynabber.UpdateState(ynabber.StateFile(cfg.DataDir), s.Synced, now); err != nil {
+		slog.Warn("Failed to update state file", "error", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/transformer"
+)
+
+func TestRunSummary(t *testing.T) {
+	var written []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{
+			failingReader{},
+			fakeReader{t: []ynabber.Transaction{{ID: "a"}, {ID: "b"}}},
+		},
+		Writers: []ynabber.Writer{
+			failingWriter{},
+			transformingWriter{Writer: recordingWriter{t: &written}},
+		},
+	}
+	s, err := run(context.Background(), y)
+
+	want := `Ynabber run failed
+Failed to read from main.failingReader: bank is down
+Read 2 transaction(s) from main.fakeReader
+Failed to write 2 transaction(s) to main.failingWriter: YNAB is down
+Wrote 2 transaction(s) to main.recordingWriter`
+	if got := s.Message(err); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	s = summary{Read: []stage{{Name: "csv.Reader", Skipped: true}}}
+	want = "Ynabber run succeeded\nSkipped csv.Reader, not due"
+	if got := s.Message(nil); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	s = summary{Read: []stage{{Name: "csv.Reader", Transactions: 3}}, Skipped: 1}
+	want = "Ynabber run succeeded\nRead 3 transaction(s) from csv.Reader\nSkipped 1 transaction(s), duplicates or removed by transformers"
+	if got := s.Message(nil); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRunRemoved(t *testing.T) {
+	var written []ynabber.Transaction
+	exclude, err := transformer.NewMemoExclude([]string{"interest"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{
+			{ID: "a", Memo: "Interest"}, {ID: "b", Memo: "Groceries"}, {ID: "b", Memo: "Groceries"},
+		}}},
+		Transformers: []ynabber.Transformer{exclude},
+		Writers:      []ynabber.Writer{recordingWriter{t: &written}},
+	}
+	s, err := run(context.Background(), y)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Ynabber run succeeded
+Read 3 transaction(s) from main.fakeReader
+Skipped 2 transaction(s), duplicates or removed by transformers
+Removed 1 transaction(s) by transformer.MemoExclude
+Wrote 1 transaction(s) to main.recordingWriter`
+	if got := s.Message(err); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// accountReader reads the accounts without any transactions
+type accountReader struct{ ibans []string }
+
+func (r accountReader) Bulk(ctx context.Context) ([]ynabber.Transaction, error) {
+	for _, iban := range r.ibans {
+		ynabber.AccountRead(ctx, iban)
+	}
+	return nil, nil
+}
+
+func TestRunSyncedWithoutTransactions(t *testing.T) {
+	var written []ynabber.Transaction
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{
+			accountReader{ibans: []string{"DK1", "DK2"}},
+			fakeReader{t: []ynabber.Transaction{{Account: ynabber.Account{IBAN: "DK2"}, ID: "a"}}},
+		},
+		Writers: []ynabber.Writer{recordingWriter{t: &written}},
+	}
+	s, err := run(context.Background(), y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"DK1": 0, "DK2": 1}; !reflect.DeepEqual(s.Synced, want) {
+		t.Errorf("got synced %v, want %v", s.Synced, want)
+	}
+}
+
+func TestRunSynced(t *testing.T) {
+	var written []ynabber.Transaction
+	checking := ynabber.Account{IBAN: "DK1"}
+	savings := ynabber.Account{IBAN: "DK2"}
+	y := ynabber.Ynabber{
+		Pipelines: []ynabber.Pipeline{
+			{
+				Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{
+					{Account: checking, ID: "a"}, {Account: checking, ID: "b"}, {Account: checking, ID: "a"},
+				}}},
+				Writers: []ynabber.Writer{recordingWriter{t: &written}},
+			},
+			{
+				Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{Account: savings, ID: "c"}}}},
+				Writers: []ynabber.Writer{failingWriter{}},
+			},
+		},
+	}
+	s, _ := run(context.Background(), y)
+
+	if want := map[string]int{"DK1": 2}; !reflect.DeepEqual(s.Synced, want) {
+		t.Errorf("got synced %v, want %v", s.Synced, want)
+	}
+	if s.Skipped != 1 {
+		t.Errorf("got %d skipped, want the duplicate", s.Skipped)
+	}
+
+	// Dry runs don't touch the state
+	cfg := ynabber.Config{DataDir: t.TempDir()}
+	cfg.YNAB.DryRun = true
+	recordState(cfg, s, time.Now())
+	if _, err := os.Stat(ynabber.StateFile(cfg.DataDir)); !os.IsNotExist(err) {
+		t.Errorf("got state file after dry run: %v", err)
+	}
+
+	cfg.YNAB.DryRun = false
+	recordState(cfg, s, time.Now())
+	state, err := ynabber.ReadState(ynabber.StateFile(cfg.DataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != 1 || state["DK1"].Transactions != 2 {
+		t.Errorf("got state %+v", state)
+	}
+}
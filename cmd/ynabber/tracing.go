@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/carlmjohnson/versioninfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is a no-op until setupTracing registers a provider
+var tracer = otel.Tracer("github.com/martinohansen/ynabber")
+
+// setupTracing registers a global tracer provider exporting spans with OTLP
+// over HTTP. The exporter is configured with the standard OTEL_EXPORTER_OTLP_*
+// environment variables. The returned function flushes and stops the
+// provider.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("ynabber"),
+		semconv.ServiceVersion(versioninfo.Short()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a span for a stage of the run named after v, see
+// stageName
+func startSpan(ctx context.Context, stage string, v any) (context.Context, trace.Span) {
+	name := stageName(v)
+	return tracer.Start(ctx, fmt.Sprintf("%s %s", stage, name),
+		trace.WithAttributes(attribute.String(fmt.Sprintf("ynabber.%s", stage), name)),
+	)
+}
+
+// endSpan records the transaction count and error, if any, and ends span
+func endSpan(span trace.Span, transactions int, err error) {
+	span.SetAttributes(attribute.Int("ynabber.transactions", transactions))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeReader struct{ t []ynabber.Transaction }
+
+func (r fakeReader) Bulk(context.Context) ([]ynabber.Transaction, error) { return r.t, nil }
+
+type fakeWriter struct{}
+
+func (w fakeWriter) Bulk(context.Context, []ynabber.Transaction) error { return nil }
+
+// spanWriter records whether Bulk is called within a span
+type spanWriter struct{ traced *bool }
+
+func (w spanWriter) Bulk(ctx context.Context, _ []ynabber.Transaction) error {
+	*w.traced = trace.SpanFromContext(ctx).SpanContext().IsValid()
+	return nil
+}
+
+func TestRunSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+
+	var traced bool
+	y := ynabber.Ynabber{
+		Readers: []ynabber.Reader{fakeReader{t: []ynabber.Transaction{{ID: "1"}, {ID: "2"}}}},
+		Writers: []ynabber.Writer{transformingWriter{Writer: spanWriter{traced: &traced}}},
+	}
+	if _, err := run(context.Background(), y); err != nil {
+		t.Fatal(err)
+	}
+	if !traced {
+		t.Error("writer wasn't called within its span")
+	}
+
+	// Wrapped stages are named after what they wrap
+	want := []string{"flush main.spanWriter", "reader main.fakeReader", "writer main.spanWriter", "run"}
+	spans := recorder.Ended()
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		if span.Name() != want[i] {
+			t.Errorf("span %d: got %s, want %s", i, span.Name(), want[i])
+		}
+		if span.Name() == "run" {
+			continue
+		}
+		if span.Parent().SpanID() != spans[len(spans)-1].SpanContext().SpanID() {
+			t.Errorf("span %s is not a child of run", span.Name())
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "ynabber.transactions" && attr.Value.AsInt64() != 2 && !strings.HasPrefix(span.Name(), "flush") {
+				t.Errorf("span %s: got %d transactions, want 2", span.Name(), attr.Value.AsInt64())
+			}
+		}
+	}
+}
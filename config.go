@@ -2,11 +2,75 @@ package ynabber
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
 )
 
 const DateFormat = "2006-01-02"
 
+// LoadConfigFile sets the environment variables defined in the YAML or JSON
+// file at path, variables already in the environment are left as is so they
+// override the file. The file maps variable names to values, lists and
+// objects are encoded as JSON. For example:
+//
+//	YNAB_BUDGETID: "abc"
+//	YNABBER_READERS: [nordigen, csv]
+//	YNAB_ACCOUNTMAP:
+//	  DK9520000123456789: "ynab-account-id"
+func LoadConfigFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for name, value := range values {
+		name = strings.ToUpper(name)
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+		s, err := configValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.Setenv(name, s); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// configValue returns value from a config file in the format of an
+// environment variable, see LoadConfigFile
+func configValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any, map[string]any:
+		// Lists are kept as JSON rather than joined by commas so entries
+		// like regular expressions can contain commas, see List
+		b, err := json.Marshal(v)
+		return string(b), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
 type Date time.Time
 
 // Decode implements `envconfig.Decoder` for Date to parse string to time.Time
@@ -30,32 +94,840 @@ func (accountMap *AccountMap) Decode(value string) error {
 	return nil
 }
 
+// SplitRule captures the parts of a transaction from its memo
+type SplitRule struct {
+	// Match selects which transactions the rule applies to
+	Match *regexp.Regexp
+	// Parts is matched repeatedly against the memo and must capture a named
+	// group called amount and optionally one called memo
+	Parts *regexp.Regexp
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` for SplitRule to compile the
+// regular expressions
+func (rule *SplitRule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Match string `json:"match"`
+		Parts string `json:"parts"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	rule.Match, err = regexp.Compile(raw.Match)
+	if err != nil {
+		return fmt.Errorf("match: %w", err)
+	}
+	rule.Parts, err = regexp.Compile(raw.Parts)
+	if err != nil {
+		return fmt.Errorf("parts: %w", err)
+	}
+	if rule.Parts.SubexpIndex("amount") == -1 {
+		return fmt.Errorf("parts: missing named group amount")
+	}
+	return nil
+}
+
+type SplitRules []SplitRule
+
+// Decode implements `envconfig.Decoder` for SplitRules to decode JSON properly
+func (rules *SplitRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), rules)
+}
+
+// CategoryRule assigns a YNAB category to the transactions it matches
+type CategoryRule struct {
+	// Payee matches transactions with exactly this payee
+	Payee string
+	// Memo matches transactions with a memo matching the regular expression
+	Memo *regexp.Regexp
+	// CategoryID is the ID of the YNAB category
+	CategoryID string
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` for CategoryRule to compile the
+// regular expression
+func (rule *CategoryRule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Payee      string `json:"payee"`
+		Memo       string `json:"memo"`
+		CategoryID string `json:"category_id"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	if raw.CategoryID == "" {
+		return fmt.Errorf("missing category_id")
+	}
+	if (raw.Payee == "") == (raw.Memo == "") {
+		return fmt.Errorf("category %s: exactly one of payee and memo must be set", raw.CategoryID)
+	}
+	if raw.Memo != "" {
+		rule.Memo, err = regexp.Compile(raw.Memo)
+		if err != nil {
+			return fmt.Errorf("category %s: memo: %w", raw.CategoryID, err)
+		}
+	}
+	rule.Payee = raw.Payee
+	rule.CategoryID = raw.CategoryID
+	return nil
+}
+
+type CategoryRules []CategoryRule
+
+// Decode implements `envconfig.Decoder` for CategoryRules to decode JSON
+// properly
+func (rules *CategoryRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), rules)
+}
+
+// FlagRule flags the transactions it matches
+type FlagRule struct {
+	// Match is matched against both the payee and the memo
+	Match *regexp.Regexp
+	// Color is the YNAB flag color
+	Color string
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` for FlagRule to compile the
+// regular expression and validate the color
+func (rule *FlagRule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Match string `json:"match"`
+		Color string `json:"color"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	rule.Match, err = regexp.Compile(raw.Match)
+	if err != nil {
+		return fmt.Errorf("match: %w", err)
+	}
+	if !validFlagColor(raw.Color) {
+		return fmt.Errorf("invalid flag color %q for %q, must be one of %v", raw.Color, raw.Match, FlagColors)
+	}
+	rule.Color = raw.Color
+	return nil
+}
+
+type FlagRules []FlagRule
+
+// Decode implements `envconfig.Decoder` for FlagRules to decode JSON properly
+func (rules *FlagRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), rules)
+}
+
+// PayeeRule renames the payees it matches
+type PayeeRule struct {
+	// Match is matched against the payee
+	Match *regexp.Regexp
+	// Payee replaces the payee
+	Payee string
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` for PayeeRule to compile the
+// regular expression
+func (rule *PayeeRule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Match string `json:"match"`
+		Payee string `json:"payee"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	if raw.Payee == "" {
+		return fmt.Errorf("missing payee for %q", raw.Match)
+	}
+	rule.Match, err = regexp.Compile(raw.Match)
+	if err != nil {
+		return fmt.Errorf("payee %s: match: %w", raw.Payee, err)
+	}
+	rule.Payee = raw.Payee
+	return nil
+}
+
+type PayeeRules []PayeeRule
+
+// Decode implements `envconfig.Decoder` for PayeeRules to decode JSON properly
+func (rules *PayeeRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), rules)
+}
+
+// listEntries splits value into its entries. A value starting with [ is a
+// JSON list of strings, anything else is separated by commas. The JSON list
+// allows entries like regular expressions to contain commas.
+func listEntries(value string) ([]string, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		var entries []string
+		if err := json.Unmarshal([]byte(value), &entries); err != nil {
+			return nil, fmt.Errorf("parsing JSON list: %w", err)
+		}
+		return entries, nil
+	}
+	return strings.Split(value, ","), nil
+}
+
+// List is a list of strings separated by commas or a JSON list, see
+// listEntries
+type List []string
+
+// Decode implements `envconfig.Decoder` for List to accept JSON lists
+func (list *List) Decode(value string) error {
+	if strings.TrimSpace(value) == "" {
+		*list = nil
+		return nil
+	}
+	entries, err := listEntries(value)
+	if err != nil {
+		return err
+	}
+	*list = entries
+	return nil
+}
+
+// PayeeStripEntry is removed from a payee. It's either a literal string, a
+// regular expression or the cleanup of the unstructured payee source.
+type PayeeStripEntry struct {
+	Literal         string
+	Pattern         *regexp.Regexp
+	NonAlphanumeric bool
+}
+
+// PayeeStrip is a list of entries removed from a payee in order. An entry is
+// either a literal string, a regular expression between slashes or the name
+// non_alphanumeric, for example: foo,/^MobilePay\s+/,non_alphanumeric. Use a
+// JSON list for entries containing commas, see listEntries.
+type PayeeStrip []PayeeStripEntry
+
+// Decode implements `envconfig.Decoder` for PayeeStrip to compile the regular
+// expressions once instead of for every transaction. Literal entries are kept
+// as is, including their whitespace.
+func (strip *PayeeStrip) Decode(value string) error {
+	entries, err := listEntries(value)
+	if err != nil {
+		return err
+	}
+	*strip = nil
+	for _, entry := range entries {
+		switch {
+		case entry == "":
+		case entry == "non_alphanumeric":
+			*strip = append(*strip, PayeeStripEntry{NonAlphanumeric: true})
+		case len(entry) > 1 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+			pattern, err := regexp.Compile(entry[1 : len(entry)-1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry, err)
+			}
+			*strip = append(*strip, PayeeStripEntry{Pattern: pattern})
+		default:
+			*strip = append(*strip, PayeeStripEntry{Literal: entry})
+		}
+	}
+	return nil
+}
+
+// AccountMatcher matches IBANs against a list of entries. An entry is either
+// an exact IBAN, a prefix ending in * or a regular expression between
+// slashes, for example: DK9520000123456789, DK95*, /^NO83.*9$/. Use a JSON
+// list for regular expressions containing commas, see listEntries.
+type AccountMatcher struct {
+	exact    map[string]bool
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
+// Decode implements `envconfig.Decoder` for AccountMatcher to parse the comma
+// separated entries once instead of on every match
+func (m *AccountMatcher) Decode(value string) error {
+	entries, err := listEntries(value)
+	if err != nil {
+		return err
+	}
+	*m = AccountMatcher{exact: map[string]bool{}}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "/") != (len(entry) > 1 && strings.HasSuffix(entry, "/")):
+			// A regular expression split by one of its commas
+			return fmt.Errorf("%s: incomplete regular expression, use a JSON list for expressions with commas", entry)
+		case len(entry) > 1 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+			pattern, err := regexp.Compile(entry[1 : len(entry)-1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry, err)
+			}
+			m.patterns = append(m.patterns, pattern)
+		case strings.HasSuffix(entry, "*"):
+			m.prefixes = append(m.prefixes, strings.TrimSuffix(entry, "*"))
+		default:
+			m.exact[entry] = true
+		}
+	}
+	return nil
+}
+
+// Match reports whether iban matches any of the entries
+func (m AccountMatcher) Match(iban string) bool {
+	if m.exact[iban] {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(iban, prefix) {
+			return true
+		}
+	}
+	for _, pattern := range m.patterns {
+		if pattern.MatchString(iban) {
+			return true
+		}
+	}
+	return false
+}
+
+// Template is a Go text/template parsed when decoded
+type Template struct {
+	*template.Template
+}
+
+// Decode implements `envconfig.Decoder` for Template to parse the template
+// once instead of for every use
+func (t *Template) Decode(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := template.New("").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return err
+	}
+	t.Template = parsed
+	return nil
+}
+
+type HeaderMap map[string]string
+
+// Decode implements `envconfig.Decoder` for HeaderMap to decode JSON properly
+func (headerMap *HeaderMap) Decode(value string) error {
+	return json.Unmarshal([]byte(value), headerMap)
+}
+
+// Pattern is a regular expression with a name
+type Pattern struct {
+	Name    string
+	Pattern string
+}
+
+// PatternMap is a JSON object from name to regular expression. The patterns
+// are kept in the order of the object since the first match wins.
+type PatternMap []Pattern
+
+// Decode implements `envconfig.Decoder` for PatternMap to decode the JSON
+// object in order
+func (patternMap *PatternMap) Decode(value string) error {
+	*patternMap = nil
+	d := json.NewDecoder(strings.NewReader(value))
+	if t, err := d.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return fmt.Errorf("want a JSON object, got %v", t)
+	}
+	for d.More() {
+		name, err := d.Token()
+		if err != nil {
+			return err
+		}
+		var pattern string
+		if err := d.Decode(&pattern); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*patternMap = append(*patternMap, Pattern{Name: name.(string), Pattern: pattern})
+	}
+	_, err := d.Token()
+	return err
+}
+
+// PipelineConfig names the stages of a pipeline, see Config.Pipelines
+type PipelineConfig struct {
+	Readers      []string `json:"readers"`
+	Transformers []string `json:"transformers"`
+	Writers      []string `json:"writers"`
+}
+
+type PipelineConfigs []PipelineConfig
+
+// Decode implements `envconfig.Decoder` for PipelineConfigs to decode JSON
+// properly
+func (pipelines *PipelineConfigs) Decode(value string) error {
+	return json.Unmarshal([]byte(value), pipelines)
+}
+
+// AccountConfig overrides global settings for a single account, see
+// Config.Accounts
+type AccountConfig struct {
+	// IBAN of the account the overrides apply to
+	IBAN string `json:"iban"`
+
+	// PayeeSource overrides NORDIGEN_PAYEE_SOURCE
+	PayeeSource []string `json:"payee_source"`
+
+	// Cleared overrides YNAB_CLEARED
+	Cleared string `json:"cleared"`
+
+	// SwapFlow overrides YNAB_SWAPFLOW, nil leaves it to YNAB_SWAPFLOW
+	SwapFlow *bool `json:"swap_flow"`
+
+	// CategoryMap overrides YNAB_CATEGORY_MAP
+	CategoryMap CategoryRules `json:"category_map"`
+}
+
+type AccountConfigs []AccountConfig
+
+// Decode implements `envconfig.Decoder` for AccountConfigs to decode JSON
+// properly
+func (accounts *AccountConfigs) Decode(value string) error {
+	return json.Unmarshal([]byte(value), accounts)
+}
+
+// CSVProfile describes the layout of the CSV files from one bank
+type CSVProfile struct {
+	// Glob matches the names of the files using the profile, for example
+	// "nordea-*.csv"
+	Glob string `json:"glob"`
+
+	// Columns maps the fields date, amount, debit, credit, payee, memo, id
+	// and account to the name of the column in the header. Date is required
+	// and so is either amount or debit and credit for banks that list
+	// outflow and inflow in separate columns. The debit is always outflow
+	// regardless of its sign and empty cells count as zero.
+	Columns map[string]string `json:"columns"`
+
+	// Account is used as the account of all transactions if there is no
+	// account column, it's matched against YNAB_ACCOUNTMAP like an IBAN
+	Account string `json:"account"`
+
+	// Delimiter separating the columns, defaults to comma
+	Delimiter string `json:"delimiter"`
+
+	// DateLayout is the Go layout of the date column, defaults to 2006-01-02
+	DateLayout string `json:"date_layout"`
+
+	// DecimalSeparator of the amount column, either period or comma.
+	// Defaults to period.
+	DecimalSeparator string `json:"decimal_separator"`
+}
+
+type CSVProfiles map[string]CSVProfile
+
+// Decode implements `envconfig.Decoder` for CSVProfiles to decode JSON and
+// validate the profiles
+func (profiles *CSVProfiles) Decode(value string) error {
+	err := json.Unmarshal([]byte(value), profiles)
+	if err != nil {
+		return err
+	}
+	for name, profile := range *profiles {
+		if _, err := filepath.Match(profile.Glob, ""); err != nil || profile.Glob == "" {
+			return fmt.Errorf("profile %s: invalid glob %q", name, profile.Glob)
+		}
+		if profile.Columns["date"] == "" {
+			return fmt.Errorf("profile %s: missing date column", name)
+		}
+		split := profile.Columns["debit"] != "" || profile.Columns["credit"] != ""
+		if profile.Columns["amount"] == "" && !split {
+			return fmt.Errorf("profile %s: missing amount column or debit and credit columns", name)
+		}
+		if profile.Columns["amount"] != "" && split {
+			return fmt.Errorf("profile %s: amount column can't be combined with debit and credit columns", name)
+		}
+		if len([]rune(profile.Delimiter)) > 1 {
+			return fmt.Errorf("profile %s: delimiter must be a single character", name)
+		}
+		switch profile.DecimalSeparator {
+		case "", ".", ",":
+		default:
+			return fmt.Errorf("profile %s: decimal separator must be period or comma", name)
+		}
+	}
+	return nil
+}
+
+type DurationMap map[string]time.Duration
+
+// Decode implements `envconfig.Decoder` for DurationMap to decode JSON with
+// durations like "1h30m"
+func (durationMap *DurationMap) Decode(value string) error {
+	var m map[string]string
+	err := json.Unmarshal([]byte(value), &m)
+	if err != nil {
+		return err
+	}
+	*durationMap = DurationMap{}
+	for key, s := range m {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		(*durationMap)[key] = d
+	}
+	return nil
+}
+
+type StageMap map[string][]string
+
+// Decode implements `envconfig.Decoder` for StageMap to decode JSON properly
+func (stageMap *StageMap) Decode(value string) error {
+	return json.Unmarshal([]byte(value), stageMap)
+}
+
+// FlagColors is the flag colors supported by YNAB
+var FlagColors = []string{"red", "orange", "yellow", "green", "blue", "purple"}
+
+// validFlagColor reports whether color is supported by YNAB
+func validFlagColor(color string) bool {
+	for _, c := range FlagColors {
+		if color == c {
+			return true
+		}
+	}
+	return false
+}
+
+type FlagColorMap map[string]string
+
+// Decode implements `envconfig.Decoder` for FlagColorMap to decode JSON and
+// validate the colors
+func (flagColorMap *FlagColorMap) Decode(value string) error {
+	err := json.Unmarshal([]byte(value), flagColorMap)
+	if err != nil {
+		return err
+	}
+	for key, color := range *flagColorMap {
+		if !validFlagColor(color) {
+			return fmt.Errorf("invalid flag color %q for %q, must be one of %v", color, key, FlagColors)
+		}
+	}
+	return nil
+}
+
 // Config is loaded from the environment during execution with cmd/ynabber
 type Config struct {
 	// DataDir is the path for storing files
 	DataDir string `envconfig:"YNABBER_DATADIR" default:"."`
 
-	// Debug prints more log statements
+	// Debug prints more log statements, same as YNABBER_LOG_LEVEL=debug
 	Debug bool `envconfig:"YNABBER_DEBUG" default:"false"`
 
-	// Interval is how often to execute the read/write loop, 0=run only once
-	Interval time.Duration `envconfig:"YNABBER_INTERVAL" default:"5m"`
+	// LogLevel is the minimum level of log statements to print. Valid
+	// options are: debug, info, warn, error
+	LogLevel string `envconfig:"YNABBER_LOG_LEVEL" default:"info"`
+
+	// LogFormat is the format of the log statements. Valid options are:
+	// text, json
+	LogFormat string `envconfig:"YNABBER_LOG_FORMAT" default:"text"`
+
+	// Interval is how often to execute the read/write loop outside Lambda,
+	// for example 1h. The loop runs until SIGINT or SIGTERM. 0=run only once
+	Interval time.Duration `envconfig:"YNABBER_INTERVAL" default:"0"`
+
+	// MetricsAddr is the address to serve Prometheus metrics on at /metrics
+	// outside Lambda, for example :9090. Most useful with YNABBER_INTERVAL.
+	// Empty disables the metrics.
+	MetricsAddr string `envconfig:"YNABBER_METRICS_ADDR"`
+
+	// MinInterval is the minimum time between two runs, a run started sooner
+	// than this after the previous successful one is skipped. This protects
+	// the limited daily Nordigen quota from misconfigured triggers while a
+	// failed run can be retried right away. 0=disabled
+	MinInterval time.Duration `envconfig:"YNABBER_MIN_INTERVAL" default:"0"`
 
-	// Readers is a list of sources to read transactions from. Currently only
-	// Nordigen is supported.
-	Readers []string `envconfig:"YNABBER_READERS" default:"nordigen"`
+	// Force runs regardless of MinInterval
+	Force bool `envconfig:"YNABBER_FORCE" default:"false"`
+
+	// Tracing exports OpenTelemetry traces of each run. The exporter is
+	// configured with the standard OTEL_EXPORTER_OTLP_* environment variables
+	Tracing bool `envconfig:"YNABBER_TRACING" default:"false"`
+
+	// AccountAliases maps IBAN to a friendly name used when logging and
+	// notifying about an account instead of the IBAN. This is purely for
+	// display and separate from YNAB_ACCOUNTMAP. For example:
+	// '{"<IBAN>": "Joint Checking"}'
+	AccountAliases AccountMap `envconfig:"YNABBER_ACCOUNT_ALIASES"`
+
+	// Accounts overrides the payee source, cleared status, swap flow and
+	// category map of single accounts by IBAN. Settings left out fall back
+	// to NORDIGEN_PAYEE_SOURCE, YNAB_CLEARED, YNAB_SWAPFLOW and
+	// YNAB_CATEGORY_MAP. For example:
+	// '[{"iban": "<IBAN>", "payee_source": ["name"], "cleared": "cleared", "swap_flow": true}]'
+	Accounts AccountConfigs `envconfig:"YNABBER_ACCOUNTS"`
+
+	// ConfigFile is a YAML or JSON file with settings, environment variables
+	// override the settings in the file. See LoadConfigFile for the format.
+	ConfigFile string `envconfig:"YNABBER_CONFIG"`
+
+	// Readers is a list of sources to read transactions from. Valid options
+	// are: nordigen, csv, ofx, plaid
+	Readers List `envconfig:"YNABBER_READERS" default:"nordigen"`
+
+	// ReaderIntervals is the minimum time between two reads of a reader by
+	// name. A reader is skipped by runs within its interval, which lets a
+	// frequent schedule check local sources often while quota limited APIs
+	// are only read a few times a day. Writers are skipped if all readers of
+	// a run are skipped. For example: '{"nordigen": "12h", "csv": "1m"}'
+	ReaderIntervals DurationMap `envconfig:"YNABBER_READER_INTERVALS"`
 
 	// Writers is a list of destinations to write transactions to.
-	Writers []string `envconfig:"YNABBER_WRITERS" default:"ynab"`
+	Writers List `envconfig:"YNABBER_WRITERS" default:"ynab"`
+
+	// WriterTransformers applies transformers to the transactions of a single
+	// writer by name, after the transformers shared by all writers. Each
+	// writer gets its own copy of the transactions so one writer's
+	// transformers never affect another. Transformers are named like in
+	// YNABBER_PIPELINES. For example, clean payees for YNAB only:
+	// '{"ynab": ["card_numbers", "payee_processors"]}'
+	WriterTransformers StageMap `envconfig:"YNABBER_WRITER_TRANSFORMERS"`
+
+	// NotifyOnRun sends a summary of each run to the notifiers with the
+	// number of transactions read and written and any errors
+	NotifyOnRun bool `envconfig:"YNABBER_NOTIFY_ON_RUN" default:"false"`
+
+	// Notifiers is a list of destinations to send messages to, for example
+	// the link to authorize a requisition. Messages are sent to all
+	// notifiers. Valid options are: email, telegram, slack
+	Notifiers List `envconfig:"YNABBER_NOTIFIERS"`
+
+	// Pipelines routes transactions from specific readers through specific
+	// transformers to specific writers. When defined YNABBER_READERS,
+	// YNABBER_WRITERS and the settings enabling transformers are ignored.
+	// Each pipeline needs at least one reader and one writer and a reader can
+	// only be part of one pipeline. Readers and writers are named like in
+	// YNABBER_READERS and YNABBER_WRITERS, transformers are one of:
+	//
+	//	* exclude_memo: drops transactions by YNABBER_EXCLUDE_MEMO
+	//	* card_numbers: strips masked card numbers from the payee
+	//	* payee_references: strips trailing references from the payee as
+	//	  configured by YNABBER_PAYEE_REFERENCE_*
+	//	* payee_processors: strips the processors of YNABBER_PAYEE_PROCESSORS
+	//	  and YNABBER_PAYEE_PROCESSOR_MAP from the payee
+	//	* payee_file: renames payees from YNABBER_PAYEE_FILE
+	//	* coalesce: merges split transactions within YNABBER_COALESCE_WINDOW
+	//	* memo_tags: appends the tags of YNABBER_MEMO_TAGS to the memo
+	//	* future_transactions: applies YNABBER_FUTURE_TRANSACTIONS
+	//
+	// Transformers run in the order listed. For example:
+	// '[{"readers": ["nordigen"], "transformers": ["card_numbers"], "writers": ["ynab", "json"]}]'
+	Pipelines PipelineConfigs `envconfig:"YNABBER_PIPELINES"`
 
 	// Reader and/or writer specific settings
 	Nordigen Nordigen
+	CSV      CSV
+	OFX      OFX
+	Plaid    Plaid
 	YNAB     YNAB
+	S3       S3
+	JSON     JSON
+	SQLite   SQLite
+	Actual   Actual
+	Firefly  Firefly
+	Webhook  Webhook
+	Email    Email
+	Telegram Telegram
+	Slack    Slack
+
+	// Transform settings applied to transactions from all readers
+	Transform Transform
+}
+
+// AccountName returns the alias of iban or iban itself if it has no alias
+func (c Config) AccountName(iban string) string {
+	if alias, ok := c.AccountAliases[iban]; ok {
+		return alias
+	}
+	return iban
+}
+
+// account returns the overrides of the account with iban, the zero value if
+// there are none
+func (c Config) account(iban string) AccountConfig {
+	for _, account := range c.Accounts {
+		if account.IBAN == iban {
+			return account
+		}
+	}
+	return AccountConfig{}
+}
+
+// PayeeSource returns the payee sources of the account with iban
+func (c Config) PayeeSource(iban string) []string {
+	if source := c.account(iban).PayeeSource; len(source) > 0 {
+		return source
+	}
+	return c.Nordigen.PayeeSource
+}
+
+// Cleared returns the cleared status of new transactions on the account with
+// iban
+func (c Config) Cleared(iban string) string {
+	if cleared := c.account(iban).Cleared; cleared != "" {
+		return strings.ToLower(cleared)
+	}
+	return c.YNAB.Cleared
+}
+
+// SwapFlow reports whether inflow and outflow should be swapped for the
+// account with iban
+func (c Config) SwapFlow(iban string) bool {
+	if swap := c.account(iban).SwapFlow; swap != nil {
+		return *swap
+	}
+	return c.YNAB.SwapFlow.Match(iban)
+}
+
+// CategoryMap returns the category rules of the account with iban
+func (c Config) CategoryMap(iban string) CategoryRules {
+	if rules := c.account(iban).CategoryMap; len(rules) > 0 {
+		return rules
+	}
+	return c.YNAB.CategoryMap
+}
+
+// minImportIDHash is the fewest characters of the hash an import ID must have
+// after the prefix to keep collisions unlikely
+const minImportIDHash = 16
+
+// payeeSources is the valid options of Nordigen.PayeeSource
+var payeeSources = []string{"unstructured", "name", "ultimate", "account", "additional"}
+
+// transactionIDs is the valid options of Nordigen.TransactionID
+var transactionIDs = []string{"TransactionId", "InternalTransactionId"}
+
+// clearedStatuses is the valid options of YNAB.Cleared
+var clearedStatuses = []string{"cleared", "uncleared", "reconciled"}
+
+// stages returns the names of the readers and writers used by c, either
+// directly or by a pipeline
+func (c Config) stages() (readers, writers map[string]bool) {
+	readers, writers = map[string]bool{}, map[string]bool{}
+	if len(c.Pipelines) == 0 {
+		for _, name := range c.Readers {
+			readers[name] = true
+		}
+		for _, name := range c.Writers {
+			writers[name] = true
+		}
+	}
+	for _, p := range c.Pipelines {
+		for _, name := range p.Readers {
+			readers[name] = true
+		}
+		for _, name := range p.Writers {
+			writers[name] = true
+		}
+	}
+	return readers, writers
+}
+
+// Validate checks that the settings are valid and that the readers and
+// writers in use have the settings they require. All problems are returned
+// joined so they can be fixed at once.
+func (c Config) Validate() error {
+	var errs []error
+	required := func(value, name string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	oneOf := func(value, name string, valid []string) {
+		for _, v := range valid {
+			if value == v {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", name, strings.Join(valid, ", "), value))
+	}
+
+	if _, err := c.Logger(io.Discard); err != nil {
+		errs = append(errs, err)
+	}
+
+	readers, writers := c.stages()
+	if readers["nordigen"] {
+		required(c.Nordigen.BankID, "NORDIGEN_BANKID")
+		required(c.Nordigen.SecretID, "NORDIGEN_SECRET_ID")
+		required(c.Nordigen.SecretKey, "NORDIGEN_SECRET_KEY")
+		for _, source := range c.Nordigen.PayeeSource {
+			oneOf(source, "NORDIGEN_PAYEE_SOURCE", payeeSources)
+		}
+		oneOf(c.Nordigen.TransactionID, "NORDIGEN_TRANSACTION_ID", transactionIDs)
+		from, to := time.Time(c.Nordigen.DateFrom), time.Time(c.Nordigen.DateTo)
+		if !from.IsZero() && !to.IsZero() && from.After(to) {
+			errs = append(errs, fmt.Errorf("NORDIGEN_DATE_FROM must not be after NORDIGEN_DATE_TO"))
+		}
+		for _, account := range c.Accounts {
+			for _, source := range account.PayeeSource {
+				oneOf(source, fmt.Sprintf("YNABBER_ACCOUNTS payee_source of %s", account.IBAN), payeeSources)
+			}
+		}
+	}
+	if readers["csv"] {
+		required(c.CSV.InputDir, "CSV_INPUT_DIR")
+	}
+	if readers["ofx"] {
+		required(c.OFX.Dir, "OFX_DIR")
+	}
+	if readers["plaid"] {
+		required(c.Plaid.ClientID, "PLAID_CLIENT_ID")
+		required(c.Plaid.Secret, "PLAID_SECRET")
+		if len(c.Plaid.AccessTokens) == 0 {
+			errs = append(errs, fmt.Errorf("PLAID_ACCESS_TOKENS is required"))
+		}
+		oneOf(c.Plaid.Environment, "PLAID_ENVIRONMENT", []string{"sandbox", "development", "production"})
+	}
+
+	if writers["ynab"] {
+		required(c.YNAB.BudgetID, "YNAB_BUDGETID")
+		required(c.YNAB.Token, "YNAB_TOKEN")
+		if len(c.YNAB.AccountMap) == 0 {
+			errs = append(errs, fmt.Errorf("YNAB_ACCOUNTMAP is required"))
+		}
+		oneOf(c.YNAB.Cleared, "YNAB_CLEARED", clearedStatuses)
+		if n := utf8.RuneCountInString(c.YNAB.ImportIDPrefix) + 1 + minImportIDHash; c.YNAB.ImportIDLength > 0 && n > min(c.YNAB.ImportIDLength, 36) {
+			errs = append(errs, fmt.Errorf("YNAB_IMPORT_ID_PREFIX %q leaves less than %d characters of YNAB_IMPORT_ID_LENGTH for the hash", c.YNAB.ImportIDPrefix, minImportIDHash))
+		}
+		for _, account := range c.Accounts {
+			if account.Cleared != "" {
+				oneOf(c.Cleared(account.IBAN), fmt.Sprintf("YNABBER_ACCOUNTS cleared of %s", account.IBAN), clearedStatuses)
+			}
+		}
+	}
+	if writers["csv"] {
+		required(c.CSV.Path, "CSV_PATH")
+	}
+	if writers["sqlite"] {
+		required(c.SQLite.Path, "SQLITE_PATH")
+	} else if c.SQLite.Dedup {
+		errs = append(errs, fmt.Errorf("SQLITE_DEDUP requires the sqlite writer"))
+	}
+	if writers["actual"] {
+		required(c.Actual.URL, "ACTUAL_URL")
+		required(c.Actual.BudgetID, "ACTUAL_BUDGETID")
+	}
+	if writers["firefly"] {
+		required(c.Firefly.URL, "FIREFLY_URL")
+		required(c.Firefly.Token, "FIREFLY_TOKEN")
+	}
+	if writers["webhook"] {
+		required(c.Webhook.URL, "WEBHOOK_URL")
+	}
+	return errors.Join(errs...)
 }
 
 // Nordigen related settings
 type Nordigen struct {
-	// BankID is used to create requisition
+	// BankID is used to create requisition. Multiple banks are read by
+	// separating their IDs with commas, each bank gets its own requisition.
+	// For example: "NORDEA_NDEADKKK,DANSKEBANK_DABADKKK"
 	BankID string `envconfig:"NORDIGEN_BANKID"`
 
 	// SecretID is used to create requisition
@@ -65,17 +937,37 @@ type Nordigen struct {
 	SecretKey string `envconfig:"NORDIGEN_SECRET_KEY"`
 
 	// PayeeSource is a list of sources for Payee candidates, the first method
-	// that yields a result will be used. Valid options are: unstructured, name
-	// and additional.
+	// that yields a result will be used. Valid options are: unstructured,
+	// name, ultimate, account and additional.
 	//
 	//	* unstructured: uses the `RemittanceInformationUnstructured` field
 	//	* name: uses either the either `debtorName` or `creditorName` field
+	//	* ultimate: uses either the `ultimateDebtor` or `ultimateCreditor` field
+	//	* account: uses either the `debtorAccount` or `creditorAccount` IBAN
 	//	* additional: uses the `AdditionalInformation` field
-	PayeeSource []string `envconfig:"NORDIGEN_PAYEE_SOURCE" default:"unstructured,name,additional"`
+	//
+	// The debtor is preferred for inflow and the creditor for outflow.
+	PayeeSource List `envconfig:"NORDIGEN_PAYEE_SOURCE" default:"unstructured,name,additional"`
 
-	// PayeeStrip is a list of words to remove from Payee. For example:
-	// "foo,bar"
-	PayeeStrip []string `envconfig:"NORDIGEN_PAYEE_STRIP"`
+	// PayeeStrip is a list of strings removed from Payee in order after the
+	// payee source is chosen. Regular expressions go between slashes, use a
+	// JSON list for entries containing commas. The name non_alphanumeric
+	// applies the cleanup used for the unstructured source to any source.
+	// For example: "foo,bar,/^MobilePay\s+/,non_alphanumeric"
+	PayeeStrip PayeeStrip `envconfig:"NORDIGEN_PAYEE_STRIP"`
+
+	// RemittanceDateFormat is the layout of the date some banks put at the
+	// start of the remittance information, the earliest of it and the
+	// booking and value date is used as transaction date. The layout is in
+	// Go format and must be numeric. Defaults to "2006.01.02", for example
+	// "02/01/2006" for dd/mm/yyyy
+	RemittanceDateFormat string `envconfig:"NORDIGEN_REMITTANCE_DATE_FORMAT"`
+
+	// MemoTemplate builds the memo from the fields of the transaction as
+	// returned by Nordigen with Go text/template syntax, for example:
+	// '{{.CreditorName}} | {{.RemittanceInformationUnstructured}} | ref:{{.TransactionId}}'
+	// The memo is set by the mapper of the bank if empty.
+	MemoTemplate Template `envconfig:"NORDIGEN_MEMO_TEMPLATE"`
 
 	// TransactionID is the field to use as transaction ID. Not all banks use
 	// the same field and some even change the ID over time.
@@ -88,6 +980,17 @@ type Nordigen struct {
 	// <status> <link>
 	RequisitionHook string `envconfig:"NORDIGEN_REQUISITION_HOOK"`
 
+	// AuthTimeout is how long to wait for the user to authorize a new
+	// requisition before giving up, this keeps unattended runs from waiting
+	// forever. 0=wait indefinitely
+	AuthTimeout time.Duration `envconfig:"NORDIGEN_AUTH_TIMEOUT" default:"5m"`
+
+	// RedirectPort starts a local web server on the port for the bank to
+	// redirect to after authorizing a requisition, which picks up the
+	// requisition right away instead of waiting for the next poll. Only
+	// useful when authorizing in a browser on the same machine. 0=disabled
+	RedirectPort int `envconfig:"NORDIGEN_REDIRECT_PORT" default:"0"`
+
 	// RequisitionFile overrides the file used to store the requisition. This
 	// file is placed inside the YNABBER_DATADIR.
 	RequisitionFile string `envconfig:"NORDIGEN_REQUISITION_FILE"`
@@ -96,6 +999,66 @@ type Nordigen struct {
 	RequisitionFileStorage string `envconfig:"NORGIDEN_REQUISITION_FILE_STORAGE" default:"file"`
 
 	S3BucketName string `envconfig:"NORDIGEN_REQUISITION_S3_BUCKET_NAME"`
+
+	// AccountStatuses is a list of account statuses to read transactions
+	// from, accounts with any other status are skipped and reported to the
+	// notifiers. Valid statuses are: DISCOVERED, PROCESSING, READY, ERROR,
+	// SUSPENDED and EXPIRED.
+	AccountStatuses List `envconfig:"NORDIGEN_ACCOUNT_STATUSES" default:"READY"`
+
+	// MinorUnits is a list of IBANs for accounts where the bank reports
+	// amounts in minor units, i.e. cents, instead of a decimal amount in
+	// major units. For example: "DK9520000123456789,NO8330001234567"
+	MinorUnits List `envconfig:"NORDIGEN_MINOR_UNITS"`
+
+	// DebitPositive is the sign convention of banks reporting outflow as a
+	// positive amount instead of a negative one like the Berlin Group
+	// standard requires, the sign of all transactions is flipped. Use
+	// YNAB_SWAPFLOW to flip single accounts instead. A warning is logged
+	// when nearly all transactions of an account are inflow since that often
+	// means the sign is wrong.
+	DebitPositive bool `envconfig:"NORDIGEN_DEBIT_POSITIVE" default:"false"`
+
+	// DumpTransactions writes the transactions received from Nordigen to a
+	// timestamped JSON file per account inside the YNABBER_DATADIR. This is
+	// useful for debugging mappers but beware that the files contain
+	// sensitive data and are not redacted. The files hold the transactions
+	// as parsed by the Nordigen client library, not the raw response, since
+	// the library doesn't expose it. Fields the library doesn't know are
+	// missing.
+	DumpTransactions bool `envconfig:"NORDIGEN_DUMP_TRANSACTIONS" default:"false"`
+
+	// DateFrom and DateTo only keep transactions dated within the range,
+	// both inclusive. This is a filter applied after the transactions are
+	// fetched: the range isn't sent to Nordigen as date_from and date_to
+	// since the client library doesn't support them, so it doesn't save API
+	// calls or data. It only filters what Nordigen returns by default,
+	// usually the last 90 days, and can't reach further back. For example:
+	// "2023-01-01"
+	DateFrom Date `envconfig:"NORDIGEN_DATE_FROM"`
+	DateTo   Date `envconfig:"NORDIGEN_DATE_TO"`
+
+	// ExpectedCurrency maps IBAN to the currency of the account in JSON.
+	// Transactions in another currency are logged with a warning since the
+	// amount is imported without conversion. For example:
+	// '{"<IBAN>": "EUR"}'
+	ExpectedCurrency AccountMap `envconfig:"NORDIGEN_EXPECTED_CURRENCY"`
+
+	// SkipCurrencyMismatch skips transactions not in the currency of
+	// NORDIGEN_EXPECTED_CURRENCY instead of only warning about them
+	SkipCurrencyMismatch bool `envconfig:"NORDIGEN_SKIP_CURRENCY_MISMATCH" default:"false"`
+
+	// IncludePending reads pending transactions along with the booked ones.
+	// Pending transactions are imported as uncleared and can change or
+	// disappear before they are booked, set YNAB_UPDATE to update them in
+	// YNAB. Pending transactions without an ID are skipped until they are
+	// booked. Banks that give a pending transaction a new ID once it's
+	// booked will cause duplicates.
+	IncludePending bool `envconfig:"NORDIGEN_INCLUDE_PENDING" default:"false"`
+
+	// Balances reads the booked balance of each account along with the
+	// transactions, this is required by YNAB_RECONCILE_MATCHED
+	Balances bool `envconfig:"NORDIGEN_BALANCES" default:"false"`
 }
 
 // YNAB related settings
@@ -116,15 +1079,459 @@ type YNAB struct {
 	// example: 2006-01-02
 	FromDate Date `envconfig:"YNAB_FROM_DATE"`
 
+	// Backfill sends transactions older than five years. YNAB rejects them
+	// for most budgets and fails the whole request, so they are skipped
+	// unless set. Only set it for budgets that accept old transactions.
+	Backfill bool `envconfig:"YNAB_BACKFILL" default:"false"`
+
+	// SkipZero skips transactions with a zero amount, like authorizations or
+	// reversals some banks report. They are counted as skipped.
+	SkipZero bool `envconfig:"YNAB_SKIP_ZERO" default:"false"`
+
 	// Set cleared status, possible values: cleared, uncleared, reconciled .
 	// Default is uncleared for historical reasons but recommend setting this
 	// to cleared because ynabber transactions are cleared by bank.
 	// They'd still be unapproved until approved in YNAB.
 	Cleared string `envconfig:"YNAB_CLEARED" default:"uncleared"`
 
+	// Approved imports transactions as approved. Combined with YNAB_CLEARED
+	// set to cleared the transactions land in the register as final, without
+	// needing any action in YNAB. Uncleared and approved transactions are
+	// still matched against manually entered transactions, but the match
+	// isn't shown for review. Default is false so every import is reviewed.
+	Approved bool `envconfig:"YNAB_APPROVED" default:"false"`
+
 	// SwapFlow changes inflow to outflow and vice versa for any account with a
 	// IBAN number in the list. This maybe be relevant for credit card accounts.
+	// Besides exact IBANs an entry can be a prefix ending in * or a regular
+	// expression between slashes to match many accounts at once. Use a JSON
+	// list if a regular expression contains commas.
 	//
-	// Example: "DK9520000123456789,NO8330001234567"
-	SwapFlow []string `envconfig:"YNAB_SWAPFLOW"`
+	// Example: "DK9520000123456789,NO8330001234567,SE45*,/^FI.*01$/" or
+	// '["SE45*", "/^DK.{2,4}01$/"]'
+	SwapFlow AccountMatcher `envconfig:"YNAB_SWAPFLOW"`
+
+	// ImportIDLength is the length of the import ID, the longer the ID the
+	// less likely two transactions are to collide. YNAB allows up to 36
+	// characters. Changing this changes the import ID of all transactions, so
+	// transactions already imported will be imported again.
+	ImportIDLength int `envconfig:"YNAB_IMPORT_ID_LENGTH" default:"32"`
+
+	// ImportIDPrefix is prepended to the import ID to tell apart transactions
+	// imported by different instances of ynabber writing to the same budget.
+	// The prefix takes up room of the hash within ImportIDLength, so keep it
+	// short. Changing this changes the import ID of all transactions, so
+	// transactions already imported will be imported again.
+	ImportIDPrefix string `envconfig:"YNAB_IMPORT_ID_PREFIX" default:"YBBRTZ"`
+
+	// ImportIDSecondary keeps a secondary import ID without the amount for
+	// the transactions imported in the last 90 days, in the data dir or
+	// S3_BUCKET if set. A transaction read again with a corrected amount, for
+	// example when the bank books a pending transaction, is recognized by it
+	// instead of being imported as a new one. The imported transaction is
+	// only updated with the new amount if Update is set.
+	ImportIDSecondary bool `envconfig:"YNAB_IMPORT_ID_SECONDARY" default:"false"`
+
+	// ImportIDV3 switches transactions from this date and onward to version 3
+	// of the import ID, which hashes the payee instead of the transaction ID.
+	// Use it for banks that change the ID of a transaction between pending
+	// and booked. Transactions with the same payee, amount and date in the
+	// same account get the same import ID, so only the first is imported.
+	// Set it to a date after the last imported transaction to avoid
+	// importing them again. For example: 2024-06-01
+	ImportIDV3 Date `envconfig:"YNAB_IMPORT_ID_V3"`
+
+	// SplitRules turns a single transaction into subtransactions by parsing
+	// the amounts out of the memo, for example a net salary with the gross
+	// amount and deductions listed in the remittance information. A split is
+	// only made if the parts sum up to the amount of the transaction. For
+	// example:
+	// '[{"match": "^SALARY", "parts": "(?P<memo>GROSS|TAX) (?P<amount>-?[0-9.]+)"}]'
+	SplitRules SplitRules `envconfig:"YNAB_SPLIT_RULES"`
+
+	// NormalizePayee normalizes the payee to Unicode NFKC before it is sent
+	// to YNAB, so for example full-width letters and ligatures don't create
+	// new payees. Whitespace and non-printable characters, like zero-width
+	// spaces, are cleaned up regardless.
+	NormalizePayee bool `envconfig:"YNAB_NORMALIZE_PAYEE" default:"false"`
+
+	// PayeeMap renames payees matching a regular expression to keep the
+	// payee list in YNAB clean, as banks often add store numbers and
+	// references to the payee. The first matching rule wins and the rules
+	// are applied before CategoryMap and FlagRules. For example:
+	// '[{"match": "^AMZN", "payee": "Amazon"}, {"match": "^SQ \\*COFFEE", "payee": "Coffee Shop"}]'
+	PayeeMap PayeeRules `envconfig:"YNAB_PAYEE_MAP"`
+
+	// CategoryMap assigns a YNAB category to transactions by payee or by a
+	// regular expression matched against the memo. The payee is matched
+	// after any transformers ran. The first matching rule wins and
+	// transactions without a match are left uncategorized, as are split
+	// transactions. For example:
+	// '[{"payee": "Netflix", "category_id": "<id>"}, {"memo": "(?i)rent", "category_id": "<id>"}]'
+	CategoryMap CategoryRules `envconfig:"YNAB_CATEGORY_MAP"`
+
+	// CodeFlags maps bank transaction codes to a YNAB flag color. A code
+	// matches the exact code or its domain or family, for example
+	// PMNT-RCDT-ESCT matches PMNT-RCDT-ESCT, PMNT-RCDT and PMNT in that order
+	// of precedence. Valid colors are: red, orange, yellow, green, blue and
+	// purple. For example:
+	// '{"PMNT-ICDT": "blue", "PMNT-CCRD": "green"}'
+	CodeFlags FlagColorMap `envconfig:"YNAB_CODE_FLAGS"`
+
+	// InstitutionFlags maps the name of the institution holding the account
+	// to a YNAB flag color. Flags from CodeFlags take precedence. For
+	// example: '{"Nordea": "blue"}'
+	InstitutionFlags FlagColorMap `envconfig:"YNAB_INSTITUTION_FLAGS"`
+
+	// FlagRules flags transactions whose payee or memo matches a regular
+	// expression. The first matching rule wins and takes precedence over
+	// CodeFlags and InstitutionFlags. For example:
+	// '[{"match": "(?i)refund", "color": "orange"}]'
+	FlagRules FlagRules `envconfig:"YNAB_FLAG_RULES"`
+
+	// MemoInstitution appends the name of the institution holding the
+	// account to the memo. Useful to tell apart transactions when multiple
+	// banks feed the same budget.
+	MemoInstitution bool `envconfig:"YNAB_MEMO_INSTITUTION" default:"false"`
+
+	// MaxPayloadSize is the maximum size in bytes of a single request to
+	// YNAB. Transactions are split into multiple requests if the payload
+	// exceeds the size, 0=no limit
+	MaxPayloadSize int `envconfig:"YNAB_MAX_PAYLOAD_SIZE" default:"1048576"`
+
+	// BatchSize is the maximum number of transactions in a single request to
+	// YNAB. A failing request doesn't stop the rest from being sent. 0=no
+	// limit
+	BatchSize int `envconfig:"YNAB_BATCH_SIZE" default:"500"`
+
+	// ReconcileMatched imports transactions as cleared regardless of
+	// YNAB_CLEARED and marks them reconciled only if the cleared balance of
+	// the YNAB account matches the balance reported by the bank after the
+	// import. Requires a reader that reports balances, see
+	// NORDIGEN_BALANCES.
+	ReconcileMatched bool `envconfig:"YNAB_RECONCILE_MATCHED" default:"false"`
+
+	// Update overwrites the amount, payee and memo of transactions that are
+	// already imported with the ones read from the bank. Useful for banks
+	// that enrich a transaction after it books, but any edits made to those
+	// fields in YNAB are lost. Since the amount is part of the import ID a
+	// transaction with a new amount is matched by its secondary import ID,
+	// so Update implies ImportIDSecondary. Transactions imported before
+	// Update was set are only matched if their amount is unchanged.
+	Update bool `envconfig:"YNAB_UPDATE" default:"false"`
+
+	// SendOrder is the order transactions are sent to YNAB in. YNAB lists
+	// transactions on the same date in the order they were imported, so
+	// sending the oldest first makes the register read naturally. Valid
+	// options are: oldest, newest
+	SendOrder string `envconfig:"YNAB_SEND_ORDER" default:"oldest"`
+
+	// SyncMarkerAccount is the ID of a YNAB account that gets a zero amount
+	// marker transaction with the time of the last successful sync in the
+	// memo. The marker is updated on every successful run so only one
+	// exists. Disabled if empty.
+	SyncMarkerAccount string `envconfig:"YNAB_SYNC_MARKER_ACCOUNT"`
+
+	// MaxRetries is the number of times a request to YNAB is retried after a
+	// network error or a server error (5xx). Client errors (4xx) are never
+	// retried.
+	MaxRetries int `envconfig:"YNAB_MAX_RETRIES" default:"2"`
+
+	// RetryBackoff is the delay before the first retry, it doubles for each
+	// following retry
+	RetryBackoff time.Duration `envconfig:"YNAB_RETRY_BACKOFF" default:"2s"`
+
+	// Timeout is the time limit of a single request to YNAB including
+	// reading the response, 0=no limit
+	Timeout time.Duration `envconfig:"YNAB_TIMEOUT" default:"30s"`
+
+	// DryRun builds the transactions but doesn't send them to YNAB
+	DryRun bool `envconfig:"YNAB_DRY_RUN" default:"false"`
+
+	// DryRunReport is the file a dry run writes the transactions that would
+	// have been sent to. The file is placed inside the YNABBER_DATADIR and is
+	// written as CSV if it ends in .csv and as JSON otherwise. The report
+	// includes the transaction as read next to the payee, memo, import ID and
+	// cleared status sent to YNAB. For example: dry-run.csv
+	DryRunReport string `envconfig:"YNAB_DRY_RUN_REPORT"`
+
+	// Outbox keeps transactions that failed to be sent to YNAB in a file
+	// inside the YNABBER_DATADIR, or in S3_BUCKET if set, and sends them at
+	// the start of the next run before new transactions are read
+	Outbox bool `envconfig:"YNAB_OUTBOX" default:"false"`
+
+	// OutboxSize is the maximum number of transactions kept in the outbox,
+	// the oldest are dropped when exceeded
+	OutboxSize int `envconfig:"YNAB_OUTBOX_SIZE" default:"1000"`
+}
+
+// CSV related settings
+type CSV struct {
+	// InputDir is the directory to read CSV files from
+	InputDir string `envconfig:"CSV_INPUT_DIR"`
+
+	// Profiles describes the layout of the files from each bank by name.
+	// Each file in InputDir is read with the first profile, by name, whose
+	// glob matches the file name. For example:
+	// '{"nordea": {"glob": "nordea-*.csv", "account": "<IBAN>", "delimiter": ";",
+	// "date_layout": "02.01.2006", "decimal_separator": ",",
+	// "columns": {"date": "Bogføringsdato", "amount": "Beløb", "payee": "Navn"}}}'
+	Profiles CSVProfiles `envconfig:"CSV_PROFILES"`
+
+	// Path is the file the csv writer writes transactions to
+	Path string `envconfig:"CSV_PATH"`
+
+	// Append adds the transactions to the end of Path instead of replacing
+	// the file on every run. The header is only written to a new file and
+	// transactions whose import ID is already in the file are skipped.
+	Append bool `envconfig:"CSV_APPEND" default:"false"`
+}
+
+// OFX related settings
+type OFX struct {
+	// Dir is the directory to read OFX and QFX files from. Files are
+	// recorded as imported by the hash of their content in
+	// ofx_imported.json inside the YNABBER_DATADIR once all writers wrote
+	// them, so they aren't read again.
+	Dir string `envconfig:"OFX_DIR"`
+}
+
+// Plaid related settings
+type Plaid struct {
+	// ClientID and Secret of the Plaid API, see
+	// https://dashboard.plaid.com/developers/keys
+	ClientID string `envconfig:"PLAID_CLIENT_ID"`
+	Secret   string `envconfig:"PLAID_SECRET"`
+
+	// AccessTokens of the items, one per bank login, to read transactions
+	// from. The account IDs of Plaid are used in place of the IBAN, for
+	// example in YNAB_ACCOUNTMAP.
+	AccessTokens List `envconfig:"PLAID_ACCESS_TOKENS"`
+
+	// Environment of the Plaid API. Valid options are: sandbox,
+	// development, production
+	Environment string `envconfig:"PLAID_ENVIRONMENT" default:"production"`
+
+	// Days is how many days back to read transactions
+	Days int `envconfig:"PLAID_DAYS" default:"30"`
+
+	// IncludePending reads transactions that are not yet posted too
+	IncludePending bool `envconfig:"PLAID_INCLUDE_PENDING" default:"false"`
+}
+
+// JSON related settings
+type JSON struct {
+	// Path is the file the json writer writes transactions to, the parent
+	// directories are created if needed. New transactions are merged into
+	// the file, transactions already in it by account and ID are skipped.
+	// Transactions are written to stdout if empty.
+	Path string `envconfig:"JSON_PATH"`
+}
+
+// S3 related settings
+type S3 struct {
+	// Bucket to upload transactions to, if empty the transactions are written
+	// to a file inside the YNABBER_DATADIR instead. The YNAB writer keeps its
+	// outbox and import IDs in the bucket too. Credentials and region are
+	// read from the standard AWS environment variables.
+	Bucket string `envconfig:"S3_BUCKET"`
+
+	// Prefix is prepended to the timestamped object key. For example:
+	// "ynabber/"
+	Prefix string `envconfig:"S3_PREFIX"`
+
+	// ServerSideEncryption of the uploaded objects, either AES256 or aws:kms.
+	// Empty uses the default encryption of the bucket.
+	ServerSideEncryption string `envconfig:"S3_SERVER_SIDE_ENCRYPTION"`
+
+	// KMSKeyID is the KMS key used when ServerSideEncryption is aws:kms
+	KMSKeyID string `envconfig:"S3_KMS_KEY_ID"`
+}
+
+// SQLite related settings
+type SQLite struct {
+	// Path is the SQLite database the sqlite writer stores transactions in,
+	// it's created if it doesn't exist
+	Path string `envconfig:"SQLITE_PATH"`
+
+	// Dedup skips the transactions already in the database, by account IBAN
+	// and transaction ID, before they reach any writer of a pipeline with
+	// the sqlite writer. The sqlite writer then runs last and only stores
+	// the transactions if all other writers of the pipeline succeeded, so a
+	// transaction is skipped once it's written everywhere. Transactions a
+	// writer skips, like future transactions for YNAB, aren't stored and
+	// nothing is stored on dry runs. Changes the bank makes to a
+	// transaction after it's stored are skipped too, so it doesn't go with
+	// YNAB_UPDATE.
+	Dedup bool `envconfig:"SQLITE_DEDUP" default:"false"`
+}
+
+// Actual related settings
+type Actual struct {
+	// URL of the actual-http-api server in front of the Actual server, for
+	// example: http://localhost:5007
+	URL string `envconfig:"ACTUAL_URL"`
+
+	// APIKey of the actual-http-api server
+	APIKey string `envconfig:"ACTUAL_API_KEY"`
+
+	// BudgetID is the sync ID of the budget, found under advanced settings in
+	// Actual
+	BudgetID string `envconfig:"ACTUAL_BUDGETID"`
+
+	// EncryptionPassword of the budget if it's end-to-end encrypted
+	EncryptionPassword string `envconfig:"ACTUAL_ENCRYPTION_PASSWORD"`
+
+	// AccountMap of IBAN to Actual account IDs in JSON. For example:
+	// '{"<IBAN>": "<Actual Account ID>"}'
+	AccountMap AccountMap `envconfig:"ACTUAL_ACCOUNTMAP"`
+}
+
+// Firefly III related settings
+type Firefly struct {
+	// URL of the Firefly III instance, for example: https://firefly.example.com
+	URL string `envconfig:"FIREFLY_URL"`
+
+	// Token is a personal access token created under Options > Profile >
+	// OAuth in Firefly III
+	Token string `envconfig:"FIREFLY_TOKEN"`
+
+	// AccountMap of IBAN to Firefly III asset account IDs in JSON. For
+	// example: '{"<IBAN>": "<Firefly account ID>"}'
+	AccountMap AccountMap `envconfig:"FIREFLY_ACCOUNTMAP"`
+}
+
+// Webhook related settings
+type Webhook struct {
+	// URL the transactions are posted to as a JSON array
+	URL string `envconfig:"WEBHOOK_URL"`
+
+	// Token is sent as a bearer token in the Authorization header if set
+	Token string `envconfig:"WEBHOOK_TOKEN"`
+
+	// Headers are added to the request in JSON. For example:
+	// '{"X-Api-Key": "secret"}'
+	Headers HeaderMap `envconfig:"WEBHOOK_HEADERS"`
+
+	// Timeout of the request including reading the response
+	Timeout time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"30s"`
+}
+
+// Email related settings
+type Email struct {
+	// Host and Port of the SMTP server used to send mails
+	Host string `envconfig:"EMAIL_HOST"`
+	Port int    `envconfig:"EMAIL_PORT" default:"587"`
+
+	// Username and Password to authenticate with the SMTP server, no
+	// authentication is used without a username
+	Username string `envconfig:"EMAIL_USERNAME"`
+	Password string `envconfig:"EMAIL_PASSWORD"`
+
+	// From is the sender of the mails, defaults to the username
+	From string `envconfig:"EMAIL_FROM"`
+
+	// To is a list of recipients. For example: "me@example.com"
+	To List `envconfig:"EMAIL_TO"`
+
+	// Subject of the mails
+	Subject string `envconfig:"EMAIL_SUBJECT" default:"Ynabber"`
+}
+
+// Telegram related settings
+type Telegram struct {
+	// Token of the bot sending the messages, see
+	// https://core.telegram.org/bots#how-do-i-create-a-bot
+	Token string `envconfig:"TELEGRAM_TOKEN"`
+
+	// ChatID is the chat the messages are sent to, either the numeric ID or
+	// the @username of a channel
+	ChatID string `envconfig:"TELEGRAM_CHAT_ID"`
+}
+
+// Slack related settings
+type Slack struct {
+	// WebhookURL is the incoming webhook messages are posted to, see
+	// https://api.slack.com/messaging/webhooks
+	WebhookURL string `envconfig:"SLACK_WEBHOOK_URL"`
+}
+
+// Transform related settings
+type Transform struct {
+	// StripCardNumbers removes masked card numbers and the card terminal
+	// words in front of them from the payee, for example
+	// "POS 1234****5678 TESCO" becomes "TESCO"
+	StripCardNumbers bool `envconfig:"YNABBER_STRIP_CARD_NUMBERS" default:"false"`
+
+	// StripPayeeReferences removes trailing reference numbers from the payee
+	// so recurring merchants share one payee, for example "SPOTIFY 998213"
+	// becomes "SPOTIFY". By default references of at least four digits,
+	// optionally introduced by REF, NR or #, are removed.
+	StripPayeeReferences bool `envconfig:"YNABBER_STRIP_PAYEE_REFERENCES" default:"false"`
+
+	// PayeeReferencePattern replaces the default regular expression matching
+	// a reference at the end of the payee. For example: '\s+\d{6}$'
+	PayeeReferencePattern string `envconfig:"YNABBER_PAYEE_REFERENCE_PATTERN"`
+
+	// PayeeReferenceMemo appends the references stripped from the payee to
+	// the memo
+	PayeeReferenceMemo bool `envconfig:"YNABBER_PAYEE_REFERENCE_MEMO" default:"false"`
+
+	// ExcludeMemo drops transactions whose memo, i.e. the remittance
+	// information, contains any of the keywords regardless of case. Keywords
+	// are regular expressions. For example: "INTEREST,OWN TRANSFER"
+	ExcludeMemo List `envconfig:"YNABBER_EXCLUDE_MEMO"`
+
+	// ExcludeMemoWholeWord only matches ExcludeMemo keywords as whole words,
+	// i.e. INTEREST doesn't match "INTERESTING"
+	ExcludeMemoWholeWord bool `envconfig:"YNABBER_EXCLUDE_MEMO_WHOLE_WORD" default:"false"`
+
+	// CoalesceWindow merges transactions a merchant split into multiple card
+	// transactions. Transactions on the same account with the same payee and
+	// date made within the window of each other are merged into one with the
+	// amounts of the parts in the memo. Only transactions with a time of day
+	// are merged, and they are held back until the window has passed so all
+	// parts are written at once. 0=disabled
+	CoalesceWindow time.Duration `envconfig:"YNABBER_COALESCE_WINDOW" default:"0"`
+
+	// MemoTags appends a fixed tag to the memo of all transactions from an
+	// account, for example to mark whose transaction it is on a joint
+	// account. The memo is shortened to make room for the tag. For example:
+	// '{"<IBAN>": "[50/50]"}'
+	MemoTags AccountMap `envconfig:"YNABBER_MEMO_TAGS"`
+
+	// PayeeProcessors strips the prefix of common payment processors such as
+	// PayPal, Paddle, Square and Stripe from the payee to surface the
+	// underlying merchant. For example "PAYPAL *SPOTIFY" becomes "Spotify".
+	PayeeProcessors bool `envconfig:"YNABBER_PAYEE_PROCESSORS" default:"false"`
+
+	// PayeeProcessorMap adds processors to the ones above, it's applied even
+	// if PayeeProcessors is disabled. The map is from processor name to a
+	// regular expression matching its prefix, they are tried after the ones
+	// above in the order given. For example:
+	// '{"Zettle": "^ZETTLE_\\*"}'
+	PayeeProcessorMap PatternMap `envconfig:"YNABBER_PAYEE_PROCESSOR_MAP"`
+
+	// FutureTransactions decides what happens to transactions dated after
+	// today in the local time zone (TZ), valid options are:
+	//
+	//	* keep: passes the transaction on to the writers as is, the YNAB
+	//	  writer still leaves it out until its date has come
+	//	* skip: skips the transaction
+	//	* clamp: dates the transaction today, the YNAB import ID is still
+	//	  made from the date as read so it's imported once
+	FutureTransactions string `envconfig:"YNABBER_FUTURE_TRANSACTIONS" default:"keep"`
+
+	// PayeeFile is the path to a CSV file mapping raw payees to clean ones,
+	// one pair per line without a header. Lines starting with # are ignored.
+	// The file is read on every run so edits take effect without a restart.
+	// For example:
+	//
+	//	NETTO 1234,Netto
+	//	AMZN Mktp,Amazon
+	PayeeFile string `envconfig:"YNABBER_PAYEE_FILE"`
+
+	// PayeeFilePrefix also matches payees starting with a raw payee from
+	// PayeeFile. The longest match wins and exact matches take precedence.
+	PayeeFilePrefix bool `envconfig:"YNABBER_PAYEE_FILE_PREFIX" default:"false"`
 }
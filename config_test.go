@@ -1,8 +1,14 @@
 package ynabber
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/kelseyhightower/envconfig"
 )
 
 func TestDateDecode(t *testing.T) {
@@ -35,3 +41,397 @@ func TestDateDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitRulesDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			value:   `[{"match": "^SALARY", "parts": "(?P<amount>[0-9.]+)"}]`,
+			wantErr: false,
+		},
+		{
+			name:    "invalid regex",
+			value:   `[{"match": "(", "parts": "(?P<amount>[0-9.]+)"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing amount group",
+			value:   `[{"match": "^SALARY", "parts": "[0-9.]+"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules SplitRules
+			if err := rules.Decode(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("SplitRules.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCategoryRulesDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			value: `[{"payee": "Netflix", "category_id": "a"}, {"memo": "(?i)rent", "category_id": "b"}]`,
+		},
+		{
+			name:    "missing category",
+			value:   `[{"payee": "Netflix"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "payee and memo",
+			value:   `[{"payee": "Netflix", "memo": "rent", "category_id": "a"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			value:   `[{"memo": "(", "category_id": "a"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules CategoryRules
+			if err := rules.Decode(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("CategoryRules.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlagRulesDecode(t *testing.T) {
+	var rules FlagRules
+	if err := rules.Decode(`[{"match": "REFUND", "color": "orange"}]`); err != nil {
+		t.Errorf("valid rule: %s", err)
+	}
+	if err := rules.Decode(`[{"match": "REFUND", "color": "pink"}]`); err == nil {
+		t.Error("invalid color: expected error")
+	}
+	if err := rules.Decode(`[{"match": "(", "color": "orange"}]`); err == nil {
+		t.Error("invalid regex: expected error")
+	}
+}
+
+func TestPayeeRulesDecode(t *testing.T) {
+	var rules PayeeRules
+	if err := rules.Decode(`[{"match": "^AMZN", "payee": "Amazon"}]`); err != nil {
+		t.Errorf("valid rule: %s", err)
+	}
+	if err := rules.Decode(`[{"match": "^AMZN"}]`); err == nil {
+		t.Error("missing payee: expected error")
+	}
+	if err := rules.Decode(`[{"match": "(", "payee": "Amazon"}]`); err == nil {
+		t.Error("invalid regex: expected error")
+	}
+}
+
+func TestAccountMatcherDecode(t *testing.T) {
+	var m AccountMatcher
+	if err := m.Decode("DK95,NO83*,/^SE.*1$/"); err != nil {
+		t.Fatal(err)
+	}
+	for iban, want := range map[string]bool{"DK95": true, "DK951": false, "NO8312": true, "SE451": true, "SE450": false} {
+		if got := m.Match(iban); got != want {
+			t.Errorf("Match(%s) = %t, want %t", iban, got, want)
+		}
+	}
+	if err := m.Decode("/(/"); err == nil {
+		t.Error("invalid regex: expected error")
+	}
+
+	// Regular expressions with commas need a JSON list
+	if err := m.Decode("/^DK.{2,4}1$/"); err == nil {
+		t.Error("regex split by comma: expected error")
+	}
+	if err := m.Decode(`["NO83*", "/^DK.{2,4}1$/"]`); err != nil {
+		t.Fatal(err)
+	}
+	for iban, want := range map[string]bool{"DK121": true, "DK1": false, "NO831": true} {
+		if got := m.Match(iban); got != want {
+			t.Errorf("JSON list: Match(%s) = %t, want %t", iban, got, want)
+		}
+	}
+}
+
+func TestPatternMapDecode(t *testing.T) {
+	var patterns PatternMap
+	if err := patterns.Decode(`{"b": "^B", "a": "^A"}`); err != nil {
+		t.Fatal(err)
+	}
+	want := PatternMap{{Name: "b", Pattern: "^B"}, {Name: "a", Pattern: "^A"}}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Errorf("got %+v, want %+v", patterns, want)
+	}
+	for _, value := range []string{`["^A"]`, `{"a": 1}`, `{"a": "^A"`} {
+		if err := patterns.Decode(value); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
+
+func TestPayeeStripDecode(t *testing.T) {
+	var strip PayeeStrip
+	if err := strip.Decode(`not ,/^MobilePay\s+/,non_alphanumeric`); err != nil {
+		t.Fatal(err)
+	}
+	if len(strip) != 3 || strip[0].Literal != "not " || strip[1].Pattern == nil || !strip[2].NonAlphanumeric {
+		t.Errorf("got %+v", strip)
+	}
+	if err := strip.Decode(`["a,b", "/x{1,2}/"]`); err != nil {
+		t.Fatal(err)
+	}
+	if len(strip) != 2 || strip[0].Literal != "a,b" || strip[1].Pattern == nil {
+		t.Errorf("JSON list: got %+v", strip)
+	}
+	if err := strip.Decode("/(/"); err == nil {
+		t.Error("invalid regex: expected error")
+	}
+}
+
+func TestFlagColorMapDecode(t *testing.T) {
+	var flags FlagColorMap
+	if err := flags.Decode(`{"PMNT": "blue"}`); err != nil {
+		t.Errorf("valid color: %s", err)
+	}
+	if err := flags.Decode(`{"PMNT": "pink"}`); err == nil {
+		t.Error("invalid color: expected error")
+	}
+}
+
+func TestCSVProfilesDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "valid", value: `{"a": {"glob": "a-*.csv", "columns": {"date": "Date", "amount": "Amount"}}}`},
+		{name: "missing glob", value: `{"a": {"columns": {"date": "Date", "amount": "Amount"}}}`, wantErr: true},
+		{name: "invalid glob", value: `{"a": {"glob": "[", "columns": {"date": "Date", "amount": "Amount"}}}`, wantErr: true},
+		{name: "missing amount", value: `{"a": {"glob": "*", "columns": {"date": "Date"}}}`, wantErr: true},
+		{name: "debit and credit", value: `{"a": {"glob": "*", "columns": {"date": "Date", "debit": "Out", "credit": "In"}}}`},
+		{name: "missing date", value: `{"a": {"glob": "*", "columns": {"amount": "Amount"}}}`, wantErr: true},
+		{name: "amount and debit", value: `{"a": {"glob": "*", "columns": {"date": "Date", "amount": "Amount", "debit": "Out"}}}`, wantErr: true},
+		{name: "delimiter", value: `{"a": {"glob": "*", "delimiter": ";;", "columns": {"date": "Date", "amount": "Amount"}}}`, wantErr: true},
+		{name: "separator", value: `{"a": {"glob": "*", "decimal_separator": "'", "columns": {"date": "Date", "amount": "Amount"}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var profiles CSVProfiles
+			err := profiles.Decode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDurationMapDecode(t *testing.T) {
+	var durations DurationMap
+	if err := durations.Decode(`{"nordigen": "12h", "csv": "1m30s"}`); err != nil {
+		t.Fatal(err)
+	}
+	if durations["nordigen"] != 12*time.Hour || durations["csv"] != 90*time.Second {
+		t.Errorf("got %v", durations)
+	}
+	if err := durations.Decode(`{"nordigen": "daily"}`); err == nil {
+		t.Error("invalid duration: expected error")
+	}
+}
+
+func TestAccountName(t *testing.T) {
+	cfg := Config{AccountAliases: AccountMap{"DK9520000123456789": "Joint Checking"}}
+	if got := cfg.AccountName("DK9520000123456789"); got != "Joint Checking" {
+		t.Errorf("alias: got %s", got)
+	}
+	if got := cfg.AccountName("NO8330001234567"); got != "NO8330001234567" {
+		t.Errorf("no alias: got %s", got)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(`
+YNAB_BUDGETID: from-file
+YNAB_TOKEN: from-file
+ynabber_readers: [nordigen, csv]
+YNAB_SWAPFLOW: ["/^DK.{2,4}01$/"]
+YNAB_ACCOUNTMAP:
+  DK123: abc
+YNABBER_PIPELINES:
+  - readers: [csv]
+    writers: [json]
+YNAB_BATCH_SIZE: 100
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"YNAB_BUDGETID", "YNABBER_READERS", "YNAB_SWAPFLOW", "YNAB_ACCOUNTMAP", "YNABBER_PIPELINES", "YNAB_BATCH_SIZE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	// The environment overrides the file
+	t.Setenv("YNAB_TOKEN", "from-env")
+
+	if err := LoadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.YNAB.BudgetID != "from-file" || cfg.YNAB.Token != "from-env" {
+		t.Errorf("got budget %s and token %s", cfg.YNAB.BudgetID, cfg.YNAB.Token)
+	}
+	if want := (List{"nordigen", "csv"}); !reflect.DeepEqual(cfg.Readers, want) {
+		t.Errorf("got readers %v, want %v", cfg.Readers, want)
+	}
+	if !cfg.SwapFlow("DK12301") {
+		t.Error("want the swap flow expression with a comma to match")
+	}
+	if want := (AccountMap{"DK123": "abc"}); !reflect.DeepEqual(cfg.YNAB.AccountMap, want) {
+		t.Errorf("got account map %v, want %v", cfg.YNAB.AccountMap, want)
+	}
+	want := PipelineConfigs{{Readers: []string{"csv"}, Writers: []string{"json"}}}
+	if !reflect.DeepEqual(cfg.Pipelines, want) {
+		t.Errorf("got pipelines %+v, want %+v", cfg.Pipelines, want)
+	}
+	if cfg.YNAB.BatchSize != 100 {
+		t.Errorf("got batch size %d, want 100", cfg.YNAB.BatchSize)
+	}
+
+	if err := LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("want error for missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{
+		Readers: []string{"nordigen"},
+		Writers: []string{"ynab"},
+		Nordigen: Nordigen{
+			BankID:        "NORDEA_NDEADKKK",
+			SecretID:      "id",
+			SecretKey:     "key",
+			PayeeSource:   []string{"unstructured", "name"},
+			TransactionID: "TransactionId",
+		},
+		YNAB: YNAB{
+			BudgetID:   "budget",
+			Token:      "token",
+			AccountMap: AccountMap{"DK123": "abc"},
+			Cleared:    "uncleared",
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid config: %s", err)
+	}
+
+	invalid := valid
+	invalid.Nordigen.SecretKey = ""
+	invalid.Nordigen.PayeeSource = []string{"foo"}
+	invalid.YNAB.AccountMap = nil
+	invalid.YNAB.Cleared = "maybe"
+	invalid.YNAB.ImportIDLength = 32
+	invalid.YNAB.ImportIDPrefix = "HOUSEHOLD-BUDGET"
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("want error for invalid config")
+	}
+	for _, want := range []string{"NORDIGEN_SECRET_KEY", "NORDIGEN_PAYEE_SOURCE", "YNAB_ACCOUNTMAP", "YNAB_CLEARED", "YNAB_IMPORT_ID_PREFIX"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+
+	// The prefix is counted in characters like the import ID is cut
+	prefix := valid
+	prefix.YNAB.ImportIDLength = 32
+	prefix.YNAB.ImportIDPrefix = strings.Repeat("Ø", 15)
+	if err := prefix.Validate(); err != nil {
+		t.Errorf("multibyte prefix: %s", err)
+	}
+
+	dedup := valid
+	dedup.SQLite.Dedup = true
+	if err := dedup.Validate(); err == nil || !strings.Contains(err.Error(), "SQLITE_DEDUP") {
+		t.Errorf("got %v, want SQLITE_DEDUP to require the sqlite writer", err)
+	}
+
+	dates := valid
+	dates.Nordigen.DateFrom = Date(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC))
+	dates.Nordigen.DateTo = Date(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := dates.Validate(); err == nil || !strings.Contains(err.Error(), "NORDIGEN_DATE_FROM") {
+		t.Errorf("got %v, want NORDIGEN_DATE_FROM after NORDIGEN_DATE_TO rejected", err)
+	}
+
+	// Only the stages in use are checked
+	pipelines := Config{
+		Readers:   []string{"nordigen"},
+		Writers:   []string{"ynab"},
+		Pipelines: PipelineConfigs{{Readers: []string{"csv"}, Writers: []string{"json", "webhook"}}},
+		CSV:       CSV{InputDir: "in"},
+	}
+	err = pipelines.Validate()
+	if err == nil || !strings.Contains(err.Error(), "WEBHOOK_URL") || strings.Contains(err.Error(), "YNAB") {
+		t.Errorf("got %v, want only WEBHOOK_URL to be required", err)
+	}
+}
+
+func TestAccountOverrides(t *testing.T) {
+	cfg := Config{
+		Nordigen: Nordigen{PayeeSource: []string{"unstructured"}},
+		YNAB:     YNAB{Cleared: "uncleared"},
+	}
+	if err := cfg.YNAB.SwapFlow.Decode("DK1*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.YNAB.CategoryMap.Decode(`[{"payee": "Netflix", "category_id": "global"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Accounts.Decode(`[
+		{"iban": "DK1", "payee_source": ["name"], "cleared": "Cleared", "swap_flow": false},
+		{"iban": "DK2", "swap_flow": true, "category_map": [{"payee": "Netflix", "category_id": "account"}]}
+	]`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		iban        string
+		payeeSource []string
+		cleared     string
+		swapFlow    bool
+		category    string
+	}{
+		{iban: "DK1", payeeSource: []string{"name"}, cleared: "cleared", swapFlow: false, category: "global"},
+		{iban: "DK2", payeeSource: []string{"unstructured"}, cleared: "uncleared", swapFlow: true, category: "account"},
+		{iban: "DK10", payeeSource: []string{"unstructured"}, cleared: "uncleared", swapFlow: true, category: "global"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iban, func(t *testing.T) {
+			if got := cfg.PayeeSource(tt.iban); !reflect.DeepEqual(got, tt.payeeSource) {
+				t.Errorf("PayeeSource() = %v, want %v", got, tt.payeeSource)
+			}
+			if got := cfg.Cleared(tt.iban); got != tt.cleared {
+				t.Errorf("Cleared() = %s, want %s", got, tt.cleared)
+			}
+			if got := cfg.SwapFlow(tt.iban); got != tt.swapFlow {
+				t.Errorf("SwapFlow() = %t, want %t", got, tt.swapFlow)
+			}
+			if got := cfg.CategoryMap(tt.iban)[0].CategoryID; got != tt.category {
+				t.Errorf("CategoryMap() = %s, want %s", got, tt.category)
+			}
+		})
+	}
+}
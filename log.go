@@ -0,0 +1,32 @@
+package ynabber
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Logger returns a logger writing to w with the level and format of c. Debug
+// lowers the level to debug regardless of LogLevel.
+func (c Config) Logger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if c.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			return nil, fmt.Errorf("YNABBER_LOG_LEVEL must be one of debug, info, warn, error, got %q", c.LogLevel)
+		}
+	}
+	if c.Debug {
+		level = slog.LevelDebug
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(c.LogFormat) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("YNABBER_LOG_FORMAT must be one of text, json, got %q", c.LogFormat)
+	}
+}
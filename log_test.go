@@ -0,0 +1,41 @@
+package ynabber
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := Config{LogLevel: "warn"}.Logger(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "count", 2)
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "msg=shown count=2") {
+		t.Errorf("got text log %q", got)
+	}
+
+	buf.Reset()
+	logger, err = Config{LogLevel: "error", LogFormat: "JSON", Debug: true}.Logger(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("shown")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("got invalid json log %q: %s", buf.String(), err)
+	}
+	if entry["msg"] != "shown" || entry["level"] != "DEBUG" {
+		t.Errorf("got json log %v", entry)
+	}
+
+	for _, cfg := range []Config{{LogLevel: "loud"}, {LogFormat: "xml"}} {
+		if _, err := cfg.Logger(&buf); err == nil {
+			t.Errorf("want error for %+v", cfg)
+		}
+	}
+}
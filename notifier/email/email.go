@@ -0,0 +1,66 @@
+package email
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// sendMail sends the mail, tests replace it to avoid a SMTP server
+var sendMail = smtp.SendMail
+
+// Notifier sends messages by email through a SMTP server
+type Notifier struct {
+	Config *ynabber.Config
+}
+
+// NewNotifier returns a new email notifier or an error if the server or the
+// recipients are missing
+func NewNotifier(cfg *ynabber.Config) (Notifier, error) {
+	if cfg.Email.Host == "" || len(cfg.Email.To) == 0 {
+		return Notifier{}, fmt.Errorf("EMAIL_HOST and EMAIL_TO must be set")
+	}
+	return Notifier{Config: cfg}, nil
+}
+
+// message returns message as a mail with the configured headers
+func (n Notifier) message(message string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.Config.Email.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.Config.Email.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// from returns the sender, which defaults to the username
+func (n Notifier) from() string {
+	if n.Config.Email.From != "" {
+		return n.Config.Email.From
+	}
+	return n.Config.Email.Username
+}
+
+// Notify sends message to the configured recipients
+func (n Notifier) Notify(message string) error {
+	var auth smtp.Auth
+	if n.Config.Email.Username != "" {
+		auth = smtp.PlainAuth("", n.Config.Email.Username, n.Config.Email.Password, n.Config.Email.Host)
+	}
+	addr := net.JoinHostPort(n.Config.Email.Host, strconv.Itoa(n.Config.Email.Port))
+	err := sendMail(addr, auth, n.from(), n.Config.Email.To, n.message(message))
+	if err != nil {
+		return fmt.Errorf("sending mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package email
+
+import (
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestNotify(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	cfg := ynabber.Config{Email: ynabber.Email{
+		Host:     "smtp.example.com",
+		Port:     587,
+		Username: "ynabber@example.com",
+		To:       []string{"me@example.com", "you@example.com"},
+		Subject:  "Ynabber",
+	}}
+	n, err := NewNotifier(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify("Initiate requisition by going to: https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotAddr != "smtp.example.com:587" || gotFrom != "ynabber@example.com" {
+		t.Errorf("got addr %s and from %s", gotAddr, gotFrom)
+	}
+	if !reflect.DeepEqual(gotTo, []string(cfg.Email.To)) {
+		t.Errorf("got to %v, want %v", gotTo, cfg.Email.To)
+	}
+	msg := string(gotMsg)
+	for _, want := range []string{
+		"To: me@example.com, you@example.com\r\n",
+		"Subject: Ynabber\r\n",
+		"\r\n\r\nInitiate requisition by going to: https://example.com\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q doesn't contain %q", msg, want)
+		}
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	if _, err := NewNotifier(&ynabber.Config{}); err == nil {
+		t.Error("want error without host and recipients")
+	}
+}
@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Notifier posts messages to a Slack incoming webhook
+type Notifier struct {
+	Config *ynabber.Config
+}
+
+// NewNotifier returns a new Slack notifier or an error if the webhook is
+// missing
+func NewNotifier(cfg *ynabber.Config) (Notifier, error) {
+	if cfg.Slack.WebhookURL == "" {
+		return Notifier{}, fmt.Errorf("SLACK_WEBHOOK_URL is not set")
+	}
+	return Notifier{Config: cfg}, nil
+}
+
+// Notify posts message to the webhook, any status outside 2xx is an error
+func (n Notifier) Notify(message string) error {
+	payload, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: message})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Post(n.Config.Slack.WebhookURL, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		// The error contains the URL which is a secret
+		return fmt.Errorf("failed to post message")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("failed to post message: %s: %s", res.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestNotify(t *testing.T) {
+	var got struct {
+		Text string `json:"text"`
+	}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier(&ynabber.Config{Slack: ynabber.Slack{WebhookURL: server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "hello" {
+		t.Errorf("got text %q, want hello", got.Text)
+	}
+
+	status = http.StatusNotFound
+	if err := n.Notify("hello"); err == nil {
+		t.Error("want error for non-2xx status")
+	}
+
+	if _, err := NewNotifier(&ynabber.Config{}); err == nil {
+		t.Error("want error without webhook")
+	}
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// apiURL is the Telegram Bot API, tests replace it with a local server
+var apiURL = "https://api.telegram.org"
+
+// Notifier sends messages to a Telegram chat through a bot
+type Notifier struct {
+	Config *ynabber.Config
+}
+
+// Notify sends message to the configured chat. A notifier without a token or
+// chat returns an error without sending anything.
+func (n Notifier) Notify(message string) error {
+	if n.Config.Telegram.Token == "" || n.Config.Telegram.ChatID == "" {
+		return fmt.Errorf("TELEGRAM_TOKEN and TELEGRAM_CHAT_ID must be set")
+	}
+
+	payload, err := json.Marshal(struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}{
+		ChatID: n.Config.Telegram.ChatID,
+		Text:   message,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", apiURL, n.Config.Telegram.Token)
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		// The error contains the URL and thereby the token
+		return fmt.Errorf("failed to send message")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("failed to send message: %s: %s", res.Status, body)
+	}
+	return nil
+}
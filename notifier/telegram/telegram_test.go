@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestNotify(t *testing.T) {
+	var path string
+	var got struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.ChatID != "@me" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	apiURL = server.URL
+	defer func() { apiURL = "https://api.telegram.org" }()
+
+	cfg := ynabber.Config{Telegram: ynabber.Telegram{Token: "token", ChatID: "@me"}}
+	n := Notifier{Config: &cfg}
+	if err := n.Notify("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/bottoken/sendMessage" || got.Text != "hello" {
+		t.Errorf("got path %s and text %q", path, got.Text)
+	}
+
+	cfg.Telegram.ChatID = "@someone"
+	if err := n.Notify("hello"); err == nil {
+		t.Error("want error for rejected message")
+	}
+
+	cfg.Telegram.Token = ""
+	if err := n.Notify("hello"); err == nil {
+		t.Error("want error without token")
+	}
+}
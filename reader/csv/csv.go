@@ -0,0 +1,209 @@
+package csv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Reader reads transactions from the CSV files in a directory
+type Reader struct {
+	Config *ynabber.Config
+}
+
+// profile returns the name and profile of the first profile, by name, whose
+// glob matches file
+func (r Reader) profile(file string) (string, ynabber.CSVProfile, bool) {
+	names := make([]string, 0, len(r.Config.CSV.Profiles))
+	for name := range r.Config.CSV.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		profile := r.Config.CSV.Profiles[name]
+		if ok, _ := filepath.Match(profile.Glob, file); ok {
+			return name, profile, true
+		}
+	}
+	return "", ynabber.CSVProfile{}, false
+}
+
+// parseAmount parses s using the decimal separator of profile, the other
+// separator is assumed to group thousands. The amount is exact since it
+// doesn't go through a float.
+func parseAmount(profile ynabber.CSVProfile, s string) (ynabber.Milliunits, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if profile.DecimalSeparator == "," {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	return ynabber.MilliunitsFromString(s)
+}
+
+// recordAmount returns the amount of a row from the amount column, or from the
+// debit and credit columns if the profile has no amount column. The debit is
+// outflow regardless of its sign and empty debit or credit cells are zero.
+func recordAmount(profile ynabber.CSVProfile, amount, debit, credit string) (ynabber.Milliunits, error) {
+	if profile.Columns["amount"] != "" {
+		return parseAmount(profile, amount)
+	}
+
+	var total ynabber.Milliunits
+	if debit != "" {
+		x, err := parseAmount(profile, debit)
+		if err != nil {
+			return 0, fmt.Errorf("debit: %w", err)
+		}
+		total -= max(x, x.Negate())
+	}
+	if credit != "" {
+		x, err := parseAmount(profile, credit)
+		if err != nil {
+			return 0, fmt.Errorf("credit: %w", err)
+		}
+		total += x
+	}
+	return total, nil
+}
+
+// id returns an ID for a row without an ID column. Identical rows in a file
+// are told apart by the number of times the row has been seen before.
+func id(record []string, seen map[string]int) ynabber.ID {
+	row := strings.Join(record, "\x00")
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", row, seen[row])))
+	seen[row]++
+	return ynabber.ID(fmt.Sprintf("%x", hash[:16]))
+}
+
+// parse reads the transactions from f using profile
+func parse(profile ynabber.CSVProfile, f io.Reader) ([]ynabber.Transaction, error) {
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	if profile.Delimiter != "" {
+		reader.Comma = []rune(profile.Delimiter)[0]
+	}
+	layout := profile.DateLayout
+	if layout == "" {
+		layout = ynabber.DateFormat
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for field, name := range profile.Columns {
+		index[field] = -1
+		for i, column := range header {
+			if strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")) == name {
+				index[field] = i
+			}
+		}
+		if index[field] == -1 {
+			return nil, fmt.Errorf("missing column: %s", name)
+		}
+	}
+	column := func(record []string, field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var t []ynabber.Transaction
+	seen := map[string]int{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		date, err := time.ParseInLocation(layout, column(record, "date"), time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		amount, err := recordAmount(profile, column(record, "amount"), column(record, "debit"), column(record, "credit"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		account := column(record, "account")
+		if account == "" {
+			account = profile.Account
+		}
+		transactionID := ynabber.ID(column(record, "id"))
+		if transactionID == "" {
+			transactionID = id(record, seen)
+		}
+
+		t = append(t, ynabber.Transaction{
+			Account: ynabber.Account{
+				ID:   ynabber.ID(account),
+				Name: account,
+				IBAN: account,
+			},
+			ID:     transactionID,
+			Date:   date,
+			Time:   date,
+			Payee:  ynabber.Payee(column(record, "payee")),
+			Memo:   column(record, "memo"),
+			Amount: amount,
+		})
+	}
+	return t, nil
+}
+
+// Bulk reads the transactions from all files in the input dir that match a
+// profile
+func (r Reader) Bulk(ctx context.Context) (t []ynabber.Transaction, err error) {
+	entries, err := os.ReadDir(r.Config.CSV.InputDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading input dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name, profile, ok := r.profile(entry.Name())
+		if !ok {
+			slog.Info("Skipping file, no profile matches", "file", entry.Name())
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(r.Config.CSV.InputDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		x, err := parse(profile, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", entry.Name(), err)
+		}
+		for i := range x {
+			x[i].Account.Name = r.Config.AccountName(x[i].Account.IBAN)
+		}
+
+		slog.Info("Read transactions", "count", len(x), "file", entry.Name(), "profile", name)
+		ynabber.Sort(x)
+		t = append(t, x...)
+	}
+	return t, nil
+}
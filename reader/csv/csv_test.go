@@ -0,0 +1,151 @@
+package csv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestBulkProfiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"nordea-2023.csv": "Bogføringsdato;Beløb;Navn;Tekst\n" +
+			"03.11.2023;-1.234,50;Netto;Groceries\n" +
+			"04.11.2023;100,00;Salary;\n",
+		"revolut-nov.csv": "Completed Date,Amount,Description,ID,Account\n" +
+			"2023-11-05,\"-1,000.25\",Spotify,tx1,LT123\n",
+		"notes.txt": "not a transaction",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cfg ynabber.Config
+	cfg.CSV.InputDir = dir
+	err := cfg.CSV.Profiles.Decode(`{
+		"nordea": {"glob": "nordea-*.csv", "account": "DK123", "delimiter": ";",
+			"date_layout": "02.01.2006", "decimal_separator": ",",
+			"columns": {"date": "Bogføringsdato", "amount": "Beløb", "payee": "Navn", "memo": "Tekst"}},
+		"revolut": {"glob": "revolut-*.csv",
+			"columns": {"date": "Completed Date", "amount": "Amount", "payee": "Description", "id": "ID", "account": "Account"}}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AccountAliases = ynabber.AccountMap{"DK123": "Nordea"}
+
+	got, err := Reader{Config: &cfg}.Bulk(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(got))
+	}
+
+	want := []struct {
+		account string
+		name    string
+		date    time.Time
+		payee   ynabber.Payee
+		memo    string
+		amount  ynabber.Milliunits
+	}{
+		{account: "DK123", name: "Nordea", date: time.Date(2023, 11, 3, 0, 0, 0, 0, time.UTC), payee: "Netto", memo: "Groceries", amount: -1234500},
+		{account: "DK123", name: "Nordea", date: time.Date(2023, 11, 4, 0, 0, 0, 0, time.UTC), payee: "Salary", amount: 100000},
+		{account: "LT123", name: "LT123", date: time.Date(2023, 11, 5, 0, 0, 0, 0, time.UTC), payee: "Spotify", amount: -1000250},
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Account.IBAN != w.account || g.Account.Name != w.name || !g.Date.Equal(w.date) ||
+			g.Payee != w.payee || g.Memo != w.memo || g.Amount != w.amount {
+			t.Errorf("transaction %d: got %+v, want %+v", i, g, w)
+		}
+	}
+	if got[2].ID != "tx1" {
+		t.Errorf("got ID %s, want tx1", got[2].ID)
+	}
+	if got[0].ID == "" || got[0].ID == got[1].ID {
+		t.Errorf("want distinct generated IDs, got %s and %s", got[0].ID, got[1].ID)
+	}
+}
+
+func TestID(t *testing.T) {
+	seen := map[string]int{}
+	record := []string{"2023-11-03", "-10.00", "Netto"}
+	first := id(record, seen)
+	second := id(record, seen)
+	if first == second {
+		t.Error("identical rows got the same ID")
+	}
+	if again := id(record, map[string]int{}); again != first {
+		t.Errorf("ID is not stable: got %s, want %s", again, first)
+	}
+}
+
+func TestParseMissingColumn(t *testing.T) {
+	profile := ynabber.CSVProfile{Columns: map[string]string{"date": "Date", "amount": "Amount"}}
+	_, err := parse(profile, strings.NewReader("Date,Value\n2023-11-03,1.00\n"))
+	if err == nil {
+		t.Error("want error for missing column")
+	}
+}
+
+func TestParseDebitCredit(t *testing.T) {
+	profile := ynabber.CSVProfile{
+		Delimiter:        ";",
+		DecimalSeparator: ",",
+		Columns:          map[string]string{"date": "Datum", "debit": "Soll", "credit": "Haben", "payee": "Empfänger"},
+	}
+	got, err := parse(profile, strings.NewReader("Datum;Soll;Haben;Empfänger\n"+
+		"2023-11-03;12,50;;REWE\n"+
+		"2023-11-04;-1.000,00;;Miete\n"+
+		"2023-11-05;;2.500,75;Gehalt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ynabber.Milliunits{-12500, -1000000, 2500750}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(got), len(want))
+	}
+	for i, amount := range want {
+		if got[i].Amount != amount {
+			t.Errorf("transaction %d: got %s, want %s", i, got[i].Amount, amount)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		separator string
+		s         string
+		want      ynabber.Milliunits
+	}{
+		{separator: ".", s: "-1,234.56", want: -1234560},
+		{separator: ",", s: "1.234,56", want: 1234560},
+		{separator: ",", s: " 1 234,5 ", want: 1234500},
+		// A float would round this down
+		{separator: ".", s: "1.0005", want: 1001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseAmount(ynabber.CSVProfile{DecimalSeparator: tt.separator}, tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseAmount(ynabber.CSVProfile{}, "abc"); err == nil {
+		t.Error("want error for invalid amount")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"io"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
@@ -22,10 +24,13 @@ const RequisitionRedirect = "https://raw.githubusercontent.com/martinohansen/yna
 
 // requisitionStore returns a clean path to the requisition file
 func (r Reader) requisitionStore() string {
-	// Use BankID or RequisitionFile as filename
+	// Use BankID or RequisitionFile as filename, the bank is added to
+	// RequisitionFile when reading multiple banks to keep the files apart
 	var file string
 	if r.Config.Nordigen.RequisitionFile == "" {
-		file = r.Config.Nordigen.BankID
+		file = r.bankID()
+	} else if len(r.banks()) > 1 {
+		file = fmt.Sprintf("%s-%s", r.Config.Nordigen.RequisitionFile, r.bankID())
 	} else {
 		file = r.Config.Nordigen.RequisitionFile
 	}
@@ -34,40 +39,41 @@ func (r Reader) requisitionStore() string {
 }
 
 func (r Reader) DownloadFile(bucketName string, objectKey string) ([]byte, error) {
-	log.Printf("Reading requisition file from S3 bucket: %s, objectKey: %s", bucketName, objectKey)
+	slog.Info("Reading requisition file from S3", "bucket", bucketName, "key", objectKey)
 	result, err := r.S3Client.GetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
 	})
 	if err != nil {
-		log.Printf("Couldn't get object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		slog.Error("Failed to get requisition file from S3", "bucket", bucketName, "key", objectKey, "error", err)
 		return nil, err
 	}
 	defer result.Body.Close()
 	body, err := io.ReadAll(result.Body)
 	if err != nil {
-		log.Printf("Couldn't read object body from %v. Here's why: %v\n", objectKey, err)
+		slog.Error("Failed to read requisition file from S3", "key", objectKey, "error", err)
 	}
 	return body, nil
 }
 
 func (r Reader) RequisitionFile() ([]byte, error) {
 	if r.Config.Nordigen.RequisitionFileStorage == "s3" {
-		return r.DownloadFile(r.Config.Nordigen.S3BucketName, r.Config.Nordigen.BankID)
+		return r.DownloadFile(r.Config.Nordigen.S3BucketName, r.bankID())
 	} else {
-		log.Printf("Reading requisition file from OS file system")
+		slog.Info("Reading requisition file", "file", r.requisitionStore())
 		return os.ReadFile(r.requisitionStore())
 	}
 }
 
 // Requisition tries to get requisition from disk, if it fails it will create a
-// new and store that one to disk.
-func (r Reader) Requisition() (nordigen.Requisition, error) {
+// new and store that one to disk. Creating a requisition waits for the user to
+// authorize it until ctx is done or Nordigen.AuthTimeout passes.
+func (r Reader) Requisition(ctx context.Context) (nordigen.Requisition, error) {
 	requisitionFile, err := r.RequisitionFile()
 
 	if errors.Is(err, os.ErrNotExist) {
-		log.Print("Requisition is not found")
-		return r.createRequisition()
+		slog.Info("Requisition is not found", "bank", r.bankID())
+		return r.createRequisition(ctx, "")
 	} else if err != nil {
 		return nordigen.Requisition{}, fmt.Errorf("ReadFile: %w", err)
 	}
@@ -75,22 +81,55 @@ func (r Reader) Requisition() (nordigen.Requisition, error) {
 	var requisition nordigen.Requisition
 	err = json.Unmarshal(requisitionFile, &requisition)
 	if err != nil {
-		log.Print("Failed to parse requisition file")
-		return r.createRequisition()
+		slog.Warn("Failed to parse requisition file", "error", err)
+		return r.createRequisition(ctx, "")
 	}
 
+	// The stored status is from when the requisition was linked, so get the
+	// current status to catch requisitions that expired since
+	current, err := r.Client.GetRequisition(requisition.Id)
+	if notFound(err) {
+		slog.Info("Requisition no longer exists, re-authorization is needed", "bank", r.bankID())
+		r.removeRequisition()
+		return r.createRequisition(ctx, "the requisition no longer exists")
+	} else if err != nil {
+		return nordigen.Requisition{}, fmt.Errorf("GetRequisition: %w", err)
+	}
+	requisition = current
+
 	switch requisition.Status {
 	case "EX":
 		// Create a new requisition if expired
-		log.Printf("Requisition is expired")
-		return r.createRequisition()
+		slog.Info("Requisition is expired, re-authorization is needed", "bank", r.bankID())
+		r.removeRequisition()
+		return r.createRequisition(ctx, "the requisition is expired")
 	case "LN":
 		// Return requisition if it's still valid
 		return requisition, nil
 	default:
 		// Handle unknown status by recreating requisition
-		log.Printf("Unsupported requisition status: %s", requisition.Status)
-		return r.createRequisition()
+		slog.Info("Unsupported requisition status, re-authorization is needed", "bank", r.bankID(), "status", requisition.Status)
+		r.removeRequisition()
+		return r.createRequisition(ctx, fmt.Sprintf("the requisition has status %s", requisition.Status))
+	}
+}
+
+// notFound reports whether err is Nordigen reporting that the requested
+// resource doesn't exist, for example a deleted requisition
+func notFound(err error) bool {
+	var apiErr *nordigen.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// removeRequisition deletes the stored requisition so a stale one isn't
+// reused if creating a new one fails
+func (r Reader) removeRequisition() {
+	if r.Config.Nordigen.RequisitionFileStorage == "s3" {
+		return
+	}
+	err := os.Remove(r.requisitionStore())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Failed to remove requisition file", "error", err)
 	}
 }
 
@@ -107,45 +146,128 @@ func (r Reader) saveRequisition(requisition nordigen.Requisition) error {
 	return nil
 }
 
-func (r Reader) createRequisition() (nordigen.Requisition, error) {
+// createRequisition creates a new requisition and waits for the user to
+// authorize it. The user is notified with the link, reason tells why an
+// existing authorization has to be renewed and is empty for a new one.
+func (r Reader) createRequisition(ctx context.Context, reason string) (nordigen.Requisition, error) {
+	// Listen for the redirect from the bank if possible to pick up the
+	// requisition right away, polling is still used as a fallback
+	redirect := RequisitionRedirect
+	var callback <-chan struct{}
+	if port := r.Config.Nordigen.RedirectPort; port != 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+		if err != nil {
+			slog.Warn("Failed to listen for redirect, polling instead", "error", err)
+		} else {
+			server, c := serveRedirect(listener)
+			defer server.Close()
+			redirect = fmt.Sprintf("http://localhost:%d/", port)
+			callback = c
+		}
+	}
+
 	requisition, err := r.Client.CreateRequisition(nordigen.Requisition{
-		Redirect:      RequisitionRedirect,
+		Redirect:      redirect,
 		Reference:     strconv.Itoa(int(time.Now().Unix())),
 		Agreement:     "",
-		InstitutionId: r.Config.Nordigen.BankID,
+		InstitutionId: r.bankID(),
 	})
 	if err != nil {
 		return nordigen.Requisition{}, fmt.Errorf("CreateRequisition: %w", err)
 	}
 
 	r.requisitionHook(requisition)
-	log.Printf("Initiate requisition by going to: %s", requisition.Link)
+	slog.Info("Initiate requisition by going to the link", "bank", r.bankID(), "link", requisition.Link)
+	r.notify(authMessage(r.bankID(), reason, requisition.Link))
 
 	// Keep waiting for the user to accept the requisition
-	for requisition.Status != "LN" {
-		requisition, err = r.Client.GetRequisition(requisition.Id)
-		if err != nil {
-			return nordigen.Requisition{}, fmt.Errorf("GetRequisition: %w", err)
-		}
-		time.Sleep(2 * time.Second)
+	requisition, err = waitForLink(ctx, r.Config.Nordigen.AuthTimeout, 2*time.Second, callback, func() (nordigen.Requisition, error) {
+		return r.Client.GetRequisition(requisition.Id)
+	})
+	if err != nil {
+		return nordigen.Requisition{}, err
 	}
 
 	// Store requisition on disk
 	err = r.saveRequisition(requisition)
 	if err != nil {
-		log.Printf("Failed to write requisition to disk: %s", err)
+		slog.Warn("Failed to write requisition to disk", "error", err)
 	}
 
 	return requisition, nil
 }
 
+// serveRedirect serves the page the bank redirects to after authorization on
+// listener. The returned channel receives when the page is requested.
+func serveRedirect(listener net.Listener) (*http.Server, <-chan struct{}) {
+	callback := make(chan struct{}, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(w, "Ynabber received the authorization, you can close this window.")
+			select {
+			case callback <- struct{}{}:
+			default:
+			}
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("Redirect server failed", "error", err)
+		}
+	}()
+	return server, callback
+}
+
+// authMessage returns the message asking the user to authorize bank by going
+// to link, see createRequisition for reason
+func authMessage(bank, reason, link string) string {
+	if reason == "" {
+		return fmt.Sprintf("Initiate requisition for bank %s by going to: %s", bank, link)
+	}
+	return fmt.Sprintf("Bank %s needs re-authorization since %s, go to: %s", bank, reason, link)
+}
+
+// waitForLink calls get every interval, and whenever callback receives, until
+// the requisition is linked, ctx is done or timeout passes. A nil callback
+// only polls and a timeout of 0 waits until ctx is done.
+func waitForLink(ctx context.Context, timeout, interval time.Duration, callback <-chan struct{}, get func() (nordigen.Requisition, error)) (nordigen.Requisition, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for {
+		requisition, err := get()
+		if err != nil {
+			return nordigen.Requisition{}, fmt.Errorf("GetRequisition: %w", err)
+		}
+		if requisition.Status == "LN" {
+			return requisition, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nordigen.Requisition{}, fmt.Errorf("requisition was not authorized within %s", timeout)
+			}
+			return nordigen.Requisition{}, fmt.Errorf("waiting for authorization: %w", ctx.Err())
+		case <-callback:
+			slog.Info("Received redirect from bank")
+		case <-time.After(interval):
+		}
+	}
+}
+
 // requisitionHook executes the hook with the status and link as arguments
 func (r Reader) requisitionHook(req nordigen.Requisition) {
 	if r.Config.Nordigen.RequisitionHook != "" {
 		cmd := exec.Command(r.Config.Nordigen.RequisitionHook, req.Status, req.Link)
 		_, err := cmd.Output()
 		if err != nil {
-			log.Printf("failed to run requisition hook: %s", err)
+			slog.Warn("Failed to run requisition hook", "error", err)
 		}
 	}
 }
@@ -1,8 +1,18 @@
 package nordigen
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/frieser/nordigen-go-lib/v2"
 	"github.com/martinohansen/ynabber"
 )
 
@@ -21,3 +31,148 @@ func TestStore(t *testing.T) {
 		t.Fatalf("default: %s != %s", want, got)
 	}
 }
+
+func TestStoreMultipleBanks(t *testing.T) {
+	cfg := &ynabber.Config{
+		Nordigen: ynabber.Nordigen{BankID: "foo, bar"},
+		DataDir:  "data",
+	}
+	r := Reader{Config: cfg}
+	if got, want := r.banks(), []string{"foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got banks %v, want %v", got, want)
+	}
+
+	r.bank = "bar"
+	if got, want := r.requisitionStore(), "data/bar.json"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	cfg.Nordigen.RequisitionFile = "requisition"
+	if got, want := r.requisitionStore(), "data/requisition-bar.json"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "not found", err: fmt.Errorf("wrapped: %w", &nordigen.APIError{StatusCode: http.StatusNotFound}), want: true},
+		{name: "server error", err: &nordigen.APIError{StatusCode: http.StatusInternalServerError}, want: false},
+		{name: "other error", err: errors.New("network"), want: false},
+		{name: "no error", err: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notFound(tt.err); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveRequisition(t *testing.T) {
+	r := Reader{Config: &ynabber.Config{
+		Nordigen: ynabber.Nordigen{BankID: "foo"},
+		DataDir:  t.TempDir(),
+	}}
+	if err := r.saveRequisition(nordigen.Requisition{Id: "1", Status: "EX"}); err != nil {
+		t.Fatal(err)
+	}
+	r.removeRequisition()
+	if _, err := r.RequisitionFile(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v, want requisition file removed", err)
+	}
+
+	// Removing a missing file is fine
+	r.removeRequisition()
+}
+
+func TestWaitForLink(t *testing.T) {
+	statuses := []string{"CR", "GA", "LN"}
+	calls := 0
+	get := func() (nordigen.Requisition, error) {
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		return nordigen.Requisition{Id: "1", Status: status}, nil
+	}
+
+	got, err := waitForLink(context.Background(), time.Second, time.Millisecond, nil, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "LN" || calls != 3 {
+		t.Errorf("got status %s after %d calls, want LN after 3", got.Status, calls)
+	}
+
+	pending := func() (nordigen.Requisition, error) {
+		return nordigen.Requisition{Status: "CR"}, nil
+	}
+	_, err = waitForLink(context.Background(), 10*time.Millisecond, time.Millisecond, nil, pending)
+	if err == nil || !strings.Contains(err.Error(), "not authorized within") {
+		t.Errorf("got %v, want timeout error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = waitForLink(ctx, 0, time.Millisecond, nil, pending)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	failing := func() (nordigen.Requisition, error) {
+		return nordigen.Requisition{}, errors.New("network")
+	}
+	if _, err := waitForLink(context.Background(), time.Second, time.Millisecond, nil, failing); err == nil {
+		t.Error("want error from get")
+	}
+}
+
+func TestWaitForLinkCallback(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Skipf("can't listen: %s", err)
+	}
+	server, callback := serveRedirect(listener)
+	defer server.Close()
+
+	// The requisition is linked by the time the bank redirects
+	calls := 0
+	get := func() (nordigen.Requisition, error) {
+		calls++
+		if calls > 1 {
+			return nordigen.Requisition{Status: "LN"}, nil
+		}
+		return nordigen.Requisition{Status: "CR"}, nil
+	}
+	go func() {
+		res, err := http.Get(fmt.Sprintf("http://%s/?ref=1", listener.Addr()))
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	// The interval is longer than the test timeout so only the callback can
+	// make the requisition linked in time
+	got, err := waitForLink(context.Background(), 5*time.Second, time.Hour, callback, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "LN" {
+		t.Errorf("got status %s, want LN", got.Status)
+	}
+}
+
+func TestAuthMessage(t *testing.T) {
+	got := authMessage("NORDEA_NDEADKKK", "", "https://example.com")
+	if want := "Initiate requisition for bank NORDEA_NDEADKKK by going to: https://example.com"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = authMessage("NORDEA_NDEADKKK", "account Joint is expired", "https://example.com")
+	if want := "Bank NORDEA_NDEADKKK needs re-authorization since account Joint is expired, go to: https://example.com"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/frieser/nordigen-go-lib/v2"
@@ -14,34 +15,70 @@ type Mapper interface {
 	Map(ynabber.Account, nordigen.Transaction) (ynabber.Transaction, error)
 }
 
-// Mapper returns a mapper to transform the banks transaction to Ynabber
-func (r Reader) Mapper() Mapper {
-	switch r.Config.Nordigen.BankID {
+// Mapper returns a mapper to transform the banks transaction to Ynabber for
+// the account with iban
+func (r Reader) Mapper(iban string) Mapper {
+	switch r.bankID() {
 	case "NORDEA_NDEADKKK":
-		return Nordea{}
+		return Nordea{RemittanceDateFormat: r.Config.Nordigen.RemittanceDateFormat}
+
+	case "REVOLUT_REVOGB21", "REVOLUT_REVOLT21":
+		return Revolut{RemittanceDateFormat: r.Config.Nordigen.RemittanceDateFormat}
+
+	case "N26_NTSBDEB1":
+		return N26{RemittanceDateFormat: r.Config.Nordigen.RemittanceDateFormat}
 
 	default:
 		return Default{
-			PayeeSource:   r.Config.Nordigen.PayeeSource,
-			TransactionID: r.Config.Nordigen.TransactionID,
+			PayeeSource:          r.Config.PayeeSource(iban),
+			TransactionID:        r.Config.Nordigen.TransactionID,
+			RemittanceDateFormat: r.Config.Nordigen.RemittanceDateFormat,
 		}
 	}
 }
 
-func parseAmount(t nordigen.Transaction) (float64, error) {
+// parseAmount returns the amount of t in milliunits. The decimal string is
+// parsed directly to keep the amount exact and only amounts in other formats
+// go through a float.
+func parseAmount(t nordigen.Transaction) (ynabber.Milliunits, error) {
+	milliunits, err := ynabber.MilliunitsFromString(t.TransactionAmount.Amount)
+	if err == nil {
+		return milliunits, nil
+	}
 	amount, err := strconv.ParseFloat(t.TransactionAmount.Amount, 64)
 	if err != nil {
 		return 0, fmt.Errorf("failed to convert string to float: %w", err)
 	}
-	return amount, nil
+	return ynabber.MilliunitsFromAmount(amount), nil
+}
+
+// defaultRemittanceDateFormat is the layout of the date some banks put at the
+// start of the remittance information
+const defaultRemittanceDateFormat = "2006.01.02"
+
+// layoutDigit matches the digits of a time layout
+var layoutDigit = regexp.MustCompile(`\d`)
+
+// remittanceDate parses the date at the start of remittance using layout, the
+// default layout is used if layout is empty. The layout must be numeric since
+// each digit in it matches any digit in remittance.
+func remittanceDate(remittance, layout string) (time.Time, error) {
+	if layout == "" {
+		layout = defaultRemittanceDateFormat
+	}
+	re, err := regexp.Compile("^" + layoutDigit.ReplaceAllString(regexp.QuoteMeta(layout), `\d`))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(layout, re.FindString(remittance))
 }
 
-func parseDate(t nordigen.Transaction) (time.Time, error) {
+// parseDate returns the earliest of the value, booking and remittance date of
+// t. The remittance date is parsed with remittanceFormat, see remittanceDate.
+func parseDate(t nordigen.Transaction, remittanceFormat string) (time.Time, error) {
 	valueDate, valueDateErr := time.Parse("2006-01-02", t.ValueDate)
 	bookingDate, bookingDateErr := time.Parse("2006-01-02", t.BookingDate)
-	re := regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}`)
-	remittanceDateString := re.FindString(t.RemittanceInformationUnstructured)
-	remittanceDate, remittanceDateErr := time.Parse("2006.01.02", remittanceDateString)
+	remittanceDate, remittanceDateErr := remittanceDate(t.RemittanceInformationUnstructured, remittanceFormat)
 
 	// Handle parsing errors
 	if valueDateErr != nil && bookingDateErr != nil && remittanceDateErr != nil {
@@ -61,13 +98,49 @@ func parseDate(t nordigen.Transaction) (time.Time, error) {
 		earliestDate = bookingDate
 	}
 
+	// A date that parses as the zero time is as good as no date
+	if earliestDate.IsZero() {
+		return time.Time{}, fmt.Errorf("failed to parse any dates")
+	}
+
 	return earliestDate, nil
 }
 
+// parseTime returns the booking or value datetime of t in UTC. If neither is
+// available date is returned.
+func parseTime(t nordigen.Transaction, date time.Time) time.Time {
+	for _, v := range []string{t.BookingDateTime, t.ValueDateTime} {
+		if v == "" {
+			continue
+		}
+		dateTime, err := time.Parse(time.RFC3339, v)
+		if err == nil {
+			return dateTime.UTC()
+		}
+	}
+	return date
+}
+
+// counterparty returns the debtor for inflow and the creditor for outflow. If
+// the preferred party is empty the other party is returned instead.
+func counterparty(amount ynabber.Milliunits, creditor, debtor string) string {
+	if amount > 0 {
+		if debtor != "" {
+			return debtor
+		}
+		return creditor
+	}
+	if creditor != "" {
+		return creditor
+	}
+	return debtor
+}
+
 // Default mapping for all banks unless a more specific mapping exists
 type Default struct {
-	PayeeSource   []string
-	TransactionID string
+	PayeeSource          []string
+	TransactionID        string
+	RemittanceDateFormat string
 }
 
 // Map t using the default mapper
@@ -76,7 +149,7 @@ func (mapper Default) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Tr
 	if err != nil {
 		return ynabber.Transaction{}, err
 	}
-	date, err := parseDate(t)
+	date, err := parseDate(t, mapper.RemittanceDateFormat)
 	if err != nil {
 		return ynabber.Transaction{}, err
 	}
@@ -98,17 +171,18 @@ func (mapper Default) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Tr
 
 			// Name is using either creditor or debtor as the payee
 			case "name":
-				if amount > 0 {
-					if t.DebtorName != "" {
-						payee = t.DebtorName
-					} else if t.CreditorName != "" {
-						payee = t.CreditorName
-					}
-				} else if t.CreditorName != "" {
-					payee = t.CreditorName
-				} else if t.DebtorName != "" {
-					payee = t.DebtorName
-				}
+				payee = counterparty(amount, t.CreditorName, t.DebtorName)
+
+			// Ultimate is using either the ultimate creditor or debtor as the
+			// payee. Some banks only populate these for payments made on
+			// behalf of someone else.
+			case "ultimate":
+				payee = counterparty(amount, t.UltimateCreditor, t.UltimateDebtor)
+
+			// Account is using the IBAN of either the creditor or debtor
+			// account as the payee
+			case "account":
+				payee = counterparty(amount, t.CreditorAccount.Iban, t.DebtorAccount.Iban)
 
 			// Additional uses AdditionalInformation as payee
 			case "additional":
@@ -134,14 +208,18 @@ func (mapper Default) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Tr
 		Account: a,
 		ID:      ynabber.ID(id),
 		Date:    date,
+		Time:    parseTime(t, date),
 		Payee:   ynabber.Payee(payee),
 		Memo:    t.RemittanceInformationUnstructured,
-		Amount:  ynabber.MilliunitsFromAmount(amount),
+		Amount:  amount,
+		Code:    t.BankTransactionCode,
 	}, nil
 }
 
 // Nordea implements a specific mapper for Nordea
-type Nordea struct{}
+type Nordea struct {
+	RemittanceDateFormat string
+}
 
 // Map t using the Nordea mapper
 func (mapper Nordea) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Transaction, error) {
@@ -149,7 +227,7 @@ func (mapper Nordea) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Tra
 	if err != nil {
 		return ynabber.Transaction{}, err
 	}
-	date, err := parseDate(t)
+	date, err := parseDate(t, mapper.RemittanceDateFormat)
 	if err != nil {
 		return ynabber.Transaction{}, err
 	}
@@ -158,8 +236,100 @@ func (mapper Nordea) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Tra
 		Account: a,
 		ID:      ynabber.ID(t.InternalTransactionId),
 		Date:    date,
+		Time:    parseTime(t, date),
 		Payee:   ynabber.Payee(payeeStripNonAlphanumeric(t.RemittanceInformationUnstructured)),
 		Memo:    t.RemittanceInformationUnstructured,
-		Amount:  ynabber.MilliunitsFromAmount(amount),
+		Amount:  amount,
+		Code:    t.BankTransactionCode,
+	}, nil
+}
+
+// revolutPrefix matches the description Revolut puts in front of the merchant
+// in the remittance information
+var revolutPrefix = regexp.MustCompile(`(?i)^(card payment|payment|transfer) (to|from)\s+`)
+
+// Revolut implements a specific mapper for Revolut
+type Revolut struct {
+	RemittanceDateFormat string
+}
+
+// Map t using the Revolut mapper. Revolut only sometimes sets the creditor or
+// debtor name so the merchant is read from the remittance information
+// otherwise.
+func (mapper Revolut) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Transaction, error) {
+	amount, err := parseAmount(t)
+	if err != nil {
+		return ynabber.Transaction{}, err
+	}
+	date, err := parseDate(t, mapper.RemittanceDateFormat)
+	if err != nil {
+		return ynabber.Transaction{}, err
+	}
+
+	remittance := t.RemittanceInformationUnstructured
+	if remittance == "" {
+		remittance = strings.Join(t.RemittanceInformationUnstructuredArray, " ")
+	}
+
+	payee := counterparty(amount, t.CreditorName, t.DebtorName)
+	if payee == "" {
+		payee = revolutPrefix.ReplaceAllString(strings.TrimSpace(remittance), "")
+	}
+
+	return ynabber.Transaction{
+		Account: a,
+		ID:      ynabber.ID(t.TransactionId),
+		Date:    date,
+		Time:    parseTime(t, date),
+		Payee:   ynabber.Payee(strings.TrimSpace(payee)),
+		Memo:    remittance,
+		Amount:  amount,
+		Code:    t.BankTransactionCode,
+	}, nil
+}
+
+// n26Timestamp matches the timestamp N26 adds to the remittance information
+var n26Timestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?Z?`)
+
+// N26 implements a specific mapper for N26
+type N26 struct {
+	RemittanceDateFormat string
+}
+
+// Map t using the N26 mapper. The remittance information repeats the payee
+// along with a timestamp, both are removed to keep the memo short.
+func (mapper N26) Map(a ynabber.Account, t nordigen.Transaction) (ynabber.Transaction, error) {
+	amount, err := parseAmount(t)
+	if err != nil {
+		return ynabber.Transaction{}, err
+	}
+	date, err := parseDate(t, mapper.RemittanceDateFormat)
+	if err != nil {
+		return ynabber.Transaction{}, err
+	}
+
+	// Remove the timestamp first so its digits and separators don't end up
+	// in the payee
+	memo := n26Timestamp.ReplaceAllString(t.RemittanceInformationUnstructured, "")
+
+	payee := strings.TrimSpace(counterparty(amount, t.CreditorName, t.DebtorName))
+	if payee == "" {
+		payee = payeeStripNonAlphanumeric(memo)
+	}
+
+	if payee != "" {
+		memo = strings.ReplaceAll(memo, payee, "")
+	}
+	memo = strings.Join(strings.Fields(memo), " ")
+
+	return ynabber.Transaction{
+		Account: a,
+		ID:      ynabber.ID(t.TransactionId),
+		Date:    date,
+		Time:    parseTime(t, date),
+		Payee:   ynabber.Payee(payee),
+		Memo:    memo,
+		Amount:  amount,
+		Code:    t.BankTransactionCode,
 	}, nil
 }
@@ -1,16 +1,19 @@
 package nordigen
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/frieser/nordigen-go-lib/v2"
+	"github.com/martinohansen/ynabber"
 )
 
 func TestParseAmount(t *testing.T) {
 	tests := []struct {
 		transaction nordigen.Transaction
-		want        float64
+		want        ynabber.Milliunits
 		wantErr     bool
 	}{
 		{
@@ -20,7 +23,7 @@ func TestParseAmount(t *testing.T) {
 					Currency string "json:\"currency,omitempty\""
 				}{Amount: "328.18"},
 			},
-			want:    328.18,
+			want:    328180,
 			wantErr: false,
 		},
 		{
@@ -30,9 +33,28 @@ func TestParseAmount(t *testing.T) {
 					Currency string "json:\"currency,omitempty\""
 				}{Amount: "32818"},
 			},
-			want:    32818,
+			want:    32818000,
 			wantErr: false,
 		},
+		{
+			transaction: nordigen.Transaction{
+				TransactionAmount: struct {
+					Amount   string "json:\"amount,omitempty\""
+					Currency string "json:\"currency,omitempty\""
+				}{Amount: "1.5e2"},
+			},
+			want:    150000,
+			wantErr: false,
+		},
+		{
+			transaction: nordigen.Transaction{
+				TransactionAmount: struct {
+					Amount   string "json:\"amount,omitempty\""
+					Currency string "json:\"currency,omitempty\""
+				}{Amount: "foo"},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -48,3 +70,378 @@ func TestParseAmount(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultMapNestedPayee(t *testing.T) {
+	// Transaction where the bank leaves the names empty and only populates
+	// the nested ultimate and account fields
+	transaction := func(amount string) nordigen.Transaction {
+		return nordigen.Transaction{
+			TransactionId: "foo",
+			BookingDate:   "2023-02-24",
+			TransactionAmount: struct {
+				Amount   string "json:\"amount,omitempty\""
+				Currency string "json:\"currency,omitempty\""
+			}{Amount: amount, Currency: "EUR"},
+			CreditorAccount: struct {
+				Iban string "json:\"iban,omitempty\""
+			}{Iban: "DE89370400440532013000"},
+			UltimateCreditor: "Merchant GmbH",
+			DebtorAccount: struct {
+				Iban string "json:\"iban,omitempty\""
+			}{Iban: "DK9520000123456789"},
+			UltimateDebtor: "Employer A/S",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		payeeSource []string
+		amount      string
+		want        string
+	}{
+		{
+			name:        "ultimate outflow",
+			payeeSource: []string{"ultimate"},
+			amount:      "-10",
+			want:        "Merchant GmbH",
+		},
+		{
+			name:        "ultimate inflow",
+			payeeSource: []string{"ultimate"},
+			amount:      "10",
+			want:        "Employer A/S",
+		},
+		{
+			name:        "account outflow",
+			payeeSource: []string{"account"},
+			amount:      "-10",
+			want:        "DE89370400440532013000",
+		},
+		{
+			name:        "account inflow",
+			payeeSource: []string{"account"},
+			amount:      "10",
+			want:        "DK9520000123456789",
+		},
+		{
+			// Empty name falls through to the next source
+			name:        "fallthrough",
+			payeeSource: []string{"name", "ultimate", "account"},
+			amount:      "-10",
+			want:        "Merchant GmbH",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := Default{PayeeSource: tt.payeeSource, TransactionID: "TransactionId"}
+			got, err := mapper.Map(ynabber.Account{}, transaction(tt.amount))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got.Payee) != tt.want {
+				t.Errorf("got = %s, want %s", got.Payee, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterparty(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   ynabber.Milliunits
+		creditor string
+		debtor   string
+		want     string
+	}{
+		{name: "inflow", amount: 1, creditor: "c", debtor: "d", want: "d"},
+		{name: "outflow", amount: -1, creditor: "c", debtor: "d", want: "c"},
+		{name: "inflow no debtor", amount: 1, creditor: "c", want: "c"},
+		{name: "outflow no creditor", amount: -1, debtor: "d", want: "d"},
+		{name: "empty", amount: -1, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counterparty(tt.amount, tt.creditor, tt.debtor); got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateRemittanceFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		remittance string
+		want       time.Time
+	}{
+		{
+			name:       "default",
+			remittance: "2023.02.20 Netto",
+			want:       time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "custom",
+			format:     "02/01/2006",
+			remittance: "20/02/2023 Netto",
+			want:       time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "custom ignores default",
+			format:     "02/01/2006",
+			remittance: "2023.02.20 Netto",
+			want:       time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "no date",
+			remittance: "Netto",
+			want:       time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := nordigen.Transaction{
+				BookingDate:                       "2023-02-24",
+				RemittanceInformationUnstructured: tt.remittance,
+			}
+			got, err := parseDate(transaction, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateZero(t *testing.T) {
+	tests := []struct {
+		name string
+		t    nordigen.Transaction
+	}{
+		{name: "no dates", t: nordigen.Transaction{}},
+		{name: "zero remittance date", t: nordigen.Transaction{RemittanceInformationUnstructured: "0001.01.01 Netto"}},
+		{name: "zero booking date", t: nordigen.Transaction{BookingDate: "0001-01-01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDate(tt.t, "")
+			if err == nil {
+				t.Errorf("got %s, want error", got)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	date := time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    nordigen.Transaction
+		want time.Time
+	}{
+		{
+			name: "booking datetime",
+			t: nordigen.Transaction{
+				BookingDateTime: "2023-02-24T10:15:00+01:00",
+				ValueDateTime:   "2023-02-24T11:00:00+01:00",
+			},
+			want: time.Date(2023, 2, 24, 9, 15, 0, 0, time.UTC),
+		},
+		{
+			name: "value datetime",
+			t:    nordigen.Transaction{ValueDateTime: "2023-02-24T11:00:00Z"},
+			want: time.Date(2023, 2, 24, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date only",
+			t:    nordigen.Transaction{BookingDate: "2023-02-24"},
+			want: date,
+		},
+		{
+			name: "invalid datetime",
+			t:    nordigen.Transaction{BookingDateTime: "2023-02-24 10:15"},
+			want: date,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTime(tt.t, date); !got.Equal(tt.want) {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevolutMap(t *testing.T) {
+	// Transactions as received from Nordigen for a Revolut account
+	tests := []struct {
+		name        string
+		transaction string
+		payee       ynabber.Payee
+		memo        string
+		amount      ynabber.Milliunits
+	}{
+		{
+			name: "payee from remittance array",
+			transaction: `{
+				"transactionId": "64a1f2e3-0a1b-a2c3-9d4e-5f6a7b8c9d0e",
+				"bookingDate": "2023-07-02",
+				"valueDate": "2023-07-02",
+				"bookingDateTime": "2023-07-02T14:31:05.123Z",
+				"transactionAmount": {"amount": "-12.40", "currency": "EUR"},
+				"remittanceInformationUnstructuredArray": ["Card payment to Lidl Vilnius"]
+			}`,
+			payee:  "Lidl Vilnius",
+			memo:   "Card payment to Lidl Vilnius",
+			amount: -12400,
+		},
+		{
+			name: "creditor name",
+			transaction: `{
+				"transactionId": "64a2b3c4-1b2c-a3d4-8e5f-6a7b8c9d0e1f",
+				"bookingDate": "2023-07-03",
+				"valueDate": "2023-07-03",
+				"transactionAmount": {"amount": "-4.99", "currency": "EUR"},
+				"creditorName": "Spotify",
+				"remittanceInformationUnstructured": "Card payment to Spotify P1A2B3C4D5"
+			}`,
+			payee:  "Spotify",
+			memo:   "Card payment to Spotify P1A2B3C4D5",
+			amount: -4990,
+		},
+		{
+			name: "inflow",
+			transaction: `{
+				"transactionId": "64a3c4d5-2c3d-a4e5-9f6a-7b8c9d0e1f2a",
+				"bookingDate": "2023-07-04",
+				"valueDate": "2023-07-04",
+				"transactionAmount": {"amount": "25.00", "currency": "EUR"},
+				"remittanceInformationUnstructured": "Payment from John Doe"
+			}`,
+			payee:  "John Doe",
+			memo:   "Payment from John Doe",
+			amount: 25000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transaction nordigen.Transaction
+			if err := json.Unmarshal([]byte(tt.transaction), &transaction); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Revolut{}.Map(ynabber.Account{}, transaction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != ynabber.ID(transaction.TransactionId) {
+				t.Errorf("got ID %s, want %s", got.ID, transaction.TransactionId)
+			}
+			if got.Payee != tt.payee || got.Memo != tt.memo || got.Amount != tt.amount {
+				t.Errorf("got %q, %q, %s, want %q, %q, %s",
+					got.Payee, got.Memo, got.Amount, tt.payee, tt.memo, tt.amount)
+			}
+		})
+	}
+
+	r := Reader{Config: &ynabber.Config{Nordigen: ynabber.Nordigen{BankID: "REVOLUT_REVOLT21"}}}
+	if _, ok := r.Mapper("").(Revolut); !ok {
+		t.Errorf("got mapper %T, want Revolut", r.Mapper(""))
+	}
+}
+
+func TestN26Map(t *testing.T) {
+	// Transactions as received from Nordigen for an N26 account
+	tests := []struct {
+		name        string
+		transaction string
+		payee       ynabber.Payee
+		memo        string
+		amount      ynabber.Milliunits
+	}{
+		{
+			name: "outflow",
+			transaction: `{
+				"transactionId": "5b7f0e7a-2f3c-4d8e-9a1b-3c4d5e6f7a8b",
+				"bookingDate": "2023-08-14",
+				"valueDate": "2023-08-14",
+				"transactionAmount": {"amount": "-23.15", "currency": "EUR"},
+				"creditorName": "REWE Markt GmbH",
+				"remittanceInformationUnstructured": "REWE Markt GmbH 2023-08-14T17:42:10 Einkauf"
+			}`,
+			payee:  "REWE Markt GmbH",
+			memo:   "Einkauf",
+			amount: -23150,
+		},
+		{
+			name: "inflow",
+			transaction: `{
+				"transactionId": "6c8a1f8b-3a4d-5e9f-8b2c-4d5e6f7a8b9c",
+				"bookingDate": "2023-08-15",
+				"valueDate": "2023-08-15",
+				"transactionAmount": {"amount": "1500.00", "currency": "EUR"},
+				"debtorName": "ACME GmbH",
+				"remittanceInformationUnstructured": "ACME GmbH 2023-08-15 08:00:00 Gehalt August"
+			}`,
+			payee:  "ACME GmbH",
+			memo:   "Gehalt August",
+			amount: 1500000,
+		},
+		{
+			name: "only payee and timestamp",
+			transaction: `{
+				"transactionId": "7d9b2a9c-4b5e-6f0a-9c3d-5e6f7a8b9c0d",
+				"bookingDate": "2023-08-16",
+				"valueDate": "2023-08-16",
+				"transactionAmount": {"amount": "-9.99", "currency": "EUR"},
+				"creditorName": "Netflix",
+				"remittanceInformationUnstructured": "Netflix 2023-08-16T00:00:00"
+			}`,
+			payee:  "Netflix",
+			memo:   "",
+			amount: -9990,
+		},
+		{
+			// The timestamp must not leave its T behind in the payee
+			name: "payee from remittance",
+			transaction: `{
+				"transactionId": "8e0c3b0d-5c6f-7a1b-0d4e-6f7a8b9c0d1e",
+				"bookingDate": "2023-08-17",
+				"valueDate": "2023-08-17",
+				"transactionAmount": {"amount": "-3.50", "currency": "EUR"},
+				"remittanceInformationUnstructured": "Kiosk 2023-08-17T10:00:00"
+			}`,
+			payee:  "Kiosk",
+			memo:   "",
+			amount: -3500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transaction nordigen.Transaction
+			if err := json.Unmarshal([]byte(tt.transaction), &transaction); err != nil {
+				t.Fatal(err)
+			}
+			got, err := N26{}.Map(ynabber.Account{}, transaction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != ynabber.ID(transaction.TransactionId) {
+				t.Errorf("got ID %s, want %s", got.ID, transaction.TransactionId)
+			}
+			if got.Payee != tt.payee || got.Memo != tt.memo || got.Amount != tt.amount {
+				t.Errorf("got %q, %q, %s, want %q, %q, %s",
+					got.Payee, got.Memo, got.Amount, tt.payee, tt.memo, tt.amount)
+			}
+		})
+	}
+
+	r := Reader{Config: &ynabber.Config{Nordigen: ynabber.Nordigen{BankID: "N26_NTSBDEB1"}}}
+	if _, ok := r.Mapper("").(N26); !ok {
+		t.Errorf("got mapper %T, want N26", r.Mapper(""))
+	}
+}
@@ -1,34 +1,84 @@
 package nordigen
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"log"
+	"log/slog"
+	"os"
+	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/frieser/nordigen-go-lib/v2"
 	"github.com/martinohansen/ynabber"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer is a no-op unless a tracer provider is registered
+var tracer = otel.Tracer("github.com/martinohansen/ynabber/reader/nordigen")
+
 type Reader struct {
 	Config *ynabber.Config
 
 	Client *nordigen.Client
 
 	S3Client *s3.Client
+
+	// Notifier is used to send the requisition link to the user
+	Notifier ynabber.Notifier
+
+	// bank is the bank read by Bulk, see bankID
+	bank string
 }
 
 // NewReader returns a new nordigen reader or panics
-func NewReader(cfg *ynabber.Config) Reader {
+func NewReader(cfg *ynabber.Config, notifier ynabber.Notifier) Reader {
 	client, err := nordigen.NewClient(cfg.Nordigen.SecretID, cfg.Nordigen.SecretKey)
 	if err != nil {
 		panic("Failed to create nordigen client")
 	}
 
 	return Reader{
-		Config: cfg,
-		Client: client,
+		Config:   cfg,
+		Client:   client,
+		Notifier: notifier,
+	}
+}
+
+// banks returns the IDs of the banks to read from
+func (r Reader) banks() []string {
+	var banks []string
+	for _, bank := range strings.Split(r.Config.Nordigen.BankID, ",") {
+		if bank = strings.TrimSpace(bank); bank != "" {
+			banks = append(banks, bank)
+		}
+	}
+	return banks
+}
+
+// bankID returns the ID of the bank being read
+func (r Reader) bankID() string {
+	if r.bank != "" {
+		return r.bank
+	}
+	return r.Config.Nordigen.BankID
+}
+
+// notify sends message with the notifier if any. Errors are only logged since
+// the message is also written to the log.
+func (r Reader) notify(message string) {
+	if r.Notifier == nil {
+		return
+	}
+	if err := r.Notifier.Notify(message); err != nil {
+		slog.Warn("Failed to notify", "error", err)
 	}
 }
 
@@ -39,20 +89,198 @@ func payeeStripNonAlphanumeric(payee string) (x string) {
 	return strings.TrimSpace(x)
 }
 
+// stripPayee removes the entries in strip from payee in order
+func stripPayee(payee ynabber.Payee, strip ynabber.PayeeStrip) ynabber.Payee {
+	x := string(payee)
+	for _, entry := range strip {
+		switch {
+		case entry.NonAlphanumeric:
+			x = payeeStripNonAlphanumeric(x)
+		case entry.Pattern != nil:
+			x = entry.Pattern.ReplaceAllString(x, "")
+		default:
+			x = strings.ReplaceAll(x, entry.Literal, "")
+		}
+	}
+	return ynabber.Payee(strings.TrimSpace(x))
+}
+
+// dumpTransactions writes t to a timestamped file in the data dir and returns
+// the path of the file. The client library only returns the parsed response,
+// so t is marshalled again and fields it doesn't model are lost.
+func (r Reader) dumpTransactions(accountID string, t nordigen.AccountTransactions) (string, error) {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	file := path.Clean(fmt.Sprintf("%s/nordigen-%s-%s.json",
+		r.Config.DataDir,
+		accountID,
+		time.Now().UTC().Format("20060102T150405Z"),
+	))
+	err = os.WriteFile(file, b, 0600)
+	if err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
 func (r Reader) toYnabber(a ynabber.Account, t nordigen.Transaction) (ynabber.Transaction, error) {
-	transaction, err := r.Mapper().Map(a, t)
+	transaction, err := r.Mapper(a.IBAN).Map(a, t)
 	if err != nil {
 		return ynabber.Transaction{}, err
 	}
 
+	transaction.Currency = t.TransactionAmount.Currency
+
+	if tmpl := r.Config.Nordigen.MemoTemplate; tmpl.Template != nil {
+		var memo strings.Builder
+		if err := tmpl.Execute(&memo, t); err != nil {
+			return ynabber.Transaction{}, fmt.Errorf("memo template: %w", err)
+		}
+		transaction.Memo = strings.TrimSpace(memo.String())
+	}
+
+	// Convert the amount from minor units if the account is configured to
+	// report amounts as such
+	if r.minorUnits(a.IBAN) {
+		transaction.Amount = transaction.Amount / 100
+	}
+
+	if r.Config.Nordigen.DebitPositive {
+		transaction.Amount = transaction.Amount.Negate()
+	}
+
 	// Execute strip method on payee if defined in config
 	if r.Config.Nordigen.PayeeStrip != nil {
-		transaction.Payee = transaction.Payee.Strip(r.Config.Nordigen.PayeeStrip)
+		transaction.Payee = stripPayee(transaction.Payee, r.Config.Nordigen.PayeeStrip)
 	}
 
 	return transaction, nil
 }
 
+// minorUnits reports whether the account with iban reports amounts in minor
+// units
+func (r Reader) minorUnits(iban string) bool {
+	for _, v := range r.Config.Nordigen.MinorUnits {
+		if v == iban {
+			return true
+		}
+	}
+	return false
+}
+
+// signCheckMinimum is the number of transactions an account needs before
+// checkSigns draws any conclusion
+const signCheckMinimum = 10
+
+// checkSigns warns if nearly all transactions in t are inflow, which usually
+// means the sign convention of the bank is configured wrong and spending
+// shows up as income
+func checkSigns(a ynabber.Account, t []ynabber.Transaction) {
+	if len(t) < signCheckMinimum {
+		return
+	}
+	inflow := 0
+	for _, v := range t {
+		if v.Amount > 0 {
+			inflow += 1
+		}
+	}
+	if inflow*10 < len(t)*9 {
+		return
+	}
+	slog.Warn("Nearly all transactions of the account are inflow, check NORDIGEN_DEBIT_POSITIVE and YNAB_SWAPFLOW",
+		"account", a.Name, "inflow", inflow, "transactions", len(t))
+}
+
+// balanceTypes is the balance types that reflect booked transactions in order
+// of preference
+var balanceTypes = []string{"closingBooked", "interimBooked", "expected"}
+
+// bookedBalance returns the preferred booked balance in b in milliunits, the
+// decimal string is parsed directly to keep it exact
+func bookedBalance(b nordigen.AccountBalances) (ynabber.Milliunits, error) {
+	for _, balanceType := range balanceTypes {
+		for _, balance := range b.Balances {
+			if balance.BalanceType != balanceType {
+				continue
+			}
+			return ynabber.MilliunitsFromString(balance.BalanceAmount.Amount)
+		}
+	}
+	return 0, fmt.Errorf("no booked balance")
+}
+
+// balance returns the booked balance of the account in milliunits
+func (r Reader) balance(a ynabber.Account) (ynabber.Milliunits, error) {
+	balances, err := r.Client.GetAccountBalances(string(a.ID))
+	if err != nil {
+		return 0, err
+	}
+	balance, err := bookedBalance(balances)
+	if err != nil {
+		return 0, err
+	}
+	if r.minorUnits(a.IBAN) {
+		balance = balance / 100
+	}
+	return balance, nil
+}
+
+// inRange reports whether date is within Nordigen.DateFrom and
+// Nordigen.DateTo, both inclusive. A zero limit is ignored. The range is
+// applied after fetching since the client can't send it to Nordigen.
+func (r Reader) inRange(date time.Time) bool {
+	from := time.Time(r.Config.Nordigen.DateFrom)
+	to := time.Time(r.Config.Nordigen.DateTo)
+	if !from.IsZero() && date.Before(from) {
+		return false
+	}
+	if !to.IsZero() && date.After(to) {
+		return false
+	}
+	return true
+}
+
+// expectedCurrency reports whether t is in the currency expected for its
+// account, a mismatch is always logged but only rejected if
+// Nordigen.SkipCurrencyMismatch is set
+func (r Reader) expectedCurrency(t ynabber.Transaction) bool {
+	expected, ok := r.Config.Nordigen.ExpectedCurrency[t.Account.IBAN]
+	if !ok || t.Currency == "" || strings.EqualFold(t.Currency, expected) {
+		return true
+	}
+
+	action := "imported at face value"
+	if r.Config.Nordigen.SkipCurrencyMismatch {
+		action = "skipped"
+	}
+	slog.Warn("Transaction is in an unexpected currency, the transaction is "+action,
+		"id", t.ID, "account", t.Account.Name, "date", t.Date.Format(ynabber.DateFormat),
+		"currency", t.Currency, "expected", expected)
+	return !r.Config.Nordigen.SkipCurrencyMismatch
+}
+
+// mapTransactions maps t with toYnabbers inside a span named after the
+// mapper of the account
+func (r Reader) mapTransactions(ctx context.Context, a ynabber.Account, t nordigen.AccountTransactions) ([]ynabber.Transaction, error) {
+	name := fmt.Sprintf("%T", r.Mapper(a.IBAN))
+	_, span := tracer.Start(ctx, fmt.Sprintf("mapper %s", name),
+		trace.WithAttributes(attribute.String("ynabber.mapper", name)),
+	)
+	defer span.End()
+
+	y, err := r.toYnabbers(a, t)
+	span.SetAttributes(attribute.Int("ynabber.transactions", len(y)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return y, err
+}
+
 func (r Reader) toYnabbers(a ynabber.Account, t nordigen.AccountTransactions) ([]ynabber.Transaction, error) {
 	y := []ynabber.Transaction{}
 	for _, v := range t.Transactions.Booked {
@@ -60,20 +288,73 @@ func (r Reader) toYnabbers(a ynabber.Account, t nordigen.AccountTransactions) ([
 		if err != nil {
 			return nil, err
 		}
+		if !r.inRange(transaction.Date) || !r.expectedCurrency(transaction) {
+			continue
+		}
 
 		// Append transaction
 		y = append(y, transaction)
 	}
+
+	// Pending transactions without an ID are skipped, they can't be matched
+	// with the booked transaction later and would stay in YNAB forever
+	if r.Config.Nordigen.IncludePending {
+		withoutID := 0
+		for _, v := range t.Transactions.Pending {
+			transaction, err := r.toYnabber(a, v)
+			if err != nil {
+				return nil, err
+			}
+			if transaction.ID == "" {
+				withoutID += 1
+				continue
+			}
+			if !r.inRange(transaction.Date) || !r.expectedCurrency(transaction) {
+				continue
+			}
+			transaction.Pending = true
+			y = append(y, transaction)
+		}
+		if withoutID > 0 {
+			slog.Info("Skipped pending transactions without an ID, they are read once booked",
+				"account", a.Name, "count", withoutID)
+		}
+	}
 	return y, nil
 }
 
-func (r Reader) Bulk() (t []ynabber.Transaction, err error) {
-	req, err := r.Requisition()
+// Bulk reads the transactions from all banks. A failing bank doesn't stop the
+// others, the joined errors are returned along with what the others read.
+func (r Reader) Bulk(ctx context.Context) (t []ynabber.Transaction, err error) {
+	var errs []error
+	for _, bank := range r.banks() {
+		r.bank = bank
+		x, err := r.bulk(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bank %s: %w", bank, err))
+			continue
+		}
+		t = append(t, x...)
+	}
+	return t, errors.Join(errs...)
+}
+
+// bulk reads the transactions from the accounts of a single bank
+func (r Reader) bulk(ctx context.Context) (t []ynabber.Transaction, err error) {
+	req, err := r.Requisition(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to authorize: %w", err)
 	}
 
-	log.Printf("Found %v accounts", len(req.Accounts))
+	// Use the institution ID if the name can't be looked up
+	institution, err := r.Client.GetInstitution(req.InstitutionId)
+	if err != nil {
+		slog.Warn("Failed to get institution", "error", err)
+		institution.Name = req.InstitutionId
+	}
+
+	slog.Info("Found accounts", "count", len(req.Accounts))
+	var skipped []string
 	for _, account := range req.Accounts {
 		accountMetadata, err := r.Client.GetAccountMetadata(account)
 		if err != nil {
@@ -84,36 +365,88 @@ func (r Reader) Bulk() (t []ynabber.Transaction, err error) {
 		// requisition.
 		switch accountMetadata.Status {
 		case "EXPIRED", "SUSPENDED":
-			log.Printf(
-				"Account: %s is %s. Going to recreate the requisition...",
-				account,
-				accountMetadata.Status,
-			)
-			r.createRequisition()
+			slog.Info("Account is not accessible, going to recreate the requisition",
+				"account", account, "status", accountMetadata.Status)
+			r.createRequisition(ctx, fmt.Sprintf("account %s is %s",
+				r.Config.AccountName(accountMetadata.Iban), strings.ToLower(accountMetadata.Status)))
+		}
+
+		// Skip accounts that are not ready to avoid a failing fetch
+		if !r.acceptedStatus(accountMetadata.Status) {
+			slog.Info("Skipping account",
+				"account", r.Config.AccountName(accountMetadata.Iban), "status", accountMetadata.Status)
+			skipped = append(skipped, fmt.Sprintf("%s (%s)",
+				r.Config.AccountName(accountMetadata.Iban), accountMetadata.Status))
+			continue
 		}
 
 		account := ynabber.Account{
 			ID:   ynabber.ID(accountMetadata.Id),
-			Name: accountMetadata.Iban,
+			Name: r.Config.AccountName(accountMetadata.Iban),
 			IBAN: accountMetadata.Iban,
+
+			Institution: institution.Name,
+		}
+
+		// A missing balance only prevents reconciliation, so don't fail
+		if r.Config.Nordigen.Balances {
+			balance, err := r.balance(account)
+			if err != nil {
+				slog.Warn("Failed to get balance of account", "account", account.Name, "error", err)
+			} else {
+				account.Balance = &balance
+			}
 		}
 
-		log.Printf("Reading transactions from account: %s", account.Name)
+		slog.Info("Reading transactions", "account", account.Name)
 
 		transactions, err := r.Client.GetAccountTransactions(string(account.ID))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get transactions: %w", err)
 		}
 
-		if r.Config.Debug {
-			log.Printf("Transactions received from Nordigen: %+v", transactions)
+		slog.Debug("Transactions received from Nordigen", "transactions", transactions)
+
+		if r.Config.Nordigen.DumpTransactions {
+			file, err := r.dumpTransactions(string(account.ID), transactions)
+			if err != nil {
+				slog.Warn("Failed to dump transactions", "error", err)
+			} else {
+				slog.Info("Dumped transactions", "file", file)
+			}
 		}
 
-		x, err := r.toYnabbers(account, transactions)
+		x, err := r.mapTransactions(ctx, account, transactions)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert transaction: %w", err)
 		}
+		checkSigns(account, x)
+		ynabber.Sort(x)
 		t = append(t, x...)
+		ynabber.AccountRead(ctx, account.IBAN)
+	}
+
+	// The user is notified since transactions of a skipped account are
+	// silently missing from the budget otherwise
+	if len(skipped) > 0 {
+		message := fmt.Sprintf("Skipped %d of %d accounts of bank %s: %s",
+			len(skipped), len(req.Accounts), r.bankID(), strings.Join(skipped, ", "))
+		slog.Info(message)
+		r.notify(message)
 	}
 	return t, nil
 }
+
+// acceptedStatus reports whether transactions should be read from an account
+// with status. An empty status is accepted since not all banks report it.
+func (r Reader) acceptedStatus(status string) bool {
+	if status == "" {
+		return true
+	}
+	for _, accepted := range r.Config.Nordigen.AccountStatuses {
+		if strings.EqualFold(status, accepted) {
+			return true
+		}
+	}
+	return false
+}
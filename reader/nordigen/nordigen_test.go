@@ -1,12 +1,23 @@
 package nordigen
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/frieser/nordigen-go-lib/v2"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestToYnabber(t *testing.T) {
@@ -57,12 +68,14 @@ func TestToYnabber(t *testing.T) {
 					AdditionalInformation:                  "VISA KØB"},
 			},
 			want: ynabber.Transaction{
-				Account: ynabber.Account{Name: "foo", IBAN: "bar"},
-				ID:      ynabber.ID("H00000000000000000000"),
-				Date:    time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
-				Payee:   "Visa køb DKK HELLOFRESH Copenha Den",
-				Memo:    "Visa køb DKK 424,00 HELLOFRESH Copenha Den 23.02",
-				Amount:  ynabber.Milliunits(10000),
+				Account:  ynabber.Account{Name: "foo", IBAN: "bar"},
+				ID:       ynabber.ID("H00000000000000000000"),
+				Date:     time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
+				Time:     time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
+				Payee:    "Visa køb DKK HELLOFRESH Copenha Den",
+				Memo:     "Visa køb DKK 424,00 HELLOFRESH Copenha Den 23.02",
+				Amount:   ynabber.Milliunits(10000),
+				Currency: "DKK",
 			},
 			wantErr: false,
 		},
@@ -97,12 +110,15 @@ func TestToYnabber(t *testing.T) {
 					AdditionalInformation:                  "PASCAL AS"},
 			},
 			want: ynabber.Transaction{
-				Account: ynabber.Account{Name: "foo", IBAN: "bar"},
-				ID:      ynabber.ID("foobar"),
-				Date:    time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
-				Payee:   "PASCAL AS",
-				Memo:    "",
-				Amount:  ynabber.Milliunits(10000),
+				Account:  ynabber.Account{Name: "foo", IBAN: "bar"},
+				ID:       ynabber.ID("foobar"),
+				Date:     time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
+				Time:     time.Date(2023, time.February, 24, 0, 0, 0, 0, time.UTC),
+				Payee:    "PASCAL AS",
+				Memo:     "",
+				Amount:   ynabber.Milliunits(10000),
+				Code:     "PURCHASE",
+				Currency: "NOK",
 			},
 			wantErr: false,
 		},
@@ -146,3 +162,453 @@ func TestPayeeStripNonAlphanumeric(t *testing.T) {
 		t.Fatalf("non-alphanumeric: %s != %s", want, got)
 	}
 }
+
+func TestStripPayee(t *testing.T) {
+	tests := []struct {
+		name  string
+		payee ynabber.Payee
+		strip string
+		want  ynabber.Payee
+	}{
+		{name: "words", payee: "foo bar baz", strip: "foo,baz", want: "bar"},
+		{name: "literal", payee: "Im not really here", strip: "not ,really ", want: "Im here"},
+		{name: "literal dot", payee: "A.B AXB", strip: "A.B", want: "AXB"},
+		{name: "keeps whitespace", payee: "Netto  Vesterbro", strip: "foo", want: "Netto  Vesterbro"},
+		{name: "regex", payee: "MobilePay  Netto 1234", strip: `/^MobilePay\s+/,/\s*\d+$/`, want: "Netto"},
+		{name: "in order", payee: "Netto-42", strip: `/-\d+/,Netto`, want: ""},
+		{name: "named", payee: "Netto/Vesterbro 42", strip: "non_alphanumeric", want: "Netto Vesterbro"},
+		{name: "keeps slashes", payee: "7/11", strip: "foo", want: "7/11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var strip ynabber.PayeeStrip
+			if err := strip.Decode(tt.strip); err != nil {
+				t.Fatal(err)
+			}
+			if got := stripPayee(tt.payee, strip); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpTransactions(t *testing.T) {
+	r := Reader{Config: &ynabber.Config{DataDir: t.TempDir()}}
+
+	var want nordigen.AccountTransactions
+	want.Transactions.Booked = []nordigen.Transaction{
+		{TransactionId: "foo", BookingDate: "2023-02-24"},
+	}
+
+	file, err := r.dumpTransactions("bar", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dump must be replayable through the mappers
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got nordigen.AccountTransactions
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v, want %+v", got, want)
+	}
+}
+
+func TestToYnabberMinorUnits(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	cfg.Nordigen.MinorUnits = []string{"minor"}
+	r := Reader{Config: &cfg}
+
+	transaction := nordigen.Transaction{
+		TransactionId: "foo",
+		BookingDate:   "2023-02-24",
+		TransactionAmount: struct {
+			Amount   string "json:\"amount,omitempty\""
+			Currency string "json:\"currency,omitempty\""
+		}{Amount: "-1234", Currency: "EUR"},
+	}
+
+	tests := []struct {
+		iban string
+		want ynabber.Milliunits
+	}{
+		{iban: "major", want: -1234000},
+		{iban: "minor", want: -12340},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iban, func(t *testing.T) {
+			got, err := r.toYnabber(ynabber.Account{IBAN: tt.iban}, transaction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Amount != tt.want {
+				t.Errorf("got = %s, want %s", got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestToYnabbersPending(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	r := Reader{Config: &cfg}
+
+	transaction := func(id string) nordigen.Transaction {
+		t := nordigen.Transaction{TransactionId: id, BookingDate: "2023-02-24"}
+		t.TransactionAmount.Amount = "-10"
+		return t
+	}
+	var transactions nordigen.AccountTransactions
+	transactions.Transactions.Booked = []nordigen.Transaction{transaction("booked")}
+	transactions.Transactions.Pending = []nordigen.Transaction{transaction("pending")}
+	for _, include := range []bool{false, true} {
+		cfg.Nordigen.IncludePending = include
+		got, err := r.toYnabbers(ynabber.Account{}, transactions)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []ynabber.ID{"booked"}
+		if include {
+			want = append(want, "pending")
+		}
+		if len(got) != len(want) {
+			t.Fatalf("include %t: got %d transactions, want %d", include, len(got), len(want))
+		}
+		for i, tx := range got {
+			if tx.ID != want[i] || tx.Pending != (want[i] == "pending") {
+				t.Errorf("include %t: got %s pending %t", include, tx.ID, tx.Pending)
+			}
+		}
+	}
+}
+
+func TestToYnabbersPendingThenBooked(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	cfg.Nordigen.IncludePending = true
+	r := Reader{Config: &cfg}
+	account := ynabber.Account{IBAN: "DK123"}
+
+	transaction := func(id string) nordigen.Transaction {
+		t := nordigen.Transaction{TransactionId: id, BookingDate: "2023-02-24"}
+		t.TransactionAmount.Amount = "-10"
+		return t
+	}
+
+	// The first run sees the transaction pending, along with one the bank
+	// hasn't given an ID yet
+	var pending nordigen.AccountTransactions
+	pending.Transactions.Pending = []nordigen.Transaction{transaction("abc"), transaction("")}
+	first, err := r.toYnabbers(account, pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 || first[0].ID != "abc" || !first[0].Pending {
+		t.Fatalf("got %+v, want only the pending transaction with an ID", first)
+	}
+
+	// The next run sees both booked, the booked transaction has the same
+	// import ID as the pending one so YNAB dedups it
+	var booked nordigen.AccountTransactions
+	booked.Transactions.Booked = []nordigen.Transaction{transaction("abc"), transaction("")}
+	second, err := r.toYnabbers(account, booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 2 || second[0].Pending || second[1].ID != "" {
+		t.Fatalf("got %+v, want both booked transactions", second)
+	}
+	if ynab.ImportID(cfg, first[0]) != ynab.ImportID(cfg, second[0]) {
+		t.Error("booked transaction got another import ID than when it was pending")
+	}
+}
+
+func TestInRange(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse(ynabber.DateFormat, s)
+		return d
+	}
+	cfg := ynabber.Config{Nordigen: ynabber.Nordigen{
+		DateFrom: ynabber.Date(date("2023-01-01")),
+		DateTo:   ynabber.Date(date("2023-01-31")),
+	}}
+	r := Reader{Config: &cfg}
+
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{date: "2022-12-31", want: false},
+		{date: "2023-01-01", want: true},
+		{date: "2023-01-31", want: true},
+		{date: "2023-02-01", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			if got := r.inRange(date(tt.date)); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	cfg.Nordigen.DateFrom, cfg.Nordigen.DateTo = ynabber.Date{}, ynabber.Date{}
+	if !r.inRange(date("2000-01-01")) {
+		t.Error("want all dates in range without limits")
+	}
+}
+
+func TestExpectedCurrency(t *testing.T) {
+	cfg := ynabber.Config{Nordigen: ynabber.Nordigen{
+		ExpectedCurrency: ynabber.AccountMap{"DK123": "DKK"},
+	}}
+	r := Reader{Config: &cfg}
+
+	tests := []struct {
+		name string
+		t    ynabber.Transaction
+		skip bool
+		want bool
+	}{
+		{name: "match", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, Currency: "dkk"}, want: true},
+		{name: "mismatch warns", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, Currency: "USD"}, want: true},
+		{name: "mismatch skips", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, Currency: "USD"}, skip: true, want: false},
+		{name: "unknown currency", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}}, skip: true, want: true},
+		{name: "no expectation", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "NO123"}, Currency: "USD"}, skip: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Nordigen.SkipCurrencyMismatch = tt.skip
+			if got := r.expectedCurrency(tt.t); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcceptedStatus(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	r := Reader{Config: &cfg}
+
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{status: "READY", want: true},
+		{status: "ready", want: true},
+		{status: "", want: true},
+		{status: "SUSPENDED", want: false},
+		{status: "ERROR", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := r.acceptedStatus(tt.status); got != tt.want {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cfg.Nordigen.AccountStatuses = []string{"READY", "PROCESSING"}
+	if !r.acceptedStatus("PROCESSING") {
+		t.Error("configured status not accepted")
+	}
+}
+
+func TestBookedBalance(t *testing.T) {
+	balance := func(balanceType, amount string) nordigen.AccountBalance {
+		return nordigen.AccountBalance{
+			BalanceType:   balanceType,
+			BalanceAmount: nordigen.AccountBalanceAmount{Amount: amount},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		balances []nordigen.AccountBalance
+		want     ynabber.Milliunits
+		wantErr  bool
+	}{
+		{
+			name:     "preferred",
+			balances: []nordigen.AccountBalance{balance("interimAvailable", "10.00"), balance("expected", "20.00"), balance("closingBooked", "30.00")},
+			want:     30000,
+		},
+		{
+			name:     "fallback",
+			balances: []nordigen.AccountBalance{balance("interimAvailable", "10.00"), balance("expected", "-20.50")},
+			want:     -20500,
+		},
+		{
+			name:     "exact",
+			balances: []nordigen.AccountBalance{balance("closingBooked", "1.0005")},
+			want:     1001,
+		},
+		{
+			name:     "none",
+			balances: []nordigen.AccountBalance{balance("interimAvailable", "10.00")},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid",
+			balances: []nordigen.AccountBalance{balance("closingBooked", "abc")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bookedBalance(nordigen.AccountBalances{Balances: tt.balances})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoTemplate(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	r := Reader{Config: &cfg}
+
+	transaction := nordigen.Transaction{
+		TransactionId:                     "123",
+		BookingDate:                       "2023-02-24",
+		CreditorName:                      "Netto",
+		RemittanceInformationUnstructured: "Groceries",
+	}
+	transaction.TransactionAmount.Amount = "-10"
+
+	got, err := r.toYnabber(ynabber.Account{}, transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Memo != "Groceries" {
+		t.Errorf("without template got memo %q, want the remittance", got.Memo)
+	}
+
+	if err := cfg.Nordigen.MemoTemplate.Decode("{{.CreditorName}} | {{.RemittanceInformationUnstructured}} | ref:{{.TransactionId}}"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.toYnabber(ynabber.Account{}, transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Netto | Groceries | ref:123"; got.Memo != want {
+		t.Errorf("got memo %q, want %q", got.Memo, want)
+	}
+
+	if err := cfg.Nordigen.MemoTemplate.Decode("{{.NoSuchField}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.toYnabber(ynabber.Account{}, transaction); err == nil {
+		t.Error("want error for unknown field")
+	}
+}
+
+func TestDebitPositive(t *testing.T) {
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	r := Reader{Config: &cfg}
+
+	transaction := nordigen.Transaction{TransactionId: "foo", BookingDate: "2023-02-24"}
+	transaction.TransactionAmount.Amount = "12.50"
+
+	for _, tt := range []struct {
+		debitPositive bool
+		want          ynabber.Milliunits
+	}{
+		{debitPositive: false, want: 12500},
+		{debitPositive: true, want: -12500},
+	} {
+		cfg.Nordigen.DebitPositive = tt.debitPositive
+		got, err := r.toYnabber(ynabber.Account{}, transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Amount != tt.want {
+			t.Errorf("debit positive %t: got %s, want %s", tt.debitPositive, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestCheckSigns(t *testing.T) {
+	transactions := func(inflow, outflow int) []ynabber.Transaction {
+		var t []ynabber.Transaction
+		for i := 0; i < inflow; i++ {
+			t = append(t, ynabber.Transaction{Amount: 1000})
+		}
+		for i := 0; i < outflow; i++ {
+			t = append(t, ynabber.Transaction{Amount: -1000})
+		}
+		return t
+	}
+	tests := []struct {
+		name    string
+		inflow  int
+		outflow int
+		warn    bool
+	}{
+		{name: "too few", inflow: 5},
+		{name: "mixed", inflow: 3, outflow: 20},
+		{name: "only outflow", outflow: 20},
+		{name: "only inflow", inflow: 20, warn: true},
+		{name: "nearly only inflow", inflow: 19, outflow: 1, warn: true},
+	}
+
+	// The warning is only logged, so read it from the log
+	var log bytes.Buffer
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	slog.SetDefault(slog.New(slog.NewTextHandler(&log, nil)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log.Reset()
+			checkSigns(ynabber.Account{Name: "foo"}, transactions(tt.inflow, tt.outflow))
+			if got := strings.Contains(log.String(), "level=WARN"); got != tt.warn {
+				t.Errorf("got warning %t, want %t: %s", got, tt.warn, log.String())
+			}
+		})
+	}
+}
+
+func TestMapTransactionsSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+
+	var cfg ynabber.Config
+	_ = envconfig.Process("", &cfg)
+	r := Reader{Config: &cfg}
+
+	var transactions nordigen.AccountTransactions
+	transactions.Transactions.Booked = []nordigen.Transaction{{TransactionId: "foo", BookingDate: "2023-02-24"}}
+	transactions.Transactions.Booked[0].TransactionAmount.Amount = "-10"
+	if _, err := r.mapTransactions(context.Background(), ynabber.Account{}, transactions); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "mapper nordigen.Default" {
+		t.Fatalf("got %d spans, want the mapper span", len(spans))
+	}
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "ynabber.transactions" && attr.Value.AsInt64() != 1 {
+			t.Errorf("got %d transactions, want 1", attr.Value.AsInt64())
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package ofx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Reader reads transactions from the OFX and QFX files in a directory
+type Reader struct {
+	Config *ynabber.Config
+
+	// read are the hashes of the files read by the last Bulk, they are
+	// recorded as imported by Commit
+	read *map[string]string
+}
+
+// NewReader returns a new OFX reader
+func NewReader(cfg *ynabber.Config) Reader {
+	return Reader{Config: cfg, read: new(map[string]string)}
+}
+
+// importedFile returns a clean path to the file of imported files in dataDir
+func importedFile(dataDir string) string {
+	return path.Clean(fmt.Sprintf("%s/ofx_imported.json", dataDir))
+}
+
+// readImported returns the names of the imported files by their hash, none
+// if file doesn't exist
+func readImported(file string) (map[string]string, error) {
+	imported := map[string]string{}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return imported, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &imported); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return imported, nil
+}
+
+// element is a tag with its value, the value is empty for aggregates and
+// closing tags
+type element struct {
+	tag   string
+	value string
+}
+
+// elements splits the OFX document in s into elements. Both the SGML format of
+// OFX 1 where elements aren't closed and the XML format of OFX 2 are
+// supported, anything before the first tag like the OFX 1 header is ignored.
+func elements(s string) []element {
+	var e []element
+	for {
+		start := strings.IndexByte(s, '<')
+		if start == -1 {
+			return e
+		}
+		end := strings.IndexByte(s[start:], '>')
+		if end == -1 {
+			return e
+		}
+		tag := strings.ToUpper(strings.TrimSpace(s[start+1 : start+end]))
+		s = s[start+end+1:]
+
+		value := s
+		if next := strings.IndexByte(s, '<'); next != -1 {
+			value = s[:next]
+		}
+		if !strings.HasPrefix(tag, "?") && !strings.HasPrefix(tag, "!") {
+			e = append(e, element{tag: tag, value: html.UnescapeString(strings.TrimSpace(value))})
+		}
+	}
+}
+
+// parseDate parses an OFX date like 20240131, 20240131120000 or
+// 20240131120000.000[-5:EST]. The time zone is ignored since the date is
+// what the bank booked the transaction on.
+func parseDate(s string) (date time.Time, t time.Time, err error) {
+	if i := strings.IndexAny(s, ".["); i != -1 {
+		s = s[:i]
+	}
+	if len(s) < 8 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %q", s)
+	}
+	date, err = time.ParseInLocation("20060102", s[:8], time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	t = date
+	if len(s) >= 14 {
+		if x, err := time.ParseInLocation("20060102150405", s[:14], time.UTC); err == nil {
+			t = x
+		}
+	}
+	return date, t, nil
+}
+
+// parse reads the transactions of all statements in the OFX document in s
+func parse(s string) ([]ynabber.Transaction, error) {
+	var t []ynabber.Transaction
+	var account, currency string
+	var transaction *ynabber.Transaction
+	var posted string
+
+	for _, e := range elements(s) {
+		if transaction == nil {
+			switch e.tag {
+			case "ACCTID":
+				account = e.value
+			case "CURDEF":
+				currency = e.value
+			case "STMTTRN":
+				transaction = &ynabber.Transaction{
+					Account:  ynabber.Account{ID: ynabber.ID(account), Name: account, IBAN: account},
+					Currency: currency,
+				}
+				posted = ""
+			}
+			continue
+		}
+
+		switch e.tag {
+		case "FITID":
+			transaction.ID = ynabber.ID(e.value)
+		case "DTPOSTED":
+			posted = e.value
+		case "TRNAMT":
+			amount, err := ynabber.MilliunitsFromString(strings.ReplaceAll(e.value, ",", "."))
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", transaction.ID, err)
+			}
+			transaction.Amount = amount
+		case "NAME":
+			transaction.Payee = ynabber.Payee(e.value)
+		case "MEMO":
+			transaction.Memo = e.value
+		case "TRNTYPE":
+			transaction.Code = e.value
+		case "/STMTTRN":
+			if transaction.ID == "" {
+				return nil, fmt.Errorf("transaction without FITID on account %s", account)
+			}
+			var err error
+			transaction.Date, transaction.Time, err = parseDate(posted)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", transaction.ID, err)
+			}
+			t = append(t, *transaction)
+			transaction = nil
+		}
+	}
+	return t, nil
+}
+
+// ofxFile reports whether name is an OFX or QFX file
+func ofxFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ofx", ".qfx":
+		return true
+	}
+	return false
+}
+
+// Bulk reads the transactions from all OFX and QFX files in the input dir
+// that aren't imported yet. Files are recognized by the hash of their
+// content, so a file downloaded again with new transactions is read again.
+func (r Reader) Bulk(ctx context.Context) (t []ynabber.Transaction, err error) {
+	dir := r.Config.OFX.Dir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading input dir: %w", err)
+	}
+	imported, err := readImported(importedFile(r.Config.DataDir))
+	if err != nil {
+		return nil, fmt.Errorf("reading imported files: %w", err)
+	}
+
+	read := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !ofxFile(entry.Name()) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		hash := fmt.Sprintf("%x", sha256.Sum256(b))
+		if _, ok := imported[hash]; ok {
+			slog.Debug("Skipping file already imported", "file", entry.Name())
+			continue
+		}
+		x, err := parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", entry.Name(), err)
+		}
+		for i := range x {
+			x[i].Account.Name = r.Config.AccountName(x[i].Account.IBAN)
+		}
+
+		slog.Info("Read transactions", "count", len(x), "file", entry.Name())
+		ynabber.Sort(x)
+		t = append(t, x...)
+		read[hash] = entry.Name()
+	}
+	if r.read != nil {
+		*r.read = read
+	}
+	return t, nil
+}
+
+// Commit records the files read by the last Bulk as imported so they aren't
+// read again. It's called once all writers wrote the transactions, nothing is
+// recorded on dry runs.
+func (r Reader) Commit(ctx context.Context) error {
+	if r.read == nil || len(*r.read) == 0 || r.Config.YNAB.DryRun {
+		return nil
+	}
+	file := importedFile(r.Config.DataDir)
+	imported, err := readImported(file)
+	if err != nil {
+		return err
+	}
+	for hash, name := range *r.read {
+		imported[hash] = name
+	}
+	b, err := json.MarshalIndent(imported, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated
+	// list behind
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+	*r.read = nil
+	return nil
+}
@@ -0,0 +1,179 @@
+package ofx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// sgml is an OFX 1 document with unclosed elements
+const sgml = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>121000248<ACCTID>123456789<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240131120000.000[-5:EST]
+<TRNAMT>-19.99
+<FITID>2024013101
+<NAME>NETFLIX &amp; CO
+<MEMO>Subscription
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240130
+<TRNAMT>1000,50
+<FITID>2024013001
+<NAME>Salary
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+// xml is an OFX 2 document of a credit card
+const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CURDEF>EUR</CURDEF>
+<CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240201</DTPOSTED><TRNAMT>-5.00</TRNAMT><FITID>A1</FITID><NAME>Cafe</NAME></STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>
+`
+
+func TestParse(t *testing.T) {
+	checking := ynabber.Account{ID: "123456789", Name: "123456789", IBAN: "123456789"}
+	card := ynabber.Account{ID: "4111", Name: "4111", IBAN: "4111"}
+	tests := []struct {
+		name string
+		doc  string
+		want []ynabber.Transaction
+	}{
+		{name: "sgml", doc: sgml, want: []ynabber.Transaction{
+			{
+				Account:  checking,
+				ID:       "2024013101",
+				Date:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+				Time:     time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+				Payee:    "NETFLIX & CO",
+				Memo:     "Subscription",
+				Amount:   -19990,
+				Code:     "DEBIT",
+				Currency: "USD",
+			},
+			{
+				Account:  checking,
+				ID:       "2024013001",
+				Date:     time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+				Time:     time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC),
+				Payee:    "Salary",
+				Amount:   1000500,
+				Code:     "CREDIT",
+				Currency: "USD",
+			},
+		}},
+		{name: "xml", doc: xml, want: []ynabber.Transaction{
+			{
+				Account:  card,
+				ID:       "A1",
+				Date:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				Time:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				Payee:    "Cafe",
+				Amount:   -5000,
+				Code:     "DEBIT",
+				Currency: "EUR",
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parse("<OFX><STMTTRN><TRNAMT>1.00</STMTTRN></OFX>"); err == nil {
+		t.Error("want error for transaction without FITID")
+	}
+}
+
+func TestBulk(t *testing.T) {
+	dir := t.TempDir()
+	for name, doc := range map[string]string{"checking.ofx": sgml, "card.QFX": xml, "notes.txt": "not ofx"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := ynabber.Config{
+		DataDir:        t.TempDir(),
+		OFX:            ynabber.OFX{Dir: dir},
+		AccountAliases: ynabber.AccountMap{"4111": "Credit card"},
+	}
+	r := NewReader(&cfg)
+
+	got, err := r.Bulk(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d transactions, want 3", len(got))
+	}
+	if got[0].Account.Name != "Credit card" {
+		t.Errorf("got account name %q, want the alias", got[0].Account.Name)
+	}
+
+	// The files are left in place and read again until they are committed
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("other files must be left alone: %s", err)
+	}
+	again, err := r.Bulk(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, got) {
+		t.Errorf("got %+v on second read, want %+v", again, got)
+	}
+
+	// Dry runs don't commit
+	cfg.YNAB.DryRun = true
+	if err := r.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if again, err = r.Bulk(context.Background()); err != nil || len(again) != 3 {
+		t.Errorf("got %d transactions and %v after dry run commit, want 3", len(again), err)
+	}
+
+	cfg.YNAB.DryRun = false
+	if err := r.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if again, err = r.Bulk(context.Background()); err != nil || len(again) != 0 {
+		t.Errorf("got %d transactions and %v after commit, want none", len(again), err)
+	}
+
+	// A file downloaded again with new content is read
+	if err := os.WriteFile(filepath.Join(dir, "checking.ofx"), []byte(strings.Replace(sgml, "</OFX>", "\n</OFX>", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if again, err = r.Bulk(context.Background()); err != nil || len(again) == 0 {
+		t.Errorf("got %d transactions and %v from changed file, want some", len(again), err)
+	}
+}
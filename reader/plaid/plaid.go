@@ -0,0 +1,212 @@
+package plaid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// environments are the Plaid API hosts by environment, tests replace them
+// with a local server
+var environments = map[string]string{
+	"sandbox":     "https://sandbox.plaid.com",
+	"development": "https://development.plaid.com",
+	"production":  "https://production.plaid.com",
+}
+
+// pageSize is the number of transactions requested at a time, 500 is the
+// maximum Plaid allows
+const pageSize = 500
+
+// Reader reads transactions from the banks linked to Plaid
+type Reader struct {
+	Config *ynabber.Config
+}
+
+// Account is a Plaid account
+type Account struct {
+	AccountID string `json:"account_id"`
+	Name      string `json:"name"`
+	Mask      string `json:"mask"`
+}
+
+// Transaction is a Plaid transaction
+type Transaction struct {
+	TransactionID   string  `json:"transaction_id"`
+	AccountID       string  `json:"account_id"`
+	Amount          float64 `json:"amount"`
+	IsoCurrencyCode string  `json:"iso_currency_code"`
+	Date            string  `json:"date"`
+	Name            string  `json:"name"`
+	MerchantName    string  `json:"merchant_name"`
+	Pending         bool    `json:"pending"`
+}
+
+type transactionsRequest struct {
+	ClientID    string `json:"client_id"`
+	Secret      string `json:"secret"`
+	AccessToken string `json:"access_token"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Options     struct {
+		Count  int `json:"count"`
+		Offset int `json:"offset"`
+	} `json:"options"`
+}
+
+type transactionsResponse struct {
+	Accounts          []Account     `json:"accounts"`
+	Transactions      []Transaction `json:"transactions"`
+	TotalTransactions int           `json:"total_transactions"`
+}
+
+// Mapper transforms a Plaid transaction to Ynabber
+type Mapper interface {
+	Map(ynabber.Account, Transaction) (ynabber.Transaction, error)
+}
+
+// Mapper returns the mapper to transform the transactions with
+func (r Reader) Mapper() Mapper {
+	return Default{}
+}
+
+// Default mapping for all banks
+type Default struct{}
+
+// Map t using the default mapper. Plaid reports outflow as a positive amount
+// so the sign is flipped to match Ynabber.
+func (mapper Default) Map(a ynabber.Account, t Transaction) (ynabber.Transaction, error) {
+	date, err := time.ParseInLocation(ynabber.DateFormat, t.Date, time.UTC)
+	if err != nil {
+		return ynabber.Transaction{}, fmt.Errorf("transaction %s: %w", t.TransactionID, err)
+	}
+	payee := t.MerchantName
+	if payee == "" {
+		payee = t.Name
+	}
+	return ynabber.Transaction{
+		Account:  a,
+		ID:       ynabber.ID(t.TransactionID),
+		Date:     date,
+		Time:     date,
+		Payee:    ynabber.Payee(payee),
+		Memo:     t.Name,
+		Amount:   ynabber.MilliunitsFromAmount(t.Amount).Negate(),
+		Pending:  t.Pending,
+		Currency: t.IsoCurrencyCode,
+	}, nil
+}
+
+// transactions gets a page of the transactions of the item with token
+func (r Reader) transactions(ctx context.Context, token string, start, end time.Time, offset int) (transactionsResponse, error) {
+	host, ok := environments[r.Config.Plaid.Environment]
+	if !ok {
+		return transactionsResponse{}, fmt.Errorf("unknown environment: %s", r.Config.Plaid.Environment)
+	}
+
+	request := transactionsRequest{
+		ClientID:    r.Config.Plaid.ClientID,
+		Secret:      r.Config.Plaid.Secret,
+		AccessToken: token,
+		StartDate:   start.Format(ynabber.DateFormat),
+		EndDate:     end.Format(ynabber.DateFormat),
+	}
+	request.Options.Count = pageSize
+	request.Options.Offset = offset
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return transactionsResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, host+"/transactions/get", bytes.NewBuffer(payload))
+	if err != nil {
+		return transactionsResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return transactionsResponse{}, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return transactionsResponse{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return transactionsResponse{}, fmt.Errorf("failed to get transactions: %s: %s", res.Status, body)
+	}
+
+	var response transactionsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return transactionsResponse{}, fmt.Errorf("parsing response: %w", err)
+	}
+	return response, nil
+}
+
+// item reads the transactions of the item, a bank login, with token
+func (r Reader) item(ctx context.Context, token string) ([]ynabber.Transaction, error) {
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, -r.Config.Plaid.Days)
+
+	var t []ynabber.Transaction
+	accounts := map[string]ynabber.Account{}
+	for offset := 0; ; {
+		response, err := r.transactions(ctx, token, start, end, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range response.Accounts {
+			accounts[account.AccountID] = ynabber.Account{
+				ID:   ynabber.ID(account.AccountID),
+				Name: r.Config.AccountName(account.AccountID),
+				IBAN: account.AccountID,
+			}
+		}
+		for _, v := range response.Transactions {
+			if v.Pending && !r.Config.Plaid.IncludePending {
+				continue
+			}
+			transaction, err := r.Mapper().Map(accounts[v.AccountID], v)
+			if err != nil {
+				return nil, err
+			}
+			t = append(t, transaction)
+		}
+
+		offset += len(response.Transactions)
+		if len(response.Transactions) == 0 || offset >= response.TotalTransactions {
+			break
+		}
+	}
+	for _, account := range accounts {
+		ynabber.AccountRead(ctx, account.IBAN)
+	}
+	return t, nil
+}
+
+// Bulk reads the transactions from all items. A failing item doesn't stop the
+// others, the joined errors are returned along with what the others read.
+func (r Reader) Bulk(ctx context.Context) (t []ynabber.Transaction, err error) {
+	var errs []error
+	for i, token := range r.Config.Plaid.AccessTokens {
+		x, err := r.item(ctx, token)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+			continue
+		}
+		slog.Info("Read transactions", "count", len(x), "item", i)
+		ynabber.Sort(x)
+		t = append(t, x...)
+	}
+	return t, errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package plaid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestMap(t *testing.T) {
+	account := ynabber.Account{ID: "acc", Name: "Checking", IBAN: "acc"}
+	got, err := Default{}.Map(account, Transaction{
+		TransactionID:   "tx1",
+		AccountID:       "acc",
+		Amount:          12.34,
+		IsoCurrencyCode: "USD",
+		Date:            "2024-01-31",
+		Name:            "STARBUCKS 1234 SEATTLE",
+		MerchantName:    "Starbucks",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ynabber.Transaction{
+		Account:  account,
+		ID:       "tx1",
+		Date:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Time:     time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Payee:    "Starbucks",
+		Memo:     "STARBUCKS 1234 SEATTLE",
+		Amount:   -12340,
+		Currency: "USD",
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Refunds are negative in Plaid and become inflow
+	got, err = Default{}.Map(account, Transaction{TransactionID: "tx2", Amount: -5, Date: "2024-01-31", Name: "Refund"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Amount != 5000 || got.Payee != "Refund" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	// Serve the transactions one page at a time
+	all := []Transaction{
+		{TransactionID: "a", AccountID: "acc", Amount: 1, Date: "2024-01-02", Name: "A"},
+		{TransactionID: "b", AccountID: "acc", Amount: 2, Date: "2024-01-01", Name: "B"},
+		{TransactionID: "c", AccountID: "acc", Amount: 3, Date: "2024-01-03", Name: "C", Pending: true},
+	}
+	var requests []transactionsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/transactions/get" {
+			http.NotFound(w, req)
+			return
+		}
+		var request transactionsRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, request)
+		if request.AccessToken != "token" {
+			http.Error(w, `{"error_code": "INVALID_ACCESS_TOKEN"}`, http.StatusBadRequest)
+			return
+		}
+		end := min(request.Options.Offset+2, len(all))
+		json.NewEncoder(w).Encode(transactionsResponse{
+			Accounts:          []Account{{AccountID: "acc", Name: "Plaid Checking"}},
+			Transactions:      all[request.Options.Offset:end],
+			TotalTransactions: len(all),
+		})
+	}))
+	defer server.Close()
+	defer func(url string) { environments["sandbox"] = url }(environments["sandbox"])
+	environments["sandbox"] = server.URL
+
+	cfg := ynabber.Config{
+		Plaid: ynabber.Plaid{
+			ClientID:     "id",
+			Secret:       "secret",
+			AccessTokens: []string{"token", "expired"},
+			Environment:  "sandbox",
+			Days:         30,
+		},
+		AccountAliases: ynabber.AccountMap{"acc": "Checking"},
+	}
+	got, err := Reader{Config: &cfg}.Bulk(context.Background())
+	if err == nil {
+		t.Error("want error for the expired item")
+	}
+
+	if len(got) != 2 || got[0].ID != "b" || got[1].ID != "a" {
+		t.Fatalf("got %+v, want the posted transactions sorted by date", got)
+	}
+	if got[0].Account.Name != "Checking" || got[0].Account.IBAN != "acc" {
+		t.Errorf("got account %+v", got[0].Account)
+	}
+	if len(requests) != 3 || requests[1].Options.Offset != 2 || requests[0].ClientID != "id" {
+		t.Errorf("got requests %+v", requests)
+	}
+}
@@ -0,0 +1,88 @@
+package ynabber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// AccountState is the outcome of the last successful write of an account
+type AccountState struct {
+	// LastSuccess is when transactions of the account were last written by
+	// all writers without error
+	LastSuccess time.Time `json:"last_success"`
+	// Transactions is the number of transactions written at LastSuccess
+	Transactions int `json:"transactions"`
+}
+
+// State maps IBAN to the state of the account
+type State map[string]AccountState
+
+// accountsReadKey is the context key of the accounts read during a run
+type accountsReadKey struct{}
+
+// WithAccountsRead returns a copy of ctx in which readers report the IBANs of
+// the accounts they read to read, see AccountRead
+func WithAccountsRead(ctx context.Context, read map[string]bool) context.Context {
+	return context.WithValue(ctx, accountsReadKey{}, read)
+}
+
+// AccountRead reports that the account with iban was read without error, even
+// if it had no new transactions, so its state is kept up to date. Nothing
+// happens if ctx isn't from WithAccountsRead.
+func AccountRead(ctx context.Context, iban string) {
+	if read, ok := ctx.Value(accountsReadKey{}).(map[string]bool); ok {
+		read[iban] = true
+	}
+}
+
+// StateFile returns a clean path to the state file in dataDir
+func StateFile(dataDir string) string {
+	return path.Clean(fmt.Sprintf("%s/state.json", dataDir))
+}
+
+// ReadState reads the state from file, a missing file is an empty state
+func ReadState(file string) (State, error) {
+	state := State{}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return state, nil
+}
+
+// UpdateState records a successful write at now of the number of transactions
+// in written by IBAN to the state in file. Other accounts are kept as is.
+func UpdateState(file string, written map[string]int, now time.Time) error {
+	if len(written) == 0 {
+		return nil
+	}
+	state, err := ReadState(file)
+	if err != nil {
+		return err
+	}
+	for iban, transactions := range written {
+		state[iban] = AccountState{LastSuccess: now.UTC(), Transactions: transactions}
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated
+	// state behind
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,44 @@
+package ynabber
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	file := StateFile(t.TempDir())
+
+	state, err := ReadState(file)
+	if err != nil {
+		t.Fatalf("reading missing state: %s", err)
+	}
+	if len(state) != 0 {
+		t.Errorf("got %v, want empty state", state)
+	}
+
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	if err := UpdateState(file, map[string]int{"DK1": 3, "DK2": 1}, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateState(file, map[string]int{"DK1": 2}, second); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = ReadState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := State{
+		"DK1": {LastSuccess: second, Transactions: 2},
+		"DK2": {LastSuccess: first, Transactions: 1},
+	}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("got %+v, want %+v", state, want)
+	}
+	if got := StateFile("data/"); got != filepath.Join("data", "state.json") {
+		t.Errorf("StateFile() = %s", got)
+	}
+}
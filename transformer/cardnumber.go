@@ -0,0 +1,79 @@
+package transformer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/martinohansen/ynabber"
+)
+
+var (
+	// maskedToken matches a part of a card number with masked digits, for
+	// example 1234****5678, *1234 or XXXX-XXXX-XXXX-1234
+	maskedToken = regexp.MustCompile(`^[0-9*xX#•-]{4,}$`)
+	// maskChars matches the characters used to mask digits
+	maskChars = regexp.MustCompile(`[*#•]|[xX]{2,}`)
+	// digitToken matches a group of digits that is part of a masked card
+	// number if next to a masked token, for example 1234 in XXXX XXXX 1234
+	digitToken = regexp.MustCompile(`^[0-9]{4}$`)
+	// terminalToken matches the words card terminals put in front of the
+	// card number, for example POS in POS 1234****5678 TESCO
+	terminalToken = regexp.MustCompile(`(?i)^(POS|VISA|MC|MASTERCARD|MAESTRO|AMEX|DANKORT|VISA/DANKORT|CARD|DEBIT|CREDIT|KORT|KORTKØB):?$`)
+)
+
+// CardNumber strips masked card numbers from the payee
+type CardNumber struct{}
+
+// Payee returns payee without masked card numbers and the card terminal
+// words in front of them. If nothing but the card number is left the payee is
+// returned as is.
+func (c CardNumber) Payee(payee ynabber.Payee) ynabber.Payee {
+	tokens := strings.Fields(string(payee))
+
+	masked := make([]bool, len(tokens))
+	for i, token := range tokens {
+		masked[i] = maskedToken.MatchString(token) && maskChars.MatchString(token)
+	}
+
+	// Groups of digits are only part of the card number when next to a
+	// masked group, otherwise they could be a store number
+	for changed := true; changed; {
+		changed = false
+		for i, token := range tokens {
+			if masked[i] || !digitToken.MatchString(token) {
+				continue
+			}
+			if (i > 0 && masked[i-1]) || (i < len(tokens)-1 && masked[i+1]) {
+				masked[i] = true
+				changed = true
+			}
+		}
+	}
+
+	// The words of the card terminal in front of the card number aren't part
+	// of the merchant either
+	for i := len(tokens) - 2; i >= 0; i-- {
+		if !masked[i] && masked[i+1] && terminalToken.MatchString(tokens[i]) {
+			masked[i] = true
+		}
+	}
+
+	var merchant []string
+	for i, token := range tokens {
+		if !masked[i] {
+			merchant = append(merchant, token)
+		}
+	}
+	if len(merchant) == 0 {
+		return payee
+	}
+	return ynabber.Payee(strings.Join(merchant, " "))
+}
+
+// Transform strips masked card numbers from the payee of t
+func (c CardNumber) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	for i := range t {
+		t[i].Payee = c.Payee(t[i].Payee)
+	}
+	return t
+}
@@ -0,0 +1,37 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestCardNumberPayee(t *testing.T) {
+	tests := []struct {
+		payee ynabber.Payee
+		want  ynabber.Payee
+	}{
+		{payee: "POS 1234****5678 TESCO", want: "TESCO"},
+		{payee: "VISA DEBIT 1234****5678 TESCO", want: "TESCO"},
+		{payee: "TESCO POS 1234****5678", want: "TESCO"},
+		{payee: "POS TESCO", want: "POS TESCO"},
+		{payee: "************1234 NETTO", want: "NETTO"},
+		{payee: "NETTO XXXX XXXX XXXX 1234", want: "NETTO"},
+		{payee: "VISA *1234 SPOTIFY", want: "SPOTIFY"},
+		{payee: "4571-10XX-XXXX-1234 IKEA", want: "IKEA"},
+		{payee: "4571 10## #### 1234 IKEA", want: "IKEA"},
+		{payee: "Føtex ••••1234", want: "Føtex"},
+		{payee: "7-ELEVEN 1234", want: "7-ELEVEN 1234"},
+		{payee: "ROOM 1234 5678", want: "ROOM 1234 5678"},
+		{payee: "XBOX LIVE", want: "XBOX LIVE"},
+		{payee: "XXL SPORT", want: "XXL SPORT"},
+		{payee: "****1234", want: "****1234"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.payee), func(t *testing.T) {
+			if got := (CardNumber{}).Payee(tt.payee); got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
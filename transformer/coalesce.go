@@ -0,0 +1,102 @@
+package transformer
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Coalesce merges transactions that a merchant split into multiple card
+// transactions. Transactions on the same account with the same payee and
+// date, made within Window of the first, are merged into one with the summed
+// amount. The amounts of the parts are kept in the memo.
+//
+// Transactions without a time of day, i.e. where the reader only knows the
+// date, are never merged since anything on the same date would match.
+//
+// The merged transaction keeps the ID of the first part, so it must never be
+// written before all its parts are read. Transactions are therefore held back
+// until Window has passed since the first part, the readers read them again
+// on the next run. A part the bank reports after a run where the window had
+// closed isn't merged with parts already written.
+type Coalesce struct {
+	Window time.Duration
+
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// Transform merges the split transactions in t, the merged transaction takes
+// the place and ID of the first part. Transactions whose window hasn't closed
+// yet are left out.
+func (c Coalesce) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	type group struct {
+		index int
+		first time.Time
+		parts []ynabber.Milliunits
+	}
+
+	y := make([]ynabber.Transaction, 0, len(t))
+	open := map[string]*group{}
+	var groups []*group
+	for _, v := range t {
+		if v.Time.Equal(v.Date) {
+			y = append(y, v)
+			continue
+		}
+
+		key := strings.Join([]string{v.Account.IBAN, string(v.Payee), v.Date.Format(ynabber.DateFormat)}, "\x00")
+		if g, ok := open[key]; ok {
+			if d := v.Time.Sub(g.first); d >= -c.Window && d <= c.Window {
+				y[g.index].Amount += v.Amount
+				g.parts = append(g.parts, v.Amount)
+				continue
+			}
+		}
+
+		g := &group{index: len(y), first: v.Time, parts: []ynabber.Milliunits{v.Amount}}
+		open[key] = g
+		groups = append(groups, g)
+		y = append(y, v)
+	}
+
+	now := c.Now()
+	held := map[int]bool{}
+	merged, into := 0, 0
+	for _, g := range groups {
+		if g.first.Add(c.Window).After(now) {
+			held[g.index] = true
+			continue
+		}
+		if len(g.parts) < 2 {
+			continue
+		}
+		merged += len(g.parts)
+		into += 1
+
+		parts := make([]string, len(g.parts))
+		for i, part := range g.parts {
+			parts[i] = part.MajorString()
+		}
+		memo := fmt.Sprintf("(%s)", strings.Join(parts, " + "))
+		y[g.index].Memo = strings.TrimSpace(y[g.index].Memo + " " + memo)
+	}
+
+	if len(held) > 0 {
+		kept := make([]ynabber.Transaction, 0, len(y)-len(held))
+		for i, v := range y {
+			if !held[i] {
+				kept = append(kept, v)
+			}
+		}
+		y = kept
+		slog.Info("Held back transactions until their coalesce window closes", "count", len(held))
+	}
+	if merged > 0 {
+		slog.Info("Coalesced split transactions", "count", merged, "into", into)
+	}
+	return y
+}
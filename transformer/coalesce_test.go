@@ -0,0 +1,77 @@
+package transformer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestCoalesce(t *testing.T) {
+	date := time.Date(2023, 11, 4, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return date.Add(12*time.Hour + time.Duration(seconds)*time.Second) }
+	account := ynabber.Account{IBAN: "foobar"}
+	later := func() time.Time { return date.Add(48 * time.Hour) }
+
+	t.Run("merges within window", func(t *testing.T) {
+		got := Coalesce{Window: 2 * time.Second, Now: later}.Transform([]ynabber.Transaction{
+			{Account: account, ID: "1", Date: date, Time: at(0), Payee: "IKEA", Memo: "Card", Amount: -100000},
+			{Account: account, ID: "2", Date: date, Time: at(2), Payee: "IKEA", Amount: -25500},
+			{Account: account, ID: "3", Date: date, Time: at(3), Payee: "IKEA", Amount: -1000},
+		})
+		if len(got) != 2 {
+			t.Fatalf("got %d transactions, want 2", len(got))
+		}
+		if got[0].ID != "1" || got[0].Amount != -125500 {
+			t.Errorf("got %s with %s, want 1 with -125500", got[0].ID, got[0].Amount)
+		}
+		if want := "Card (-100.00 + -25.50)"; got[0].Memo != want {
+			t.Errorf("got memo %q, want %q", got[0].Memo, want)
+		}
+		if got[1].ID != "3" || got[1].Amount != -1000 || got[1].Memo != "" {
+			t.Errorf("transaction outside window changed: %+v", got[1])
+		}
+	})
+
+	t.Run("keeps different payees and accounts", func(t *testing.T) {
+		got := Coalesce{Window: time.Minute, Now: later}.Transform([]ynabber.Transaction{
+			{Account: account, ID: "1", Date: date, Time: at(0), Payee: "IKEA", Amount: -1000},
+			{Account: account, ID: "2", Date: date, Time: at(0), Payee: "NETTO", Amount: -1000},
+			{Account: ynabber.Account{IBAN: "other"}, ID: "3", Date: date, Time: at(0), Payee: "IKEA", Amount: -1000},
+		})
+		if len(got) != 3 {
+			t.Errorf("got %d transactions, want 3", len(got))
+		}
+	})
+
+	t.Run("keeps transactions without time", func(t *testing.T) {
+		got := Coalesce{Window: time.Minute, Now: later}.Transform([]ynabber.Transaction{
+			{Account: account, ID: "1", Date: date, Time: date, Payee: "IKEA", Amount: -1000},
+			{Account: account, ID: "2", Date: date, Time: date, Payee: "IKEA", Amount: -1000},
+		})
+		if len(got) != 2 {
+			t.Errorf("got %d transactions, want 2", len(got))
+		}
+	})
+
+	t.Run("holds back open window", func(t *testing.T) {
+		now := func() time.Time { return at(30) }
+		got := Coalesce{Window: time.Minute, Now: now}.Transform([]ynabber.Transaction{
+			{Account: account, ID: "1", Date: date, Time: at(-120), Payee: "IKEA", Amount: -1000},
+			{Account: account, ID: "2", Date: date, Time: at(0), Payee: "IKEA", Amount: -1000},
+			{Account: account, ID: "3", Date: date, Time: date, Payee: "IKEA", Amount: -1000},
+		})
+		if len(got) != 2 || got[0].ID != "1" || got[1].ID != "3" {
+			t.Errorf("got %+v, want transactions 1 and 3", got)
+		}
+
+		// The next run reads the parts again once the window has closed
+		got = Coalesce{Window: time.Minute, Now: later}.Transform([]ynabber.Transaction{
+			{Account: account, ID: "2", Date: date, Time: at(0), Payee: "IKEA", Amount: -1000},
+			{Account: account, ID: "4", Date: date, Time: at(40), Payee: "IKEA", Amount: -500},
+		})
+		if len(got) != 1 || got[0].ID != "2" || got[0].Amount != -1500 {
+			t.Errorf("got %+v, want 2 with -1500", got)
+		}
+	})
+}
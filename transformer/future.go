@@ -0,0 +1,77 @@
+package transformer
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Policies for transactions dated in the future
+const (
+	FutureKeep  = "keep"
+	FutureSkip  = "skip"
+	FutureClamp = "clamp"
+)
+
+// Future handles transactions dated after today according to Policy
+type Future struct {
+	Policy string
+
+	// Now returns the current time, the location of the time decides what
+	// today is
+	Now func() time.Time
+}
+
+// NewFuture returns a Future transformer using policy and the local time
+func NewFuture(policy string) (Future, error) {
+	switch policy {
+	case FutureKeep, FutureSkip, FutureClamp:
+		return Future{Policy: policy, Now: time.Now}, nil
+	default:
+		return Future{}, fmt.Errorf("unknown policy: %s", policy)
+	}
+}
+
+// today returns the current date as a UTC timestamp like the transaction
+// dates
+func (f Future) today() time.Time {
+	y, m, d := f.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Transform skips or clamps transactions dated after today. A clamped
+// transaction keeps its date as read in Original, which the YNAB writer makes
+// the import ID from, so it isn't imported again once its date comes.
+func (f Future) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	today := f.today()
+
+	y := make([]ynabber.Transaction, 0, len(t))
+	future := 0
+	for _, v := range t {
+		if v.Date.After(today) {
+			future += 1
+			switch f.Policy {
+			case FutureSkip:
+				continue
+			case FutureClamp:
+				// Original may be shared with other copies of the
+				// transaction, so it's replaced rather than changed
+				original := ynabber.Original{Payee: v.Payee, Memo: v.Memo}
+				if v.Original != nil {
+					original = *v.Original
+				}
+				original.Date = v.ReadDate()
+				v.Original = &original
+				v.Date = today
+			}
+		}
+		y = append(y, v)
+	}
+
+	if future > 0 {
+		slog.Info("Found transactions dated in the future", "count", future, "policy", f.Policy)
+	}
+	return y
+}
@@ -0,0 +1,66 @@
+package transformer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestFutureTransform(t *testing.T) {
+	// 23:30 in Copenhagen is still the 24th locally but the 25th is the
+	// future
+	cph, err := time.LoadLocation("Europe/Copenhagen")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := func() time.Time { return time.Date(2023, 2, 24, 23, 30, 0, 0, cph) }
+
+	today := ynabber.Transaction{ID: "today", Date: time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC)}
+	tomorrow := ynabber.Transaction{ID: "tomorrow", Date: time.Date(2023, 2, 25, 0, 0, 0, 0, time.UTC)}
+	clamped := tomorrow
+	clamped.Date = today.Date
+	clamped.Original = &ynabber.Original{Date: tomorrow.Date}
+
+	tests := []struct {
+		policy string
+		want   []ynabber.Transaction
+	}{
+		{policy: FutureKeep, want: []ynabber.Transaction{today, tomorrow}},
+		{policy: FutureSkip, want: []ynabber.Transaction{today}},
+		{policy: FutureClamp, want: []ynabber.Transaction{today, clamped}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			f := Future{Policy: tt.policy, Now: now}
+			got := f.Transform([]ynabber.Transaction{today, tomorrow})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFuture(t *testing.T) {
+	for _, policy := range []string{"ignore", "Clamp"} {
+		if _, err := NewFuture(policy); err == nil {
+			t.Errorf("expected error for unknown policy %s", policy)
+		}
+	}
+}
+
+func TestFutureClampReadDate(t *testing.T) {
+	now := func() time.Time { return time.Date(2023, 2, 24, 12, 0, 0, 0, time.UTC) }
+	read := time.Date(2023, 2, 26, 0, 0, 0, 0, time.UTC)
+	original := &ynabber.Original{Payee: "Netto", Date: read}
+	tx := ynabber.Transaction{Date: read, Payee: "NETTO", Original: original}
+
+	got := Future{Policy: FutureClamp, Now: now}.Transform([]ynabber.Transaction{tx})
+	if got[0].ReadDate() != read || got[0].Original.Payee != "Netto" {
+		t.Errorf("got read date %s and original %+v, want %s and the payee kept", got[0].ReadDate(), got[0].Original, read)
+	}
+	if original.Date != read {
+		t.Error("the original of the input was changed")
+	}
+}
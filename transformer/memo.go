@@ -0,0 +1,60 @@
+package transformer
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// MemoExclude drops transactions whose memo matches any of the patterns
+type MemoExclude struct {
+	Patterns []*regexp.Regexp
+}
+
+// NewMemoExclude returns a MemoExclude matching the keywords or regular
+// expressions in patterns regardless of case. If wholeWord is true a pattern
+// must match whole words, i.e. "INTEREST" doesn't match "INTERESTING".
+func NewMemoExclude(patterns []string, wholeWord bool) (MemoExclude, error) {
+	m := MemoExclude{}
+	for _, pattern := range patterns {
+		if wholeWord {
+			pattern = `\b(?:` + pattern + `)\b`
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return MemoExclude{}, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		m.Patterns = append(m.Patterns, re)
+	}
+	return m, nil
+}
+
+// Excluded reports whether the memo matches any of the patterns
+func (m MemoExclude) Excluded(memo string) bool {
+	for _, re := range m.Patterns {
+		if re.MatchString(memo) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transform drops the transactions in t whose memo matches
+func (m MemoExclude) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	y := make([]ynabber.Transaction, 0, len(t))
+	excluded := 0
+	for _, v := range t {
+		if m.Excluded(v.Memo) {
+			excluded += 1
+			continue
+		}
+		y = append(y, v)
+	}
+
+	if excluded > 0 {
+		slog.Info("Excluded transactions by memo keyword", "count", excluded)
+	}
+	return y
+}
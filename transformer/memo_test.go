@@ -0,0 +1,56 @@
+package transformer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestMemoExcluded(t *testing.T) {
+	tests := []struct {
+		name      string
+		patterns  []string
+		wholeWord bool
+		memo      string
+		want      bool
+	}{
+		{name: "keyword", patterns: []string{"INTEREST"}, memo: "Monthly interest payment", want: true},
+		{name: "no match", patterns: []string{"INTEREST"}, memo: "Groceries", want: false},
+		{name: "substring", patterns: []string{"INTEREST"}, memo: "Interesting book", want: true},
+		{name: "whole word", patterns: []string{"INTEREST"}, wholeWord: true, memo: "Interesting book", want: false},
+		{name: "whole word match", patterns: []string{"INTEREST"}, wholeWord: true, memo: "Credit interest Q3", want: true},
+		{name: "regex", patterns: []string{`^OWN TRANSFER \d+`}, memo: "own transfer 1234", want: true},
+		{name: "any pattern", patterns: []string{"FOO", "BAR"}, memo: "bar", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMemoExclude(tt.patterns, tt.wholeWord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Excluded(tt.memo); got != tt.want {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewMemoExclude([]string{"("}, false); err == nil {
+		t.Error("want error for invalid pattern")
+	}
+}
+
+func TestMemoExcludeTransform(t *testing.T) {
+	m, err := NewMemoExclude([]string{"interest"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Transform([]ynabber.Transaction{
+		{ID: "1", Memo: "INTEREST"},
+		{ID: "2", Memo: "Rent"},
+	})
+	want := []ynabber.Transaction{{ID: "2", Memo: "Rent"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package transformer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// maxMemoLength is the memo limit of YNAB, the strictest writer. The memo is
+// shortened to make room for the tag so writers don't truncate the tag away.
+const maxMemoLength = 200
+
+// MemoTag appends a fixed tag to the memo of transactions by account
+type MemoTag struct {
+	// Tags maps IBAN to the tag
+	Tags map[string]string
+}
+
+// Memo returns memo with tag appended, memo is shortened if the result would
+// exceed maxMemoLength characters
+func (m MemoTag) Memo(memo, tag string) string {
+	memo = strings.TrimSpace(memo)
+	if memo == "" {
+		return tag
+	}
+	room := maxMemoLength - utf8.RuneCountInString(tag) - 1
+	if r := []rune(memo); len(r) > room {
+		memo = strings.TrimSpace(string(r[:max(room, 0)]))
+	}
+	return strings.TrimSpace(memo + " " + tag)
+}
+
+// Transform appends the tag of the account to the memo of t
+func (m MemoTag) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	for i := range t {
+		if tag, ok := m.Tags[t[i].Account.IBAN]; ok && tag != "" {
+			t[i].Memo = m.Memo(t[i].Memo, tag)
+		}
+	}
+	return t
+}
@@ -0,0 +1,41 @@
+package transformer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestMemoTagTransform(t *testing.T) {
+	m := MemoTag{Tags: map[string]string{"joint": "[50/50]"}}
+	got := m.Transform([]ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "joint"}, Memo: "Groceries"},
+		{Account: ynabber.Account{IBAN: "joint"}},
+		{Account: ynabber.Account{IBAN: "private"}, Memo: "Groceries"},
+	})
+	want := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "joint"}, Memo: "Groceries [50/50]"},
+		{Account: ynabber.Account{IBAN: "joint"}, Memo: "[50/50]"},
+		{Account: ynabber.Account{IBAN: "private"}, Memo: "Groceries"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoTagTruncation(t *testing.T) {
+	m := MemoTag{}
+	got := m.Memo(strings.Repeat("ø", maxMemoLength), "[Alice]")
+	if n := utf8.RuneCountInString(got); n != maxMemoLength {
+		t.Errorf("got %d characters, want %d", n, maxMemoLength)
+	}
+	if !strings.HasSuffix(got, " [Alice]") {
+		t.Errorf("tag lost: %s", got)
+	}
+	if !strings.HasPrefix(got, "øø") || strings.ContainsRune(got, '�') {
+		t.Errorf("memo mangled: %s", got)
+	}
+}
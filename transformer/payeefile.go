@@ -0,0 +1,80 @@
+package transformer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// PayeeFile renames payees using a CSV file of raw and clean payee pairs. The
+// file is read on every transform so edits take effect on the next run.
+type PayeeFile struct {
+	Path string
+
+	// Prefix also matches payees starting with the raw payee, the longest
+	// matching prefix wins. Exact matches always take precedence.
+	Prefix bool
+}
+
+// load reads the raw to clean payee mapping from the file
+func (p PayeeFile) load() (map[string]string, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	payees := make(map[string]string, len(records))
+	for _, record := range records {
+		payees[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+	}
+	return payees, nil
+}
+
+// Payee returns the clean payee for payee or payee itself if there is no
+// match
+func (p PayeeFile) Payee(payees map[string]string, payee ynabber.Payee) ynabber.Payee {
+	if clean, ok := payees[string(payee)]; ok {
+		return ynabber.Payee(clean)
+	}
+
+	if p.Prefix {
+		match := ""
+		for raw := range payees {
+			if raw != "" && strings.HasPrefix(string(payee), raw) && len(raw) > len(match) {
+				match = raw
+			}
+		}
+		if match != "" {
+			return ynabber.Payee(payees[match])
+		}
+	}
+	return payee
+}
+
+// Transform renames the payees of t according to the file. If the file can't
+// be read the transactions are passed through unchanged.
+func (p PayeeFile) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	payees, err := p.load()
+	if err != nil {
+		slog.Warn("Not renaming payees", "error", fmt.Errorf("reading payee file: %w", err))
+		return t
+	}
+
+	for i := range t {
+		t[i].Payee = p.Payee(payees, t[i].Payee)
+	}
+	return t
+}
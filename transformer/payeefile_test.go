@@ -0,0 +1,56 @@
+package transformer
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestPayeeFileTransform(t *testing.T) {
+	file := path.Join(t.TempDir(), "payees.csv")
+	err := os.WriteFile(file, []byte(`# raw,clean
+NETTO 1234,Netto
+NETTO,Netto (other)
+AMZN Mktp,Amazon
+"SPOTIFY, AB",Spotify
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		prefix bool
+		payee  ynabber.Payee
+		want   ynabber.Payee
+	}{
+		{name: "exact", payee: "NETTO 1234", want: "Netto"},
+		{name: "quoted", payee: "SPOTIFY, AB", want: "Spotify"},
+		{name: "no prefix", payee: "AMZN Mktp DE*1A2B3", want: "AMZN Mktp DE*1A2B3"},
+		{name: "prefix", prefix: true, payee: "AMZN Mktp DE*1A2B3", want: "Amazon"},
+		{name: "longest prefix", prefix: true, payee: "NETTO 12345", want: "Netto"},
+		{name: "exact before prefix", prefix: true, payee: "NETTO", want: "Netto (other)"},
+		{name: "unmatched", prefix: true, payee: "Føtex", want: "Føtex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := PayeeFile{Path: file, Prefix: tt.prefix}
+			got := p.Transform([]ynabber.Transaction{{Payee: tt.payee}})
+			if got[0].Payee != tt.want {
+				t.Errorf("got = %s, want %s", got[0].Payee, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayeeFileMissing(t *testing.T) {
+	p := PayeeFile{Path: path.Join(t.TempDir(), "missing.csv")}
+	want := []ynabber.Transaction{{Payee: "NETTO 1234"}}
+	got := p.Transform([]ynabber.Transaction{{Payee: "NETTO 1234"}})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v, want %+v", got, want)
+	}
+}
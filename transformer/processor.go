@@ -0,0 +1,103 @@
+package transformer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// processors is the built-in list of payment processors that prefix the
+// merchant name in the payee
+var processors = ynabber.PatternMap{
+	{Name: "FastSpring", Pattern: `^FS\s*\*\s*`},
+	{Name: "Paddle", Pattern: `^PADDLE\.NET\s*\*\s*`},
+	{Name: "PayPal", Pattern: `^PAYPAL\s*\*\s*`},
+	{Name: "Square", Pattern: `^SQ\s*\*\s*`},
+	{Name: "Stripe", Pattern: `^STRIPE\s*\*\s*`},
+	{Name: "SumUp", Pattern: `^SUMUP\s*\*\s*`},
+	{Name: "Zettle", Pattern: `^I?ZETTLE_?\s*\*\s*`},
+}
+
+// Prefix is the compiled prefix of a payment processor
+type Prefix struct {
+	Name   string
+	Regexp *regexp.Regexp
+}
+
+// Processor strips payment processor prefixes from the payee. The prefixes
+// are tried in order and the first match wins.
+type Processor struct {
+	Prefixes []Prefix
+}
+
+// NewProcessor returns a processor with the built-in prefixes if builtin is
+// true followed by the additional prefixes from extra in order
+func NewProcessor(builtin bool, extra ynabber.PatternMap) (Processor, error) {
+	var p Processor
+
+	add := func(patterns ynabber.PatternMap) error {
+		for _, v := range patterns {
+			re, err := regexp.Compile("(?i)" + v.Pattern)
+			if err != nil {
+				return fmt.Errorf("processor %s: %w", v.Name, err)
+			}
+			p.Prefixes = append(p.Prefixes, Prefix{Name: v.Name, Regexp: re})
+		}
+		return nil
+	}
+
+	if builtin {
+		if err := add(processors); err != nil {
+			return Processor{}, err
+		}
+	}
+	if err := add(extra); err != nil {
+		return Processor{}, err
+	}
+	return p, nil
+}
+
+// titleCase returns s in title case if s is all upper case, mixed case is
+// assumed to be deliberate and left as is
+func titleCase(s string) string {
+	if strings.ToUpper(s) != s {
+		return s
+	}
+
+	words := strings.Fields(strings.ToLower(s))
+	for i, word := range words {
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+// Payee returns the merchant of p with the processor prefix removed
+func (p Processor) Payee(payee ynabber.Payee) ynabber.Payee {
+	for _, prefix := range p.Prefixes {
+		loc := prefix.Regexp.FindStringIndex(string(payee))
+		if loc == nil {
+			continue
+		}
+
+		merchant := strings.TrimSpace(string(payee)[loc[1]:])
+		if merchant == "" {
+			// Keep the payee if there is nothing but the processor
+			return payee
+		}
+		return ynabber.Payee(titleCase(merchant))
+	}
+	return payee
+}
+
+// Transform strips payment processor prefixes from the payee of t
+func (p Processor) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	for i := range t {
+		t[i].Payee = p.Payee(t[i].Payee)
+	}
+	return t
+}
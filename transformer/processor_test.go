@@ -0,0 +1,77 @@
+package transformer
+
+import (
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestProcessorPayee(t *testing.T) {
+	p, err := NewProcessor(true, ynabber.PatternMap{{Name: "Vipps", Pattern: `^VIPPS\s*\*\s*`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		payee ynabber.Payee
+		want  ynabber.Payee
+	}{
+		{payee: "FS *SUBLIMEHQ", want: "Sublimehq"},
+		{payee: "PADDLE.NET* NOTION", want: "Notion"},
+		{payee: "PAYPAL *SPOTIFY", want: "Spotify"},
+		{payee: "SQ *BLUE BOTTLE COFFEE", want: "Blue Bottle Coffee"},
+		{payee: "STRIPE* Linear", want: "Linear"},
+		{payee: "SUMUP *Bakery", want: "Bakery"},
+		{payee: "IZETTLE *MARKET STALL", want: "Market Stall"},
+		{payee: "VIPPS *Kiosk", want: "Kiosk"},
+		{payee: "paypal *netflix", want: "netflix"},
+		{payee: "PAYPAL *", want: "PAYPAL *"},
+		{payee: "Netto", want: "Netto"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.payee), func(t *testing.T) {
+			if got := p.Payee(tt.payee); got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProcessor(t *testing.T) {
+	// Built-in prefixes can be disabled while keeping the extra ones
+	p, err := NewProcessor(false, ynabber.PatternMap{{Name: "Vipps", Pattern: `^VIPPS\s*\*\s*`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Payee("PAYPAL *SPOTIFY"); got != "PAYPAL *SPOTIFY" {
+		t.Errorf("built-in applied: %s", got)
+	}
+	if got := p.Payee("VIPPS *KIOSK"); got != "Kiosk" {
+		t.Errorf("extra not applied: %s", got)
+	}
+
+	_, err = NewProcessor(false, ynabber.PatternMap{{Name: "broken", Pattern: `(`}})
+	if err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestProcessorOrder(t *testing.T) {
+	// The built-in prefixes come first, then the extra ones in order
+	var extra ynabber.PatternMap
+	if err := extra.Decode(`{"Vipps": "^VIPPS\\s*", "Vipps card": "^VIPPS\\s*CARD\\s*"}`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		p, err := NewProcessor(true, extra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Payee("VIPPS CARD KIOSK"); got != "Card Kiosk" {
+			t.Fatalf("got %s, want the first extra prefix to win", got)
+		}
+		if name := p.Prefixes[len(processors)].Name; name != "Vipps" {
+			t.Fatalf("got %s after the built-in prefixes, want Vipps", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package transformer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// DefaultReferencePattern matches a trailing reference of at least four
+// digits optionally introduced by REF, NR or #, for example "SPOTIFY 998213",
+// "NETFLIX REF: 4455-12" or "UBER #A12345"
+const DefaultReferencePattern = `(?i)\s+(?:ref(?:erence)?[:.]?\s*|nr[:.]?\s*|#\s*)?[a-z]{0,3}\d{4,}[0-9/-]*$`
+
+// Reference strips trailing reference numbers from the payee and optionally
+// moves them to the memo
+type Reference struct {
+	Pattern *regexp.Regexp
+
+	// Memo appends the stripped references to the memo
+	Memo bool
+}
+
+// NewReference returns a Reference stripping pattern from the end of the
+// payee, an empty pattern uses DefaultReferencePattern
+func NewReference(pattern string, memo bool) (Reference, error) {
+	if pattern == "" {
+		pattern = DefaultReferencePattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Reference{}, fmt.Errorf("pattern: %w", err)
+	}
+	return Reference{Pattern: re, Memo: memo}, nil
+}
+
+// Payee returns payee without trailing references and the references that
+// were stripped. The payee is returned as is if nothing else is left.
+func (r Reference) Payee(payee ynabber.Payee) (ynabber.Payee, []string) {
+	merchant := strings.TrimSpace(string(payee))
+
+	var references []string
+	for {
+		loc := r.Pattern.FindStringIndex(merchant)
+		if loc == nil || loc[1] == loc[0] {
+			break
+		}
+		stripped := strings.TrimSpace(merchant[:loc[0]])
+		if stripped == "" {
+			break
+		}
+		references = append([]string{strings.TrimSpace(merchant[loc[0]:])}, references...)
+		merchant = stripped
+	}
+	if len(references) == 0 {
+		return payee, nil
+	}
+	return ynabber.Payee(merchant), references
+}
+
+// Transform strips trailing references from the payee of t
+func (r Reference) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	for i := range t {
+		payee, references := r.Payee(t[i].Payee)
+		t[i].Payee = payee
+		if r.Memo && len(references) > 0 {
+			t[i].Memo = strings.TrimSpace(t[i].Memo + " " + strings.Join(references, " "))
+		}
+	}
+	return t
+}
@@ -0,0 +1,71 @@
+package transformer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestReferencePayee(t *testing.T) {
+	r, err := NewReference("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		payee      ynabber.Payee
+		want       ynabber.Payee
+		references []string
+	}{
+		{payee: "SPOTIFY 998213", want: "SPOTIFY", references: []string{"998213"}},
+		{payee: "SPOTIFY 998765", want: "SPOTIFY", references: []string{"998765"}},
+		{payee: "NETFLIX REF: 4455-12", want: "NETFLIX", references: []string{"REF: 4455-12"}},
+		{payee: "Netflix ref.20231104", want: "Netflix", references: []string{"ref.20231104"}},
+		{payee: "UBER #A12345", want: "UBER", references: []string{"#A12345"}},
+		{payee: "ELECTRICITY NR 0012/2023", want: "ELECTRICITY", references: []string{"NR 0012/2023"}},
+		{payee: "AMAZON 1234 5678", want: "AMAZON", references: []string{"1234", "5678"}},
+		{payee: "IKEA TXN20231104", want: "IKEA", references: []string{"TXN20231104"}},
+		{payee: "STUDIO 54", want: "STUDIO 54"},
+		{payee: "7-ELEVEN", want: "7-ELEVEN"},
+		{payee: "24 HOUR FITNESS", want: "24 HOUR FITNESS"},
+		{payee: "1234567", want: "1234567"},
+		{payee: "ROUTE 66 DINER", want: "ROUTE 66 DINER"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.payee), func(t *testing.T) {
+			got, references := r.Payee(tt.payee)
+			if got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(references, tt.references) {
+				t.Errorf("got references = %q, want %q", references, tt.references)
+			}
+		})
+	}
+}
+
+func TestReferenceTransform(t *testing.T) {
+	r, err := NewReference(`\s+\d+$`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := r.Transform([]ynabber.Transaction{
+		{Payee: "SPOTIFY 12", Memo: "Premium"},
+		{Payee: "SPOTIFY 34"},
+		{Payee: "SPOTIFY", Memo: "Premium"},
+	})
+	want := []ynabber.Transaction{
+		{Payee: "SPOTIFY", Memo: "Premium 12"},
+		{Payee: "SPOTIFY", Memo: "34"},
+		{Payee: "SPOTIFY", Memo: "Premium"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := NewReference("(", false); err == nil {
+		t.Error("want error for invalid pattern")
+	}
+}
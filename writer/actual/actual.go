@@ -0,0 +1,126 @@
+package actual
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+)
+
+// Writer imports transactions into Actual Budget through actual-http-api
+// (https://github.com/jhonderson/actual-http-api)
+type Writer struct {
+	Config *ynabber.Config
+}
+
+// Atransaction is a single Actual transaction
+type Atransaction struct {
+	Account    string `json:"account"`
+	Date       string `json:"date"`
+	Amount     int64  `json:"amount"`
+	PayeeName  string `json:"payee_name"`
+	Notes      string `json:"notes"`
+	ImportedID string `json:"imported_id"`
+	Cleared    bool   `json:"cleared"`
+}
+
+// Atransactions is multiple Actual transactions
+type Atransactions struct {
+	Transactions []Atransaction `json:"transactions"`
+}
+
+// toActual converts t to an Actual transaction. Actual stores amounts in
+// cents and deduplicates imports by imported_id, which uses the same ID as
+// YNAB so switching between the two doesn't import transactions twice.
+func toActual(cfg ynabber.Config, t ynabber.Transaction) (Atransaction, error) {
+	accountID, ok := cfg.Actual.AccountMap[t.Account.IBAN]
+	if !ok {
+		return Atransaction{}, fmt.Errorf("no mapping for account '%s'", t.Account.Name)
+	}
+	return Atransaction{
+		Account:    accountID,
+		Date:       t.Date.Format(ynabber.DateFormat),
+		Amount:     int64(t.Amount) / 10,
+		PayeeName:  string(t.Payee),
+		Notes:      t.Memo,
+		ImportedID: ynab.ImportID(cfg, t),
+		Cleared:    true,
+	}, nil
+}
+
+// post imports t into the Actual account with accountID
+func (w Writer) post(ctx context.Context, accountID string, t []Atransaction) error {
+	payload, err := json.Marshal(Atransactions{Transactions: t})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/budgets/%s/accounts/%s/transactions/import",
+		w.Config.Actual.URL, w.Config.Actual.BudgetID, accountID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("x-api-key", w.Config.Actual.APIKey)
+	if w.Config.Actual.EncryptionPassword != "" {
+		req.Header.Add("budget-encryption-password", w.Config.Actual.EncryptionPassword)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to send request: %s", res.Status)
+	}
+	return nil
+}
+
+// Bulk imports tx into Actual with one request per account. A failing
+// account doesn't stop the others, instead the errors are joined.
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	failed := 0
+	byAccount := map[string][]Atransaction{}
+	for _, t := range tx {
+		a, err := toActual(*w.Config, t)
+		if err != nil {
+			slog.Error("Failed to parse transaction",
+				"account", t.Account.Name, "date", t.Date.Format(ynabber.DateFormat), "error", err)
+			failed += 1
+			continue
+		}
+		byAccount[a.Account] = append(byAccount[a.Account], a)
+	}
+
+	accounts := make([]string, 0, len(byAccount))
+	for account := range byAccount {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	sent := 0
+	var errs []error
+	for _, account := range accounts {
+		if err := w.post(ctx, account, byAccount[account]); err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %w", account, err))
+			failed += len(byAccount[account])
+			continue
+		}
+		sent += len(byAccount[account])
+	}
+
+	slog.Info("Sent transactions to Actual", "sent", sent, "failed", failed)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package actual
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+)
+
+func TestToActual(t *testing.T) {
+	cfg := ynabber.Config{Actual: ynabber.Actual{
+		AccountMap: ynabber.AccountMap{"DK123": "checking"},
+	}}
+	transaction := ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "DK123"},
+		ID:      "1",
+		Date:    time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		Payee:   "Netto",
+		Memo:    "Groceries",
+		Amount:  -99500,
+	}
+
+	got, err := toActual(cfg, transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Atransaction{
+		Account:    "checking",
+		Date:       "2023-05-01",
+		Amount:     -9950,
+		PayeeName:  "Netto",
+		Notes:      "Groceries",
+		ImportedID: ynab.ImportID(cfg, transaction),
+		Cleared:    true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	transaction.Account.IBAN = "unknown"
+	if _, err := toActual(cfg, transaction); err == nil {
+		t.Error("want error for unmapped account")
+	}
+}
+
+func TestBulk(t *testing.T) {
+	imported := map[string][]Atransaction{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body Atransactions
+		json.NewDecoder(r.Body).Decode(&body)
+		imported[r.URL.Path] = append(imported[r.URL.Path], body.Transactions...)
+	}))
+	defer server.Close()
+
+	cfg := ynabber.Config{Actual: ynabber.Actual{
+		URL:        server.URL,
+		APIKey:     "key",
+		BudgetID:   "budget",
+		AccountMap: ynabber.AccountMap{"a": "checking", "b": "savings"},
+	}}
+	tx := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "a"}, ID: "1"},
+		{Account: ynabber.Account{IBAN: "b"}, ID: "2"},
+		{Account: ynabber.Account{IBAN: "a"}, ID: "3"},
+	}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(imported["/v1/budgets/budget/accounts/checking/transactions/import"]); got != 2 {
+		t.Errorf("got %d transactions on checking, want 2", got)
+	}
+	if got := len(imported["/v1/budgets/budget/accounts/savings/transactions/import"]); got != 1 {
+		t.Errorf("got %d transactions on savings, want 1", got)
+	}
+
+	cfg.Actual.APIKey = "wrong"
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), tx); err == nil {
+		t.Error("want error for rejected request")
+	}
+}
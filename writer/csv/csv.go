@@ -0,0 +1,107 @@
+package csv
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+)
+
+var header = []string{"date", "payee", "memo", "amount", "iban", "import_id"}
+
+// Writer writes transactions to a CSV file
+type Writer struct {
+	Config *ynabber.Config
+}
+
+func (w Writer) record(t ynabber.Transaction) []string {
+	return []string{
+		t.Date.Format(ynabber.DateFormat),
+		string(t.Payee),
+		t.Memo,
+		t.Amount.MajorString(),
+		t.Account.IBAN,
+		ynab.ImportID(*w.Config, t),
+	}
+}
+
+// written returns the import IDs of the rows already in the file at path
+func written(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, row := range rows {
+		if len(row) == len(header) {
+			ids[row[len(header)-1]] = true
+		}
+	}
+	return ids, nil
+}
+
+// Bulk writes tx to the configured path, either replacing the file or
+// appending to it. When appending, transactions whose import ID is already
+// in the file are skipped since every run reads the whole date window.
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	var existing map[string]bool
+	if w.Config.CSV.Append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		var err error
+		existing, err = written(w.Config.CSV.Path)
+		if err != nil {
+			return fmt.Errorf("reading file: %w", err)
+		}
+	}
+	f, err := os.OpenFile(w.Config.CSV.Path, flag, 0600)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+
+	writer := csv.NewWriter(f)
+	if info.Size() == 0 {
+		writer.Write(header)
+	}
+	count := 0
+	for _, t := range tx {
+		record := w.record(t)
+		if existing[record[len(record)-1]] {
+			continue
+		}
+		writer.Write(record)
+		count += 1
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+
+	slog.Info("Wrote transactions", "count", count, "skipped", len(tx)-count, "file", w.Config.CSV.Path)
+	return nil
+}
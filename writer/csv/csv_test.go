@@ -0,0 +1,87 @@
+package csv
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+)
+
+func TestBulk(t *testing.T) {
+	tx := []ynabber.Transaction{{
+		Account: ynabber.Account{IBAN: "DK123"},
+		ID:      "abc",
+		Date:    time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC),
+		Payee:   "Netto, Copenhagen",
+		Memo:    "Groceries",
+		Amount:  -99500,
+	}}
+
+	for _, appending := range []bool{false, true} {
+		t.Run(map[bool]string{false: "truncate", true: "append"}[appending], func(t *testing.T) {
+			cfg := ynabber.Config{CSV: ynabber.CSV{
+				Path:   filepath.Join(t.TempDir(), "transactions.csv"),
+				Append: appending,
+			}}
+			w := Writer{Config: &cfg}
+			for i := 0; i < 2; i++ {
+				if err := w.Bulk(context.Background(), tx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f, err := os.Open(cfg.CSV.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, err := csv.NewReader(f).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			row := []string{"2023-05-01", "Netto, Copenhagen", "Groceries", "-99.50", "DK123", ynab.ImportID(cfg, tx[0])}
+			// Appending skips the rows already in the file
+			want := [][]string{header, row}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBulkAppendNew(t *testing.T) {
+	cfg := ynabber.Config{CSV: ynabber.CSV{
+		Path:   filepath.Join(t.TempDir(), "transactions.csv"),
+		Append: true,
+	}}
+	w := Writer{Config: &cfg}
+	first := ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "abc", Amount: -1000}
+	second := ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "def", Amount: -2000}
+
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{first}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(cfg.CSV.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[2][5] != ynab.ImportID(cfg, second) {
+		t.Errorf("got %v, want header, first and second", got)
+	}
+}
@@ -0,0 +1,222 @@
+package firefly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Writer creates transactions in Firefly III
+type Writer struct {
+	Config *ynabber.Config
+}
+
+// Fsplit is a single Firefly III transaction split. A withdrawal moves money
+// from the asset account to the payee and a deposit from the payee to the
+// asset account, the amount is always positive.
+type Fsplit struct {
+	Type            string `json:"type"`
+	Date            string `json:"date"`
+	Amount          string `json:"amount"`
+	Description     string `json:"description"`
+	Notes           string `json:"notes,omitempty"`
+	ExternalID      string `json:"external_id"`
+	SourceID        string `json:"source_id,omitempty"`
+	SourceName      string `json:"source_name,omitempty"`
+	DestinationID   string `json:"destination_id,omitempty"`
+	DestinationName string `json:"destination_name,omitempty"`
+}
+
+// Ftransaction is a Firefly III transaction group
+type Ftransaction struct {
+	// ErrorIfDuplicateHash makes Firefly III reject a transaction identical
+	// to an existing one. It's a fallback for transactions without an ID, the
+	// others are looked up by their external ID before they are created.
+	ErrorIfDuplicateHash bool     `json:"error_if_duplicate_hash"`
+	ApplyRules           bool     `json:"apply_rules"`
+	Transactions         []Fsplit `json:"transactions"`
+}
+
+// amount returns the absolute value of m in major currency units
+func amount(m ynabber.Milliunits) string {
+	if m < 0 {
+		m = m.Negate()
+	}
+	return m.MajorString()
+}
+
+// toFirefly converts t to a Firefly III transaction
+func toFirefly(cfg ynabber.Config, t ynabber.Transaction) (Ftransaction, error) {
+	accountID, ok := cfg.Firefly.AccountMap[t.Account.IBAN]
+	if !ok {
+		return Ftransaction{}, fmt.Errorf("no mapping for account '%s'", t.Account.Name)
+	}
+
+	// Firefly III requires a description and a name for the other account
+	description := strings.TrimSpace(string(t.Payee))
+	if description == "" {
+		description = strings.TrimSpace(t.Memo)
+	}
+	if description == "" {
+		description = "(no description)"
+	}
+
+	split := Fsplit{
+		Date:        t.Date.Format(ynabber.DateFormat),
+		Amount:      amount(t.Amount),
+		Description: description,
+		Notes:       t.Memo,
+		ExternalID:  string(t.ID),
+	}
+	if t.Amount < 0 {
+		split.Type = "withdrawal"
+		split.SourceID = accountID
+		split.DestinationName = description
+	} else {
+		split.Type = "deposit"
+		split.SourceName = description
+		split.DestinationID = accountID
+	}
+
+	return Ftransaction{
+		ErrorIfDuplicateHash: true,
+		ApplyRules:           true,
+		Transactions:         []Fsplit{split},
+	}, nil
+}
+
+// errDuplicate is returned by post if the transaction already exists
+var errDuplicate = errors.New("duplicate transaction")
+
+// request sends a request with body, if any, to the API path and returns the
+// response. The caller must close the body.
+func (w Writer) request(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	url := strings.TrimSuffix(w.Config.Firefly.URL, "/") + "/api/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", w.Config.Firefly.Token))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	return client.Do(req)
+}
+
+// exists reports whether a transaction with externalID exists on the Firefly
+// III account with accountID
+func (w Writer) exists(ctx context.Context, accountID, externalID string) (bool, error) {
+	query := url.Values{
+		"query": {fmt.Sprintf("external_id_is:%q account_id:%s", externalID, accountID)},
+		"limit": {"1"},
+	}
+	res, err := w.request(ctx, http.MethodGet, "search/transactions?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to search transactions: %s: %s", res.Status, body)
+	}
+	var response struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return false, fmt.Errorf("parsing search response: %w", err)
+	}
+	return len(response.Data) > 0, nil
+}
+
+// post creates t in Firefly III
+func (w Writer) post(ctx context.Context, t Ftransaction) error {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	res, err := w.request(ctx, http.MethodPost, "transactions", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode == http.StatusUnprocessableEntity && bytes.Contains(body, []byte("Duplicate")) {
+		return errDuplicate
+	}
+	return fmt.Errorf("failed to send request: %s: %s", res.Status, body)
+}
+
+// Bulk creates tx in Firefly III with one request per transaction, which is
+// how the API creates transactions. Transactions already in Firefly III, by
+// external ID or by hash, are skipped and a failing transaction doesn't stop
+// the others.
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	sent, skipped, duplicates, failed := 0, 0, 0, 0
+	var errs []error
+	for _, t := range tx {
+		// Firefly III doesn't allow transactions without an amount
+		if t.Amount == 0 {
+			skipped += 1
+			continue
+		}
+
+		f, err := toFirefly(*w.Config, t)
+		if err != nil {
+			slog.Error("Failed to parse transaction",
+				"account", t.Account.Name, "date", t.Date.Format(ynabber.DateFormat), "error", err)
+			failed += 1
+			continue
+		}
+
+		// Look up the transaction by its ID first since the hash changes if
+		// the bank changes the description or amount
+		if t.ID != "" {
+			accountID := w.Config.Firefly.AccountMap[t.Account.IBAN]
+			found, err := w.exists(ctx, accountID, string(t.ID))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("transaction %s: %w", t.ID, err))
+				failed += 1
+				continue
+			}
+			if found {
+				duplicates += 1
+				continue
+			}
+		}
+
+		err = w.post(ctx, f)
+		if errors.Is(err, errDuplicate) {
+			duplicates += 1
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transaction %s: %w", t.ID, err))
+			failed += 1
+			continue
+		}
+		sent += 1
+	}
+
+	slog.Info("Sent transactions to Firefly III",
+		"sent", sent, "skipped", skipped, "failed", failed, "duplicates", duplicates)
+	return errors.Join(errs...)
+}
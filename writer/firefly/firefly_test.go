@@ -0,0 +1,134 @@
+package firefly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestToFirefly(t *testing.T) {
+	cfg := ynabber.Config{Firefly: ynabber.Firefly{
+		AccountMap: ynabber.AccountMap{"DK123": "1"},
+	}}
+	date := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		t    ynabber.Transaction
+		want Fsplit
+	}{
+		{
+			name: "withdrawal",
+			t:    ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "a", Date: date, Payee: "Netto", Memo: "Groceries", Amount: -99500},
+			want: Fsplit{
+				Type: "withdrawal", Date: "2023-05-01", Amount: "99.50", Description: "Netto", Notes: "Groceries",
+				ExternalID: "a", SourceID: "1", DestinationName: "Netto",
+			},
+		},
+		{
+			name: "deposit",
+			t:    ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "b", Date: date, Memo: "Salary", Amount: 1000000},
+			want: Fsplit{
+				Type: "deposit", Date: "2023-05-01", Amount: "1000.00", Description: "Salary", Notes: "Salary",
+				ExternalID: "b", SourceName: "Salary", DestinationID: "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toFirefly(cfg, tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.ErrorIfDuplicateHash {
+				t.Error("want error_if_duplicate_hash set")
+			}
+			if !reflect.DeepEqual(got.Transactions, []Fsplit{tt.want}) {
+				t.Errorf("got %+v, want %+v", got.Transactions, tt.want)
+			}
+		})
+	}
+
+	if _, err := toFirefly(cfg, ynabber.Transaction{Account: ynabber.Account{IBAN: "unknown"}}); err == nil {
+		t.Error("want error for unmapped account")
+	}
+}
+
+func TestBulk(t *testing.T) {
+	created := map[string]bool{}
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/api/v1/search/transactions" {
+			// Only the external ID lookup is supported by the fake
+			var id string
+			fmt.Sscanf(r.URL.Query().Get("query"), "external_id_is:%q account_id:1", &id)
+			if created[id] {
+				w.Write([]byte(`{"data": [{"id": "1"}]}`))
+				return
+			}
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		if r.URL.Path != "/api/v1/transactions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		posts += 1
+		var body Ftransaction
+		json.NewDecoder(r.Body).Decode(&body)
+		id := body.Transactions[0].ExternalID
+		if created[id] {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "Duplicate of transaction #1."}`))
+			return
+		}
+		created[id] = true
+	}))
+	defer server.Close()
+
+	cfg := ynabber.Config{Firefly: ynabber.Firefly{
+		URL:        server.URL + "/",
+		Token:      "token",
+		AccountMap: ynabber.AccountMap{"DK123": "1"},
+	}}
+	account := ynabber.Account{IBAN: "DK123"}
+	tx := []ynabber.Transaction{
+		{Account: account, ID: "a", Amount: -1000},
+		{Account: account, ID: "b", Amount: 0},
+		{Account: account, Amount: -500},
+	}
+	w := Writer{Config: &cfg}
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"a": true, "": true}; !reflect.DeepEqual(created, want) {
+		t.Errorf("got created %v, want %v", created, want)
+	}
+
+	// Writing the same transactions again isn't an error. The one with an ID
+	// is found by it and only the one without is posted, Firefly III rejects
+	// it by its hash.
+	posts = 0
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if posts != 1 {
+		t.Errorf("got %d posts, want only the transaction without an ID", posts)
+	}
+
+	cfg.Firefly.Token = "wrong"
+	if err := w.Bulk(context.Background(), tx); err == nil {
+		t.Error("want error for rejected request")
+	}
+}
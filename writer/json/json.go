@@ -1,19 +1,104 @@
 package json
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 
 	"github.com/martinohansen/ynabber"
 )
 
-type Writer struct{}
+type Writer struct {
+	Config *ynabber.Config
+}
+
+// Bulk writes tx as indented JSON to the configured path, or to stdout if no
+// path is configured. Transactions already in the file are kept and tx is
+// merged into them, replacing transactions with the same account and ID with
+// the newer version, so the file grows into a complete history across runs.
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	if w.Config == nil || w.Config.JSON.Path == "" {
+		b, err := json.MarshalIndent(tx, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshalling: %w", err)
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	file := w.Config.JSON.Path
+	existing, err := read(file)
+	if err != nil {
+		return err
+	}
+	merged, updated := merge(existing, tx)
 
-func (w Writer) Bulk(tx []ynabber.Transaction) error {
-	b, err := json.MarshalIndent(tx, "", "  ")
+	b, err := json.MarshalIndent(merged, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshalling: %w", err)
 	}
-	fmt.Println(string(b))
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	// Write to a temporary file first so a crash never leaves a truncated
+	// history behind
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	slog.Info("Wrote transactions", "count", len(merged)-len(existing), "updated", updated, "file", file)
 	return nil
 }
+
+// merge returns existing with tx merged into it and the number of
+// transactions in existing replaced. A transaction in tx replaces the one in
+// existing with the same account and ID in place, the others are appended.
+// Transactions without an ID are always appended since they can't be told
+// apart.
+func merge(existing, tx []ynabber.Transaction) ([]ynabber.Transaction, int) {
+	type key struct {
+		IBAN string
+		ID   ynabber.ID
+	}
+	tx, _ = ynabber.Dedup(tx)
+	index := make(map[key]int, len(existing))
+	for i, v := range existing {
+		if v.ID != "" {
+			index[key{IBAN: v.Account.IBAN, ID: v.ID}] = i
+		}
+	}
+
+	merged := append([]ynabber.Transaction(nil), existing...)
+	updated := 0
+	for _, v := range tx {
+		if i, ok := index[key{IBAN: v.Account.IBAN, ID: v.ID}]; ok && v.ID != "" {
+			merged[i] = v
+			updated += 1
+			continue
+		}
+		merged = append(merged, v)
+	}
+	return merged, updated
+}
+
+// read returns the transactions in file, none if it doesn't exist
+func read(file string) ([]ynabber.Transaction, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	var tx []ynabber.Transaction
+	if err := json.Unmarshal(b, &tx); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,123 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestBulkPath(t *testing.T) {
+	cfg := ynabber.Config{JSON: ynabber.JSON{
+		Path: filepath.Join(t.TempDir(), "backup", "transactions.json"),
+	}}
+	tx := []ynabber.Transaction{{ID: "a", Payee: "Netto", Amount: -1000}}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(cfg.JSON.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ynabber.Transaction
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tx) {
+		t.Errorf("got %+v, want %+v", got, tx)
+	}
+	if _, err := os.Stat(cfg.JSON.Path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("got temporary file left behind: %v", err)
+	}
+}
+
+func TestBulkMerge(t *testing.T) {
+	cfg := ynabber.Config{JSON: ynabber.JSON{Path: filepath.Join(t.TempDir(), "transactions.json")}}
+	w := Writer{Config: &cfg}
+	checking := ynabber.Account{IBAN: "DK1"}
+	savings := ynabber.Account{IBAN: "DK2"}
+
+	first := []ynabber.Transaction{{Account: checking, ID: "a", Amount: -1000}}
+	second := []ynabber.Transaction{
+		{Account: checking, ID: "a", Amount: -1000},
+		{Account: checking, ID: "b", Amount: 2000},
+		{Account: savings, ID: "a", Amount: 500},
+	}
+	for _, tx := range [][]ynabber.Transaction{first, second, second} {
+		if err := w.Bulk(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := read(cfg.JSON.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, second) {
+		t.Errorf("got %+v, want %+v", got, second)
+	}
+}
+
+func TestBulkLegacyAmounts(t *testing.T) {
+	// A file written before amounts were in major units
+	cfg := ynabber.Config{JSON: ynabber.JSON{Path: filepath.Join(t.TempDir(), "transactions.json")}}
+	legacy := `[{"account": {"IBAN": "DK1"}, "id": "a", "amount": -12400}]`
+	if err := os.WriteFile(cfg.JSON.Path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := []ynabber.Transaction{{Account: ynabber.Account{IBAN: "DK1"}, ID: "b", Amount: -500}}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := read(cfg.JSON.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Amount != -12400 || got[1].Amount != -500 {
+		t.Errorf("got %+v, want amounts -12400 and -500", got)
+	}
+	b, err := os.ReadFile(cfg.JSON.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"amount": -12.40`) {
+		t.Errorf("got %s, want the legacy amount rewritten as -12.40", b)
+	}
+}
+
+func TestBulkReplacesExisting(t *testing.T) {
+	cfg := ynabber.Config{JSON: ynabber.JSON{Path: filepath.Join(t.TempDir(), "transactions.json")}}
+	w := Writer{Config: &cfg}
+	account := ynabber.Account{IBAN: "DK1"}
+
+	first := []ynabber.Transaction{
+		{Account: account, ID: "a", Amount: -1000, Pending: true},
+		{Account: account, ID: "b", Amount: -2000},
+	}
+	second := []ynabber.Transaction{
+		{Account: account, ID: "a", Amount: -1250},
+		{Account: account, ID: "c", Amount: -3000},
+	}
+	for _, tx := range [][]ynabber.Transaction{first, second} {
+		if err := w.Bulk(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := read(cfg.JSON.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ynabber.Transaction{second[0], first[1], second[1]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/martinohansen/ynabber"
+)
+
+// putObjectAPI is the part of the S3 client used by the writer
+type putObjectAPI interface {
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+type Writer struct {
+	Config *ynabber.Config
+
+	// Client is nil if no bucket is configured
+	Client putObjectAPI
+
+	// now returns the time used in the object key
+	now func() time.Time
+}
+
+// NewWriter returns a new S3 writer. The AWS credentials and region are read
+// from the environment, as provided by Lambda, if a bucket is configured.
+func NewWriter(cfg *ynabber.Config) (Writer, error) {
+	w := Writer{Config: cfg, now: time.Now}
+	if cfg.S3.Bucket == "" {
+		return w, nil
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return Writer{}, fmt.Errorf("loading AWS config: %w", err)
+	}
+	w.Client = s3.NewFromConfig(awsConfig)
+	return w, nil
+}
+
+// key returns the timestamped object key
+func (w Writer) key() string {
+	return fmt.Sprintf("%synabber-%s.json",
+		w.Config.S3.Prefix,
+		w.now().UTC().Format("20060102T150405Z"),
+	)
+}
+
+// Bulk uploads tx as JSON to the configured bucket, or writes it to a file in
+// the data dir if no bucket is configured
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	b, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling: %w", err)
+	}
+	key := w.key()
+
+	if w.Client == nil {
+		file := path.Clean(fmt.Sprintf("%s/%s", w.Config.DataDir, path.Base(key)))
+		err = os.WriteFile(file, b, 0600)
+		if err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+		slog.Info("No S3 bucket configured, wrote transactions to file", "count", len(tx), "file", file)
+		return nil
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(w.Config.S3.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+	if w.Config.S3.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(w.Config.S3.ServerSideEncryption)
+	}
+	if w.Config.S3.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(w.Config.S3.KMSKeyID)
+	}
+
+	_, err = w.Client.PutObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("uploading to s3://%s/%s: %w", w.Config.S3.Bucket, key, err)
+	}
+	slog.Info("Uploaded transactions", "count", len(tx), "url", fmt.Sprintf("s3://%s/%s", w.Config.S3.Bucket, key))
+	return nil
+}
@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/martinohansen/ynabber"
+)
+
+type fakeClient struct {
+	input *s3.PutObjectInput
+	body  []byte
+}
+
+func (c *fakeClient) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	c.input = input
+	c.body, _ = io.ReadAll(input.Body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+var now = func() time.Time { return time.Date(2023, 2, 24, 12, 0, 0, 0, time.UTC) }
+
+func TestBulk(t *testing.T) {
+	client := &fakeClient{}
+	w := Writer{
+		Config: &ynabber.Config{S3: ynabber.S3{
+			Bucket:               "bucket",
+			Prefix:               "exports/",
+			ServerSideEncryption: "aws:kms",
+			KMSKeyID:             "key",
+		}},
+		Client: client,
+		now:    now,
+	}
+
+	tx := []ynabber.Transaction{{ID: "foo", Amount: 1000}}
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := aws.ToString(client.input.Key); got != "exports/ynabber-20230224T120000Z.json" {
+		t.Errorf("got key = %s", got)
+	}
+	if got := string(client.input.ServerSideEncryption); got != "aws:kms" {
+		t.Errorf("got encryption = %s", got)
+	}
+	if got := aws.ToString(client.input.SSEKMSKeyId); got != "key" {
+		t.Errorf("got KMS key = %s", got)
+	}
+
+	var got []ynabber.Transaction
+	if err := json.Unmarshal(client.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "foo" {
+		t.Errorf("got body = %s", client.body)
+	}
+}
+
+func TestBulkLocalFallback(t *testing.T) {
+	dir := t.TempDir()
+	w := Writer{Config: &ynabber.Config{DataDir: dir}, now: now}
+
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{{ID: "foo"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(dir, "ynabber-20230224T120000Z.json")); err != nil {
+		t.Error(err)
+	}
+}
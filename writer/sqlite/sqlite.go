@@ -0,0 +1,177 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+	"github.com/martinohansen/ynabber/writer/ynab"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS transactions (
+	import_id           TEXT PRIMARY KEY,
+	id                  TEXT NOT NULL,
+	account_id          TEXT NOT NULL,
+	account_name        TEXT NOT NULL,
+	account_iban        TEXT NOT NULL,
+	account_institution TEXT NOT NULL,
+	date                TEXT NOT NULL,
+	time                TEXT NOT NULL,
+	payee               TEXT NOT NULL,
+	memo                TEXT NOT NULL,
+	amount              INTEGER NOT NULL,
+	code                TEXT NOT NULL
+)`
+
+// index makes looking up transactions by account and ID fast for the ledger
+const index = `CREATE INDEX IF NOT EXISTS transactions_account_id
+	ON transactions (account_iban, id)`
+
+const upsert = `INSERT INTO transactions (
+	import_id, id, account_id, account_name, account_iban, account_institution,
+	date, time, payee, memo, amount, code
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (import_id) DO UPDATE SET
+	id = excluded.id,
+	account_id = excluded.account_id,
+	account_name = excluded.account_name,
+	account_iban = excluded.account_iban,
+	account_institution = excluded.account_institution,
+	date = excluded.date,
+	time = excluded.time,
+	payee = excluded.payee,
+	memo = excluded.memo,
+	amount = excluded.amount,
+	code = excluded.code`
+
+// Writer stores transactions in a SQLite database. Transactions are keyed by
+// the import ID used for YNAB so writing the same transaction again updates
+// it instead of adding a copy.
+type Writer struct {
+	Config *ynabber.Config
+}
+
+// open opens the database at path, the table is created if it doesn't exist
+func open(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	for _, stmt := range []string{schema, index} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("creating table: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// Bulk upserts tx into the database in a single transaction, the table is
+// created if it doesn't exist
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	db, err := open(ctx, w.Config.SQLite.Path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dbtx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbtx.Rollback()
+
+	stmt, err := dbtx.PrepareContext(ctx, upsert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, t := range tx {
+		_, err := stmt.ExecContext(ctx,
+			ynab.ImportID(*w.Config, t),
+			string(t.ID),
+			string(t.Account.ID),
+			t.Account.Name,
+			t.Account.IBAN,
+			t.Account.Institution,
+			t.Date.Format(ynabber.DateFormat),
+			t.Time.UTC().Format(time.RFC3339),
+			string(t.Payee),
+			t.Memo,
+			int64(t.Amount),
+			t.Code,
+		)
+		if err != nil {
+			return fmt.Errorf("writing transaction %s: %w", t.ID, err)
+		}
+	}
+	if err := dbtx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("Wrote transactions", "count", len(tx), "file", w.Config.SQLite.Path)
+	return nil
+}
+
+// Ledger skips the transactions already stored in the database by account
+// IBAN and transaction ID, making the database the record of what has been
+// written. Transactions without an ID are never skipped since they can't be
+// told apart.
+type Ledger struct {
+	Config *ynabber.Config
+}
+
+// Transform returns t without the transactions already in the database. If
+// the database can't be read nothing is skipped, the writers recognize
+// transactions already written by themselves.
+func (l Ledger) Transform(t []ynabber.Transaction) []ynabber.Transaction {
+	return l.TransformContext(context.Background(), t)
+}
+
+// TransformContext is Transform with the lookups bound to ctx
+func (l Ledger) TransformContext(ctx context.Context, t []ynabber.Transaction) []ynabber.Transaction {
+	unseen, err := l.unseen(ctx, t)
+	if err != nil {
+		slog.Warn("Failed to look up transactions in the ledger, skipping none", "error", err)
+		return t
+	}
+	if skipped := len(t) - len(unseen); skipped > 0 {
+		slog.Info("Skipped transactions already in the ledger", "count", skipped, "file", l.Config.SQLite.Path)
+	}
+	return unseen
+}
+
+// unseen returns the transactions in t that aren't in the database
+func (l Ledger) unseen(ctx context.Context, t []ynabber.Transaction) ([]ynabber.Transaction, error) {
+	db, err := open(ctx, l.Config.SQLite.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	stmt, err := db.PrepareContext(ctx, "SELECT EXISTS (SELECT 1 FROM transactions WHERE account_iban = ? AND id = ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	unseen := make([]ynabber.Transaction, 0, len(t))
+	for _, v := range t {
+		if v.ID != "" {
+			var seen bool
+			if err := stmt.QueryRowContext(ctx, v.Account.IBAN, string(v.ID)).Scan(&seen); err != nil {
+				return nil, fmt.Errorf("looking up transaction %s: %w", v.ID, err)
+			}
+			if seen {
+				continue
+			}
+		}
+		unseen = append(unseen, v)
+	}
+	return unseen, nil
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestBulk(t *testing.T) {
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{
+		Path: filepath.Join(t.TempDir(), "ynabber.db"),
+	}}
+	w := Writer{Config: &cfg}
+
+	date := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	tx := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "DK123"}, ID: "1", Date: date, Time: date, Payee: "Netto", Amount: -99500},
+		{Account: ynabber.Account{IBAN: "DK123"}, ID: "2", Date: date, Time: date, Payee: "Salary", Amount: 1000000},
+	}
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing again updates the transactions instead of adding copies
+	tx[0].Payee = "Netto Copenhagen"
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", cfg.SQLite.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d rows, want 2", count)
+	}
+
+	var payee string
+	var amount int64
+	if err := db.QueryRow("SELECT payee, amount FROM transactions WHERE id = '1'").Scan(&payee, &amount); err != nil {
+		t.Fatal(err)
+	}
+	if payee != "Netto Copenhagen" || amount != -99500 {
+		t.Errorf("got payee %q and amount %d, want Netto Copenhagen and -99500", payee, amount)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{
+		Path: filepath.Join(t.TempDir(), "ynabber.db"),
+	}}
+	date := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	stored := ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "1", Date: date, Amount: -99500}
+	noID := ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, Date: date, Amount: -1000}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), []ynabber.Transaction{stored, noID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same ID on another account is another transaction
+	otherAccount := stored
+	otherAccount.Account.IBAN = "DK456"
+	unseen := ynabber.Transaction{Account: ynabber.Account{IBAN: "DK123"}, ID: "2", Date: date, Amount: -500}
+	changed := stored
+	changed.Amount = -100000
+
+	got := Ledger{Config: &cfg}.Transform([]ynabber.Transaction{stored, changed, noID, otherAccount, unseen})
+	want := []ynabber.Transaction{noID, otherAccount, unseen}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLedgerUnreadable(t *testing.T) {
+	// A database that can't be opened skips nothing
+	cfg := ynabber.Config{SQLite: ynabber.SQLite{Path: filepath.Join(t.TempDir(), "missing", "ynabber.db")}}
+	tx := []ynabber.Transaction{{ID: "1"}}
+	if got := (Ledger{Config: &cfg}).Transform(tx); !reflect.DeepEqual(got, tx) {
+		t.Errorf("got %+v, want %+v", got, tx)
+	}
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Writer posts transactions as JSON to a URL
+type Writer struct {
+	Config *ynabber.Config
+}
+
+// Bulk posts tx as a JSON array to the webhook in a single request, any
+// status outside 2xx is an error
+func (w Writer) Bulk(ctx context.Context, tx []ynabber.Transaction) error {
+	if tx == nil {
+		tx = []ynabber.Transaction{}
+	}
+	payload, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("marshalling: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Config.Webhook.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Config.Webhook.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.Config.Webhook.Token))
+	}
+	for key, value := range w.Config.Webhook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: w.Config.Webhook.Timeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", res.Status, body)
+	}
+	slog.Info("Posted transactions to webhook", "count", len(tx))
+	return nil
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestBulk(t *testing.T) {
+	var got []ynabber.Transaction
+	var header http.Header
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := ynabber.Config{Webhook: ynabber.Webhook{
+		URL:     server.URL,
+		Token:   "token",
+		Headers: ynabber.HeaderMap{"X-Source": "ynabber"},
+		Timeout: time.Second,
+	}}
+	w := Writer{Config: &cfg}
+	tx := []ynabber.Transaction{{ID: "a", Amount: -1000, Payee: "Netto"}}
+	if err := w.Bulk(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tx) {
+		t.Errorf("got %+v, want %+v", got, tx)
+	}
+	if header.Get("Authorization") != "Bearer token" || header.Get("X-Source") != "ynabber" {
+		t.Errorf("got headers %v", header)
+	}
+
+	status = http.StatusInternalServerError
+	if err := w.Bulk(context.Background(), tx); err == nil {
+		t.Error("want error for non-2xx status")
+	}
+}
@@ -0,0 +1,54 @@
+package ynab
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// envelopeSize is the size of the marshaled Ytransactions without any
+// transactions, i.e. {"transactions":[]}
+var envelopeSize = len(`{"transactions":[]}`)
+
+// batch splits t into chunks of at most maxCount transactions whose marshaled
+// payload stays within maxSize bytes. A single transaction larger than
+// maxSize is put in a chunk of its own. A maxSize or maxCount of 0 disables
+// splitting by that limit.
+func batch(t []Ytransaction, maxSize int, maxCount int) ([][]Ytransaction, error) {
+	if (maxSize <= 0 && maxCount <= 0) || len(t) == 0 {
+		return [][]Ytransaction{t}, nil
+	}
+
+	var chunks [][]Ytransaction
+	var chunk []Ytransaction
+	size := envelopeSize
+	for _, v := range t {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add one byte for the comma separating the transactions
+		n := len(b)
+		if len(chunk) > 0 {
+			n += 1
+		}
+
+		full := maxCount > 0 && len(chunk) >= maxCount
+		tooLarge := maxSize > 0 && size+n > maxSize
+		if len(chunk) > 0 && (full || tooLarge) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			size = envelopeSize
+			n = len(b)
+		}
+		chunk = append(chunk, v)
+		size += n
+	}
+	chunks = append(chunks, chunk)
+
+	if len(chunks) > 1 {
+		slog.Info("Split transactions into requests",
+			"count", len(t), "requests", len(chunks), "max_count", maxCount, "max_size", maxSize)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,110 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestBatch(t *testing.T) {
+	small := Ytransaction{AccountID: "abc", Memo: "small"}
+	large := Ytransaction{AccountID: "abc", Memo: strings.Repeat("x", 1000)}
+
+	tests := []struct {
+		name     string
+		t        []Ytransaction
+		maxSize  int
+		maxCount int
+		want     []int
+	}{
+		{name: "disabled", t: []Ytransaction{large, large, large}, maxSize: 0, want: []int{3}},
+		{name: "fits", t: []Ytransaction{small, small, small}, maxSize: 2000, want: []int{3}},
+		{name: "large memos", t: []Ytransaction{small, large, large, small}, maxSize: 2000, want: []int{2, 2}},
+		{name: "larger than max", t: []Ytransaction{large, small}, maxSize: 500, want: []int{1, 1}},
+		{name: "count", t: []Ytransaction{small, small, small, small, small}, maxCount: 2, want: []int{2, 2, 1}},
+		{name: "count and size", t: []Ytransaction{small, large, large, small, small}, maxSize: 2000, maxCount: 2, want: []int{2, 2, 1}},
+		{name: "size before count", t: []Ytransaction{large, large, large}, maxSize: 2000, maxCount: 5, want: []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := batch(tt.t, tt.maxSize, tt.maxCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.want))
+			}
+			for i, chunk := range chunks {
+				if len(chunk) != tt.want[i] {
+					t.Errorf("chunk %d: got %d transactions, want %d", i, len(chunk), tt.want[i])
+				}
+
+				// Every chunk with more than one transaction must respect
+				// the max size when marshaled
+				b, err := json.Marshal(Ytransactions{Transactions: chunk})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.maxSize > 0 && len(chunk) > 1 && len(b) > tt.maxSize {
+					t.Errorf("chunk %d: payload is %d bytes, max %d", i, len(b), tt.maxSize)
+				}
+			}
+		})
+	}
+}
+
+func TestSendContinuesAfterFailedChunk(t *testing.T) {
+	defer func(url string) { baseURL = url }(baseURL)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	baseURL = server.URL
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{BatchSize: 2}}
+	transactions := []Ytransaction{{ImportID: "1"}, {ImportID: "2"}, {ImportID: "3"}, {ImportID: "4"}, {ImportID: "5"}}
+	unsent, _, err := Writer{Config: &cfg}.send(context.Background(), transactions)
+	if err == nil || !strings.Contains(err.Error(), "request 2 of 3") {
+		t.Errorf("got error %v, want error for request 2 of 3", err)
+	}
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+	if want := transactions[2:4]; !reflect.DeepEqual(unsent, want) {
+		t.Errorf("got unsent %+v, want %+v", unsent, want)
+	}
+}
+
+func TestSendDuplicates(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{BudgetID: "budget", BatchSize: 2}}
+	w := Writer{Config: &cfg}
+	if _, duplicates, err := w.send(context.Background(), []Ytransaction{{ImportID: "1"}, {ImportID: "2"}}); err != nil || len(duplicates) != 0 {
+		t.Fatalf("got duplicates %v and error %v, want none", duplicates, err)
+	}
+	_, duplicates, err := w.send(context.Background(), []Ytransaction{{ImportID: "1"}, {ImportID: "2"}, {ImportID: "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(duplicates, want) {
+		t.Errorf("got duplicates %v, want %v", duplicates, want)
+	}
+}
@@ -0,0 +1,57 @@
+package ynab
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// markerImportID is the import ID of the sync marker, it's fixed so YNAB
+// keeps a single marker
+const markerImportID = "YBBR:sync-marker"
+
+// marker returns the sync marker transaction for now
+func marker(accountID string, now time.Time) Ytransaction {
+	return Ytransaction{
+		ImportID:  markerImportID,
+		AccountID: accountID,
+		Date:      now.Format("2006-01-02"),
+		Amount:    "0",
+		PayeeName: "Ynabber",
+		Memo:      fmt.Sprintf("Last synced %s", now.UTC().Format(time.RFC3339)),
+		Cleared:   "cleared",
+		Approved:  true,
+	}
+}
+
+// syncMarker creates the sync marker if it doesn't exist and updates it to
+// now. YNAB ignores a new transaction with an existing import ID, so the
+// marker is updated by import ID after it has been created.
+func (w Writer) syncMarker(ctx context.Context, now time.Time) error {
+	m := marker(w.Config.YNAB.SyncMarkerAccount, now)
+	if _, err := w.post(ctx, []Ytransaction{m}); err != nil {
+		return err
+	}
+
+	res, _, err := w.request(ctx, http.MethodPatch, "transactions", Ytransactions{Transactions: []Ytransaction{m}})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != 209 {
+		return fmt.Errorf("failed to update marker: %s", res.Status)
+	}
+	return nil
+}
+
+// markSynced updates the sync marker if configured, a failure is only logged
+// since the transactions are already written
+func (w Writer) markSynced(ctx context.Context) {
+	if w.Config.YNAB.SyncMarkerAccount == "" || w.Config.YNAB.DryRun {
+		return
+	}
+	if err := w.syncMarker(ctx, time.Now()); err != nil {
+		slog.Warn("Failed to update sync marker", "error", err)
+	}
+}
@@ -0,0 +1,63 @@
+package ynab
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestMarker(t *testing.T) {
+	now := time.Date(2023, 11, 4, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	got := marker("abc", now)
+	want := Ytransaction{
+		ImportID:  markerImportID,
+		AccountID: "abc",
+		Date:      "2023-11-04",
+		Amount:    "0",
+		PayeeName: "Ynabber",
+		Memo:      "Last synced 2023-11-04T22:30:00Z",
+		Cleared:   "cleared",
+		Approved:  true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncMarker(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		BudgetID:          "budget",
+		SyncMarkerAccount: "marker",
+	}}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.created) != 1 || fake.created[0].AccountID != "marker" {
+		t.Errorf("got created %+v, want marker", fake.created)
+	}
+	if want := []string{markerImportID}; !reflect.DeepEqual(fake.updated, want) {
+		t.Errorf("got updated %v, want %v", fake.updated, want)
+	}
+
+	// A failing run doesn't touch the marker
+	fake.created, fake.updated, fake.fail = nil, nil, true
+	transactions := []ynabber.Transaction{{Account: ynabber.Account{IBAN: "foobar"}, Date: time.Now().AddDate(0, 0, -1)}}
+	cfg.YNAB.AccountMap = map[string]string{"foobar": "abc"}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), transactions); err == nil {
+		t.Fatal("want error from failing YNAB")
+	}
+	if len(fake.updated) != 0 {
+		t.Errorf("marker updated on failed run")
+	}
+}
@@ -0,0 +1,95 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// outboxFile is the name of the outbox inside the data dir or S3 bucket
+const outboxFile = "ynab-outbox.json"
+
+// loadOutbox returns the transactions in the outbox, a missing outbox is empty
+func (w Writer) loadOutbox(ctx context.Context) ([]Ytransaction, error) {
+	b, err := w.readFile(ctx, outboxFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t []Ytransaction
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", w.location(outboxFile), err)
+	}
+	return t, nil
+}
+
+// saveOutbox replaces the outbox with t, the file is removed if t is empty
+func (w Writer) saveOutbox(ctx context.Context, t []Ytransaction) error {
+	if len(t) == 0 {
+		return w.removeFile(ctx, outboxFile)
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return w.writeFile(ctx, outboxFile, b)
+}
+
+// addToOutbox adds t to the outbox. Transactions already in the outbox are
+// replaced by the newer version and the oldest are dropped if the outbox
+// exceeds OutboxSize.
+func (w Writer) addToOutbox(ctx context.Context, t []Ytransaction) error {
+	outbox, err := w.loadOutbox(ctx)
+	if err != nil {
+		return err
+	}
+
+	added := make(map[string]bool, len(t))
+	for _, v := range t {
+		added[v.ImportID] = true
+	}
+	var merged []Ytransaction
+	for _, v := range outbox {
+		if !added[v.ImportID] {
+			merged = append(merged, v)
+		}
+	}
+	merged = append(merged, t...)
+
+	if size := w.Config.YNAB.OutboxSize; size > 0 && len(merged) > size {
+		slog.Warn("Outbox is full, dropping oldest transactions", "count", len(merged)-size)
+		merged = merged[len(merged)-size:]
+	}
+	return w.saveOutbox(ctx, merged)
+}
+
+// Flush sends the transactions in the outbox to YNAB, transactions that fail
+// again are kept for the next run
+func (w Writer) Flush(ctx context.Context) error {
+	if !w.Config.YNAB.Outbox || w.Config.YNAB.DryRun {
+		return nil
+	}
+
+	outbox, err := w.loadOutbox(ctx)
+	if err != nil {
+		return fmt.Errorf("loading outbox: %w", err)
+	}
+	if len(outbox) == 0 {
+		return nil
+	}
+
+	unsent, _, sendErr := w.send(ctx, outbox)
+	if err := w.saveOutbox(ctx, unsent); err != nil {
+		return fmt.Errorf("saving outbox: %w", err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("flushing outbox: %d transaction(s) left: %w", len(unsent), sendErr)
+	}
+	slog.Info("Flushed transactions from outbox", "count", len(outbox))
+	return nil
+}
@@ -0,0 +1,140 @@
+package ynab
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/martinohansen/ynabber"
+)
+
+func TestOutbox(t *testing.T) {
+	fake := &fakeYNAB{fail: true}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	cfg := ynabber.Config{
+		DataDir: t.TempDir(),
+		YNAB: ynabber.YNAB{
+			BudgetID:   "budget",
+			AccountMap: map[string]string{"foobar": "abc"},
+			Outbox:     true,
+			OutboxSize: 2,
+		},
+	}
+	w := Writer{Config: &cfg}
+
+	date := time.Now().AddDate(0, 0, -1)
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: date, Amount: 1000},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "2", Date: date, Amount: 2000},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "3", Date: date, Amount: 3000},
+	}
+
+	// A failing write puts the transactions in the outbox within its size
+	if err := w.Bulk(context.Background(), transactions[:2]); err == nil {
+		t.Fatal("want error from failing YNAB")
+	}
+	if err := w.Bulk(context.Background(), transactions[1:]); err == nil {
+		t.Fatal("want error from failing YNAB")
+	}
+	outbox, err := w.loadOutbox(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 2 || outbox[0].ImportID != makeID(cfg, transactions[1]) ||
+		outbox[1].ImportID != makeID(cfg, transactions[2]) {
+		t.Fatalf("got outbox %+v, want transaction 2 and 3", outbox)
+	}
+
+	// Flushing while YNAB still fails keeps the outbox
+	if err := w.Flush(context.Background()); err == nil {
+		t.Fatal("want error from failing YNAB")
+	}
+	if outbox, _ := w.loadOutbox(context.Background()); len(outbox) != 2 {
+		t.Fatalf("got %d transactions in outbox, want 2", len(outbox))
+	}
+
+	// Flushing once YNAB is back empties the outbox
+	fake.fail = false
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.created) != 2 {
+		t.Errorf("got %d transactions created, want 2", len(fake.created))
+	}
+	if outbox, _ := w.loadOutbox(context.Background()); len(outbox) != 0 {
+		t.Errorf("got %d transactions in outbox, want 0", len(outbox))
+	}
+}
+
+func TestOutboxDisabled(t *testing.T) {
+	cfg := ynabber.Config{DataDir: t.TempDir()}
+	w := Writer{Config: &cfg}
+	if err := w.saveOutbox(context.Background(), []Ytransaction{{ImportID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if outbox, _ := w.loadOutbox(context.Background()); len(outbox) != 1 {
+		t.Errorf("disabled outbox was flushed")
+	}
+}
+
+// fakeS3 keeps objects in memory by key
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (c *fakeS3) GetObject(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	b, ok := c.objects[aws.ToString(input.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (c *fakeS3) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	c.objects[aws.ToString(input.Key)], _ = io.ReadAll(input.Body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c *fakeS3) DeleteObject(_ context.Context, input *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(c.objects, aws.ToString(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestOutboxS3(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	cfg := ynabber.Config{S3: ynabber.S3{Bucket: "bucket", Prefix: "ynabber/"}}
+	w := Writer{Config: &cfg, S3Client: client}
+	ctx := context.Background()
+
+	if outbox, err := w.loadOutbox(ctx); err != nil || len(outbox) != 0 {
+		t.Fatalf("got %+v, %v, want an empty outbox", outbox, err)
+	}
+	if err := w.addToOutbox(ctx, []Ytransaction{{ImportID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.objects["ynabber/"+outboxFile]; !ok {
+		t.Fatalf("got objects %v, want the outbox under the prefix", client.objects)
+	}
+	if outbox, _ := w.loadOutbox(ctx); len(outbox) != 1 {
+		t.Errorf("got %d transactions in outbox, want 1", len(outbox))
+	}
+	if err := w.saveOutbox(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.objects) != 0 {
+		t.Errorf("got objects %v, want the empty outbox removed", client.objects)
+	}
+}
@@ -0,0 +1,126 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// reconciliation is the transactions sent to a YNAB account and the balance
+// the bank reported for it
+type reconciliation struct {
+	AccountID string
+	Name      string
+	Balance   ynabber.Milliunits
+	ImportIDs []string
+}
+
+// reconciliations groups the transactions sent by YNAB account. Accounts
+// without a balance from the bank can't be reconciled and are left out.
+func reconciliations(cfg ynabber.Config, t []ynabber.Transaction, y []Ytransaction) []reconciliation {
+	byAccount := map[string]*reconciliation{}
+	var accounts []string
+	for i, v := range y {
+		if t[i].Account.Balance == nil {
+			continue
+		}
+		r, ok := byAccount[v.AccountID]
+		if !ok {
+			balance := *t[i].Account.Balance
+			if cfg.SwapFlow(t[i].Account.IBAN) {
+				balance = balance.Negate()
+			}
+			r = &reconciliation{
+				AccountID: v.AccountID,
+				Name:      t[i].Account.Name,
+				Balance:   balance,
+			}
+			byAccount[v.AccountID] = r
+			accounts = append(accounts, v.AccountID)
+		}
+		r.ImportIDs = append(r.ImportIDs, v.ImportID)
+	}
+
+	sort.Strings(accounts)
+	r := make([]reconciliation, 0, len(accounts))
+	for _, account := range accounts {
+		r = append(r, *byAccount[account])
+	}
+	return r
+}
+
+// clearedBalance returns the cleared balance of the YNAB account
+func (w Writer) clearedBalance(ctx context.Context, accountID string) (ynabber.Milliunits, error) {
+	res, body, err := w.request(ctx, http.MethodGet, "accounts/"+accountID, nil)
+	if err != nil {
+		return 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to get account: %s", res.Status)
+	}
+
+	var account struct {
+		Data struct {
+			Account struct {
+				// YNAB uses plain milliunits unlike ynabber.Milliunits
+				ClearedBalance int64 `json:"cleared_balance"`
+			} `json:"account"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &account); err != nil {
+		return 0, err
+	}
+	return ynabber.Milliunits(account.Data.Account.ClearedBalance), nil
+}
+
+// markReconciled updates the transactions with importIDs to reconciled
+func (w Writer) markReconciled(ctx context.Context, importIDs []string) error {
+	type update struct {
+		ImportID string `json:"import_id"`
+		Cleared  string `json:"cleared"`
+	}
+	updates := struct {
+		Transactions []update `json:"transactions"`
+	}{}
+	for _, id := range importIDs {
+		updates.Transactions = append(updates.Transactions, update{ImportID: id, Cleared: "reconciled"})
+	}
+
+	res, _, err := w.request(ctx, http.MethodPatch, "transactions", updates)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != 209 {
+		return fmt.Errorf("failed to update transactions: %s", res.Status)
+	}
+	return nil
+}
+
+// reconcile marks the transactions of each account in r reconciled if the
+// cleared balance in YNAB matches the balance reported by the bank. Failures
+// are only logged since the transactions are already imported.
+func (w Writer) reconcile(ctx context.Context, r []reconciliation) {
+	for _, account := range r {
+		cleared, err := w.clearedBalance(ctx, account.AccountID)
+		if err != nil {
+			slog.Warn("Not reconciling account", "account", account.Name, "error", err)
+			continue
+		}
+		if cleared != account.Balance {
+			slog.Info("Not reconciling account, cleared balance doesn't match bank balance",
+				"account", account.Name, "cleared", cleared, "balance", account.Balance)
+			continue
+		}
+		if err := w.markReconciled(ctx, account.ImportIDs); err != nil {
+			slog.Warn("Not reconciling account", "account", account.Name, "error", err)
+			continue
+		}
+		slog.Info("Reconciled transactions",
+			"count", len(account.ImportIDs), "account", account.Name, "balance", account.Balance)
+	}
+}
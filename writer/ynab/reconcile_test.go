@@ -0,0 +1,147 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// fakeYNAB serves the account balances in balances and records the created
+// transactions and the import IDs of updated and reconciled transactions.
+// Transactions with an import ID already created are reported as duplicates.
+// Creating transactions fails while fail is true.
+type fakeYNAB struct {
+	balances   map[string]ynabber.Milliunits
+	created    []Ytransaction
+	updated    []string
+	amounts    []string
+	reconciled []string
+	fail       bool
+}
+
+func (f *fakeYNAB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && f.fail:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case r.Method == http.MethodPost && r.URL.Path == "/budgets/budget/transactions":
+		var body Ytransactions
+		json.NewDecoder(r.Body).Decode(&body)
+		var response postResponse
+		for _, t := range body.Transactions {
+			duplicate := false
+			for _, c := range f.created {
+				duplicate = duplicate || c.ImportID == t.ImportID
+			}
+			if duplicate {
+				response.Data.DuplicateImportIDs = append(response.Data.DuplicateImportIDs, t.ImportID)
+				continue
+			}
+			f.created = append(f.created, t)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	case r.Method == http.MethodPatch && r.URL.Path == "/budgets/budget/transactions":
+		var body struct {
+			Transactions []struct {
+				ImportID string `json:"import_id"`
+				Cleared  string `json:"cleared"`
+				Amount   string `json:"amount"`
+			} `json:"transactions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, t := range body.Transactions {
+			f.updated = append(f.updated, t.ImportID)
+			f.amounts = append(f.amounts, t.Amount)
+			if t.Cleared == "reconciled" {
+				f.reconciled = append(f.reconciled, t.ImportID)
+			}
+		}
+		w.WriteHeader(209)
+	case r.Method == http.MethodGet:
+		var account string
+		fmt.Sscanf(r.URL.Path, "/budgets/budget/accounts/%s", &account)
+		fmt.Fprintf(w, `{"data": {"account": {"cleared_balance": %d}}}`, f.balances[account])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestReconcileMatched(t *testing.T) {
+	fake := &fakeYNAB{balances: map[string]ynabber.Milliunits{
+		"matched":    -10000,
+		"mismatched": 5000,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	balance := func(m ynabber.Milliunits) *ynabber.Milliunits { return &m }
+	date := time.Now().AddDate(0, 0, -1)
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{Name: "A", IBAN: "a", Balance: balance(-10000)}, ID: "1", Date: date, Amount: -10000},
+		{Account: ynabber.Account{Name: "B", IBAN: "b", Balance: balance(6000)}, ID: "2", Date: date, Amount: 1000},
+		{Account: ynabber.Account{Name: "C", IBAN: "c"}, ID: "3", Date: date, Amount: 1000},
+	}
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		BudgetID: "budget",
+		AccountMap: map[string]string{
+			"a": "matched",
+			"b": "mismatched",
+			"c": "unknown",
+		},
+		Cleared:          "reconciled",
+		ReconcileMatched: true,
+	}}
+	w := Writer{Config: &cfg}
+	if err := w.Bulk(context.Background(), transactions); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, created := range fake.created {
+		if created.Cleared != "cleared" {
+			t.Errorf("transaction %s created as %s, want cleared", created.ImportID, created.Cleared)
+		}
+	}
+	want := []string{makeID(cfg, transactions[0])}
+	if !reflect.DeepEqual(fake.reconciled, want) {
+		t.Errorf("got reconciled %v, want %v", fake.reconciled, want)
+	}
+}
+
+func TestReconciliations(t *testing.T) {
+	balance := ynabber.Milliunits(1000)
+	cfg := ynabber.Config{}
+	if err := cfg.YNAB.SwapFlow.Decode("b"); err != nil {
+		t.Fatal(err)
+	}
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{Name: "B", IBAN: "b", Balance: &balance}},
+		{Account: ynabber.Account{Name: "A", IBAN: "a", Balance: &balance}},
+		{Account: ynabber.Account{Name: "A", IBAN: "a", Balance: &balance}},
+		{Account: ynabber.Account{Name: "C", IBAN: "c"}},
+	}
+	y := []Ytransaction{
+		{AccountID: "y-b", ImportID: "1"},
+		{AccountID: "y-a", ImportID: "2"},
+		{AccountID: "y-a", ImportID: "3"},
+		{AccountID: "y-c", ImportID: "4"},
+	}
+
+	got := reconciliations(cfg, transactions, y)
+	want := []reconciliation{
+		{AccountID: "y-a", Name: "A", Balance: 1000, ImportIDs: []string{"2", "3"}},
+		{AccountID: "y-b", Name: "B", Balance: -1000, ImportIDs: []string{"1"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
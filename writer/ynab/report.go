@@ -0,0 +1,95 @@
+package ynab
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// reportEntry is a single transaction in the dry-run report showing the
+// transaction as read next to what would be sent to YNAB
+type reportEntry struct {
+	Account       string `json:"account"`
+	IBAN          string `json:"iban"`
+	ID            string `json:"id"`
+	OriginalPayee string `json:"original_payee"`
+	OriginalMemo  string `json:"original_memo"`
+	Ytransaction
+}
+
+// newReportEntry returns the entry of t sent as y. The payee and memo are
+// the ones read before the transformers changed them.
+func newReportEntry(t ynabber.Transaction, y Ytransaction) reportEntry {
+	original := ynabber.Original{Payee: t.Payee, Memo: t.Memo}
+	if t.Original != nil {
+		original = *t.Original
+	}
+	return reportEntry{
+		Account:       t.Account.Name,
+		IBAN:          t.Account.IBAN,
+		ID:            string(t.ID),
+		OriginalPayee: string(original.Payee),
+		OriginalMemo:  original.Memo,
+		Ytransaction:  y,
+	}
+}
+
+var reportHeader = []string{
+	"account", "iban", "id", "original_payee", "original_memo", "account_id",
+	"date", "amount", "payee_name", "memo", "import_id", "cleared",
+	"approved", "flag_color", "subtransactions",
+}
+
+func (e reportEntry) record() []string {
+	return []string{
+		e.Account, e.IBAN, e.ID, e.OriginalPayee, e.OriginalMemo, e.AccountID,
+		e.Date, e.Amount, e.PayeeName, e.Memo, e.ImportID, e.Cleared,
+		strconv.FormatBool(e.Approved), e.FlagColor,
+		strconv.Itoa(len(e.Subtransactions)),
+	}
+}
+
+// String returns the entry on a single line for the dry-run log
+func (e reportEntry) String() string {
+	return fmt.Sprintf("%s %s %q on account '%s' to YNAB account %s with import ID %s",
+		e.Date, e.Amount, e.PayeeName, e.Account, e.AccountID, e.ImportID)
+}
+
+// reportStore returns a clean path to the dry-run report inside the data dir
+func (w Writer) reportStore() string {
+	return path.Clean(fmt.Sprintf("%s/%s", w.Config.DataDir, w.Config.YNAB.DryRunReport))
+}
+
+// writeReport writes entries to the dry-run report. The report is written as
+// CSV if the file ends in .csv and as JSON otherwise.
+func (w Writer) writeReport(entries []reportEntry) (string, error) {
+	file := w.reportStore()
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(path.Ext(file), ".csv") {
+		c := csv.NewWriter(f)
+		records := [][]string{reportHeader}
+		for _, e := range entries {
+			records = append(records, e.record())
+		}
+		err = c.WriteAll(records)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	}
+	if err != nil {
+		return "", err
+	}
+	return file, f.Close()
+}
@@ -0,0 +1,170 @@
+package ynab
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestDryRunReport(t *testing.T) {
+	transactions := []ynabber.Transaction{
+		{
+			Account: ynabber.Account{Name: "Checking", IBAN: "foobar"},
+			ID:      "abc",
+			Date:    time.Now().AddDate(0, 0, -1),
+			Payee:   "  PAYPAL   *SPOTIFY ",
+			Memo:    "Spotify  subscription",
+			Amount:  -99000,
+		},
+	}
+
+	for _, file := range []string{"report.json", "report.csv"} {
+		t.Run(file, func(t *testing.T) {
+			dir := t.TempDir()
+			w := Writer{Config: &ynabber.Config{
+				DataDir: dir,
+				YNAB: ynabber.YNAB{
+					AccountMap:   map[string]string{"foobar": "ynab-account"},
+					Cleared:      "cleared",
+					DryRun:       true,
+					DryRunReport: file,
+				},
+			}}
+			if err := w.Bulk(context.Background(), transactions); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(path.Join(dir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			var got reportEntry
+			if path.Ext(file) == ".csv" {
+				records, err := csv.NewReader(f).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(records) != 2 {
+					t.Fatalf("got %d records, want header and 1 transaction", len(records))
+				}
+				r := records[1]
+				got = reportEntry{OriginalPayee: r[3], Ytransaction: Ytransaction{
+					AccountID: r[5], PayeeName: r[8], ImportID: r[10], Cleared: r[11],
+				}}
+			} else {
+				var entries []reportEntry
+				if err := json.NewDecoder(f).Decode(&entries); err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != 1 {
+					t.Fatalf("got %d entries, want 1", len(entries))
+				}
+				got = entries[0]
+			}
+
+			if got.OriginalPayee != "  PAYPAL   *SPOTIFY " || got.PayeeName != "PAYPAL *SPOTIFY" {
+				t.Errorf("payee: got %q -> %q", got.OriginalPayee, got.PayeeName)
+			}
+			if got.AccountID != "ynab-account" || got.Cleared != "cleared" {
+				t.Errorf("got account %q and cleared %q", got.AccountID, got.Cleared)
+			}
+			if got.ImportID != makeID(*w.Config, transactions[0]) {
+				t.Errorf("got import ID %q", got.ImportID)
+			}
+		})
+	}
+}
+
+func TestDryRunLog(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		BudgetID:   "budget",
+		AccountMap: map[string]string{"foobar": "ynab-account"},
+		DryRun:     true,
+	}}
+	transaction := ynabber.Transaction{
+		Account: ynabber.Account{Name: "Checking", IBAN: "foobar"},
+		ID:      "abc",
+		Date:    time.Now().AddDate(0, 0, -1),
+		Payee:   "Spotify",
+		Amount:  -99000,
+	}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), []ynabber.Transaction{transaction}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.created) != 0 {
+		t.Errorf("got %d transactions sent, want none", len(fake.created))
+	}
+	for _, want := range []string{"ynab-account", makeID(cfg, transaction), `"Spotify"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log %q doesn't contain %q", buf.String(), want)
+		}
+	}
+}
+
+func TestUnmappedAccountLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	transaction := ynabber.Transaction{
+		Account: ynabber.Account{Name: "Checking", IBAN: "foobar"},
+		Date:    time.Now().AddDate(0, 0, -1),
+		Amount:  -99000,
+	}
+	tests := []struct {
+		name       string
+		accountMap map[string]string
+		want       string
+	}{
+		{name: "empty map", want: "no mapping for account 'Checking': no account for: foobar, the account map is empty"},
+		{name: "missing account", accountMap: map[string]string{"other": "abc"}, want: "no mapping for account 'Checking': no account for: foobar in map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			cfg := ynabber.Config{YNAB: ynabber.YNAB{BudgetID: "budget", AccountMap: tt.accountMap, DryRun: true}}
+			if err := (Writer{Config: &cfg}).Bulk(context.Background(), []ynabber.Transaction{transaction}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("log %q doesn't contain %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReportEntryOriginal(t *testing.T) {
+	// The transformers changed the payee and memo after they were read
+	tx := ynabber.Transaction{
+		Payee:    "Spotify",
+		Memo:     "Music [50/50]",
+		Original: &ynabber.Original{Payee: "PAYPAL *SPOTIFY", Memo: "Music"},
+	}
+	got := newReportEntry(tx, Ytransaction{})
+	if got.OriginalPayee != "PAYPAL *SPOTIFY" || got.OriginalMemo != "Music" {
+		t.Errorf("got %q and %q, want the payee and memo as read", got.OriginalPayee, got.OriginalMemo)
+	}
+}
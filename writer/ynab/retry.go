@@ -0,0 +1,109 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sleep waits for d or until ctx is done, it's a variable to allow testing
+// without waiting
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// transient reports whether a request that returned res and err may succeed
+// if retried. Only network errors and server errors are retried, a 4xx is a
+// problem with the request itself.
+func transient(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+// defaultRetryAfter is the wait after a 429 without a valid Retry-After
+const defaultRetryAfter = 60 * time.Second
+
+// retryAfter returns the wait given by the Retry-After header value h, which
+// is either a number of seconds or an HTTP date
+func retryAfter(h string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(h); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return defaultRetryAfter
+}
+
+// backoff returns the delay before retry number attempt, starting from 0
+func backoff(base time.Duration, attempt int) time.Duration {
+	return base * time.Duration(1<<attempt)
+}
+
+// request sends body as JSON to path in the budget and returns the response
+// with the body read. Transient failures are retried up to MaxRetries times
+// with exponential backoff.
+func (w Writer) request(ctx context.Context, method, path string, body interface{}) (*http.Response, []byte, error) {
+	url := fmt.Sprintf("%s/budgets/%s/%s", baseURL, w.Config.YNAB.BudgetID, path)
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	retries := max(w.Config.YNAB.MaxRetries, 0)
+	throttled := false
+	for attempt := 0; ; attempt++ {
+		res, b, err := w.do(ctx, method, url, payload)
+
+		// Wait out the rate limit once, it's per hour so there's no point in
+		// trying repeatedly
+		if err == nil && res.StatusCode == http.StatusTooManyRequests && !throttled {
+			throttled = true
+			attempt--
+			delay := retryAfter(res.Header.Get("Retry-After"), time.Now())
+			slog.Warn("Rate limited by YNAB, retrying", "delay", delay)
+			if err := sleep(ctx, delay); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if !transient(res, err) {
+			return res, b, err
+		}
+
+		reason := err
+		if reason == nil {
+			reason = fmt.Errorf("%s", res.Status)
+		}
+		if attempt >= retries {
+			if retries > 0 {
+				slog.Error("Request to YNAB failed", "attempts", attempt+1, "error", reason)
+			}
+			return res, b, err
+		}
+
+		delay := backoff(w.Config.YNAB.RetryBackoff, attempt)
+		slog.Warn(fmt.Sprintf("Request to YNAB failed, retry %d of %d", attempt+1, retries),
+			"error", reason, "delay", delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, nil, err
+		}
+	}
+}
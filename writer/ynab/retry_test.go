@@ -0,0 +1,181 @@
+package ynab
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestRequestRetry(t *testing.T) {
+	defer func(url string) { baseURL = url }(baseURL)
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+
+	var delays []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error { delays = append(delays, d); return nil }
+
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+		requests int
+		delays   []time.Duration
+	}{
+		{
+			name:     "success",
+			statuses: []int{http.StatusCreated},
+			want:     http.StatusCreated,
+			requests: 1,
+		},
+		{
+			name:     "recovers",
+			statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusCreated},
+			want:     http.StatusCreated,
+			requests: 3,
+			delays:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "gives up",
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusCreated},
+			want:     http.StatusInternalServerError,
+			requests: 3,
+			delays:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "client error",
+			statuses: []int{http.StatusBadRequest, http.StatusCreated},
+			want:     http.StatusBadRequest,
+			requests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[requests])
+				requests++
+			}))
+			defer server.Close()
+			baseURL = server.URL
+			delays = nil
+
+			cfg := ynabber.Config{YNAB: ynabber.YNAB{MaxRetries: 2, RetryBackoff: time.Second}}
+			res, _, err := Writer{Config: &cfg}.request(context.Background(), http.MethodPost, "transactions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", res.StatusCode, tt.want)
+			}
+			if requests != tt.requests {
+				t.Errorf("got %d requests, want %d", requests, tt.requests)
+			}
+			if !reflect.DeepEqual(delays, tt.delays) {
+				t.Errorf("got delays %v, want %v", delays, tt.delays)
+			}
+		})
+	}
+}
+
+func TestRequestRetryNetworkError(t *testing.T) {
+	defer func(url string) { baseURL = url }(baseURL)
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+
+	retries := 0
+	sleep = func(context.Context, time.Duration) error { retries++; return nil }
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	baseURL = server.URL
+	server.Close()
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{MaxRetries: 2}}
+	if _, _, err := (Writer{Config: &cfg}).request(context.Background(), http.MethodGet, "accounts", nil); err == nil {
+		t.Fatal("want error from closed server")
+	}
+	if retries != 2 {
+		t.Errorf("got %d retries, want 2", retries)
+	}
+}
+
+func TestRequestCancelled(t *testing.T) {
+	defer func(url string) { baseURL = url }(baseURL)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	baseURL = server.URL
+
+	// The real sleep must stop waiting for the backoff once cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{MaxRetries: 2, RetryBackoff: time.Hour}}
+	_, _, err := Writer{Config: &cfg}.request(ctx, http.MethodGet, "accounts", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 11, 4, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "120", want: 2 * time.Minute},
+		{header: "0", want: 0},
+		{header: "Sat, 04 Nov 2023 12:00:30 GMT", want: 30 * time.Second},
+		{header: "Sat, 04 Nov 2023 11:59:00 GMT", want: 0},
+		{header: "", want: defaultRetryAfter},
+		{header: "soon", want: defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestRateLimited(t *testing.T) {
+	defer func(url string) { baseURL = url }(baseURL)
+	defer func(s func(context.Context, time.Duration) error) { sleep = s }(sleep)
+
+	var delays []time.Duration
+	sleep = func(_ context.Context, d time.Duration) error { delays = append(delays, d); return nil }
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	baseURL = server.URL
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{MaxRetries: 2, RetryBackoff: time.Second}}
+	res, _, err := Writer{Config: &cfg}.request(context.Background(), http.MethodPost, "transactions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want the payload retried once", requests)
+	}
+	if want := []time.Duration{5 * time.Second}; !reflect.DeepEqual(delays, want) {
+		t.Errorf("got delays %v, want %v", delays, want)
+	}
+}
@@ -0,0 +1,142 @@
+package ynab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// importIDsFile is the name of the file inside the data dir or S3 bucket
+// mapping the secondary import IDs to the transactions imported to YNAB
+const importIDsFile = "ynab-import-ids.json"
+
+// importIDsRetention is how long after its date a transaction is kept in the
+// import IDs file, banks correct transactions within days
+const importIDsRetention = 90 * 24 * time.Hour
+
+// imported is a transaction as it was last sent to YNAB
+type imported struct {
+	Yupdate
+	Date string `json:"date"`
+}
+
+// secondaryID returns the import ID of t without the amount. It's never sent
+// to YNAB, it's only used to recognize a transaction already imported when
+// the amount is corrected.
+func secondaryID(cfg ynabber.Config, t ynabber.Transaction) string {
+	return hashID(cfg,
+		[]byte(t.Account.IBAN),
+		[]byte(t.ID),
+		[]byte(t.ReadDate().Format("2006-01-02")),
+	)
+}
+
+// correction returns the update of the transaction imported as prev to y.
+// False is returned if prev already matches y.
+func correction(prev imported, y Ytransaction) (Yupdate, bool) {
+	u := Yupdate{
+		ImportID:  prev.ImportID,
+		Amount:    y.Amount,
+		PayeeName: y.PayeeName,
+		Memo:      y.Memo,
+	}
+	return u, u != prev.Yupdate
+}
+
+// loadImportIDs returns the imported transactions by secondary import ID, a
+// missing file is empty
+func (w Writer) loadImportIDs(ctx context.Context) (map[string]imported, error) {
+	index := map[string]imported{}
+	b, err := w.readFile(ctx, importIDsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", w.location(importIDsFile), err)
+	}
+	return index, nil
+}
+
+// saveImportIDs replaces the import IDs file with index, transactions older
+// than importIDsRetention are left out
+func (w Writer) saveImportIDs(ctx context.Context, index map[string]imported, now time.Time) error {
+	oldest := now.Add(-importIDsRetention).Format("2006-01-02")
+	for key, v := range index {
+		if v.Date < oldest {
+			delete(index, key)
+		}
+	}
+	b, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return w.writeFile(ctx, importIDsFile, b)
+}
+
+// correct updates the transactions in fixes, by secondary import ID, that
+// were imported before with another amount, payee or memo. They are only
+// updated if Update is set, otherwise YNAB is left as is.
+func (w Writer) correct(ctx context.Context, index map[string]imported, fixes map[string]imported) {
+	if len(fixes) == 0 {
+		return
+	}
+	if !w.Config.YNAB.Update {
+		slog.Info("Skipped transactions imported before with another amount, set YNAB_UPDATE to update them",
+			"count", len(fixes))
+		return
+	}
+	if w.Config.YNAB.DryRun {
+		slog.Info("Dry run: would have updated transactions imported before with another amount",
+			"count", len(fixes))
+		return
+	}
+
+	keys := make(map[string]string, len(fixes))
+	u := make([]Yupdate, 0, len(fixes))
+	for key, v := range fixes {
+		keys[v.ImportID] = key
+		u = append(u, v.Yupdate)
+	}
+	sort.Slice(u, func(i, j int) bool { return u[i].ImportID < u[j].ImportID })
+
+	updated := w.patchAll(ctx, u)
+	for _, v := range updated {
+		index[keys[v.ImportID]] = fixes[keys[v.ImportID]]
+	}
+	slog.Info(fmt.Sprintf("Updated %d of %d transactions imported before with another amount", len(updated), len(u)))
+	if err := w.saveImportIDs(ctx, index, time.Now()); err != nil {
+		slog.Error("Failed to save import IDs", "error", err)
+	}
+}
+
+// recordImportIDs adds the transactions in y sent to YNAB to index by their
+// secondary import IDs in keys and saves it, the ones in unsent are left
+// out. Failures are only logged since the transactions are already sent.
+func (w Writer) recordImportIDs(ctx context.Context, index map[string]imported, y []Ytransaction, keys []string, unsent []Ytransaction) {
+	failed := make(map[string]bool, len(unsent))
+	for _, v := range unsent {
+		failed[v.ImportID] = true
+	}
+	for i, v := range y {
+		if failed[v.ImportID] {
+			continue
+		}
+		index[keys[i]] = imported{
+			Yupdate: Yupdate{ImportID: v.ImportID, Amount: v.Amount, PayeeName: v.PayeeName, Memo: v.Memo},
+			Date:    v.Date,
+		}
+	}
+	if err := w.saveImportIDs(ctx, index, time.Now()); err != nil {
+		slog.Error("Failed to save import IDs", "error", err)
+	}
+}
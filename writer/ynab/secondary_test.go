@@ -0,0 +1,100 @@
+package ynab
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestSecondaryID(t *testing.T) {
+	pending := ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "foobar"},
+		ID:      "abc",
+		Date:    time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC),
+		Amount:  -10000,
+	}
+	// The same transaction booked with a corrected amount
+	booked := pending
+	booked.Amount = -12500
+
+	cfg := ynabber.Config{}
+	if makeID(cfg, pending) == makeID(cfg, booked) {
+		t.Error("correction got the same import ID")
+	}
+	if secondaryID(cfg, pending) != secondaryID(cfg, booked) {
+		t.Error("correction got a new secondary import ID")
+	}
+
+	other := booked
+	other.ID = "def"
+	if secondaryID(cfg, booked) == secondaryID(cfg, other) {
+		t.Error("different transactions got the same secondary import ID")
+	}
+}
+
+func TestBulkCorrection(t *testing.T) {
+	date := time.Now().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	pending := ynabber.Transaction{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: date, Amount: -10000}
+	booked := pending
+	booked.Amount = -12500
+
+	for _, update := range []bool{false, true} {
+		fake := &fakeYNAB{}
+		server := httptest.NewServer(fake)
+		defer server.Close()
+		defer func(url string) { baseURL = url }(baseURL)
+		baseURL = server.URL
+
+		cfg := ynabber.Config{DataDir: t.TempDir(), YNAB: ynabber.YNAB{
+			BudgetID:          "budget",
+			AccountMap:        map[string]string{"foobar": "abc"},
+			ImportIDSecondary: true,
+			Update:            update,
+		}}
+		w := Writer{Config: &cfg}
+
+		// The pending transaction is imported, then the bank corrects the
+		// amount as it books and it's read again on the next runs
+		for _, tx := range []ynabber.Transaction{pending, booked, booked} {
+			if err := w.Bulk(context.Background(), []ynabber.Transaction{tx}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(fake.created) != 1 || fake.created[0].ImportID != makeID(cfg, pending) {
+			t.Errorf("update %v: got created %+v, want only the pending transaction", update, fake.created)
+		}
+		var wantUpdated, wantAmounts []string
+		if update {
+			wantUpdated = []string{makeID(cfg, pending)}
+			wantAmounts = []string{"-12500"}
+		}
+		if !reflect.DeepEqual(fake.updated, wantUpdated) || !reflect.DeepEqual(fake.amounts, wantAmounts) {
+			t.Errorf("update %v: got updated %v with %v, want %v with %v",
+				update, fake.updated, fake.amounts, wantUpdated, wantAmounts)
+		}
+	}
+}
+
+func TestSaveImportIDsRetention(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	w := Writer{Config: &ynabber.Config{DataDir: t.TempDir()}}
+	index := map[string]imported{
+		"recent": {Yupdate: Yupdate{ImportID: "a"}, Date: "2024-05-01"},
+		"old":    {Yupdate: Yupdate{ImportID: "b"}, Date: "2024-01-01"},
+	}
+	if err := w.saveImportIDs(context.Background(), index, now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := w.loadImportIDs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["recent"]; !ok || len(got) != 1 {
+		t.Errorf("got %+v, want only the recent transaction", got)
+	}
+}
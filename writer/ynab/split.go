@@ -0,0 +1,76 @@
+package ynab
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/martinohansen/ynabber"
+)
+
+// Ysubtransaction is a single part of a split YNAB transaction
+type Ysubtransaction struct {
+	Amount string `json:"amount"`
+	Memo   string `json:"memo,omitempty"`
+}
+
+// parseSplitAmount parses an amount captured from free-text remittance
+// information. Both period and comma is accepted as decimal separator, if
+// both are used the last one is the decimal separator and the other groups
+// thousands.
+func parseSplitAmount(s string) (ynabber.Milliunits, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	} else {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	// Parsed without a float since the parts must sum up exactly
+	return ynabber.MilliunitsFromString(s)
+}
+
+// split returns the subtransactions of t according to the first rule that
+// matches the memo. Nil is returned if no rule applies or if the parts don't
+// sum up to the amount of t. If swap is true the parts are negated the same
+// way SwapFlow negates the transaction.
+func split(rules []ynabber.SplitRule, t ynabber.Transaction, swap bool) []Ysubtransaction {
+	for _, rule := range rules {
+		if !rule.Match.MatchString(t.Memo) {
+			continue
+		}
+
+		var sum ynabber.Milliunits
+		var parts []Ysubtransaction
+		for _, match := range rule.Parts.FindAllStringSubmatch(t.Memo, -1) {
+			amount, err := parseSplitAmount(match[rule.Parts.SubexpIndex("amount")])
+			if err != nil {
+				slog.Warn("Not splitting transaction", "account", t.Account.Name, "error", err)
+				return nil
+			}
+
+			var memo string
+			if i := rule.Parts.SubexpIndex("memo"); i != -1 {
+				memo = strings.TrimSpace(match[i])
+			}
+
+			sum += amount
+			if swap {
+				amount = amount.Negate()
+			}
+			parts = append(parts, Ysubtransaction{Amount: amount.String(), Memo: memo})
+		}
+
+		if len(parts) < 2 {
+			slog.Warn("Not splitting transaction, too few parts",
+				"account", t.Account.Name, "parts", len(parts))
+			return nil
+		}
+		if sum != t.Amount {
+			slog.Warn("Not splitting transaction, parts don't sum to the amount",
+				"account", t.Account.Name, "sum", sum, "amount", t.Amount)
+			return nil
+		}
+		return parts
+	}
+	return nil
+}
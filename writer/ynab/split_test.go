@@ -0,0 +1,93 @@
+package ynab
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestSplit(t *testing.T) {
+	var rules ynabber.SplitRules
+	err := rules.Decode(`[{"match": "^SALARY", "parts": "(?P<memo>GROSS|TAX|PENSION) (?P<amount>-?[0-9.,]+)"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payslip := "SALARY 2023-02 GROSS 32000.00 TAX -6123.45 PENSION -1000.00"
+
+	tests := []struct {
+		name string
+		t    ynabber.Transaction
+		swap bool
+		want []Ysubtransaction
+	}{
+		{
+			name: "payslip",
+			t:    ynabber.Transaction{Memo: payslip, Amount: 24876550},
+			want: []Ysubtransaction{
+				{Amount: "32000000", Memo: "GROSS"},
+				{Amount: "-6123450", Memo: "TAX"},
+				{Amount: "-1000000", Memo: "PENSION"},
+			},
+		},
+		{
+			name: "swap",
+			t:    ynabber.Transaction{Memo: payslip, Amount: 24876550},
+			swap: true,
+			want: []Ysubtransaction{
+				{Amount: "-32000000", Memo: "GROSS"},
+				{Amount: "6123450", Memo: "TAX"},
+				{Amount: "1000000", Memo: "PENSION"},
+			},
+		},
+		{
+			name: "sum mismatch",
+			t:    ynabber.Transaction{Memo: payslip, Amount: 25000000},
+			want: nil,
+		},
+		{
+			name: "no match",
+			t:    ynabber.Transaction{Memo: "GROSS 10.00 TAX -5.00", Amount: 5000},
+			want: nil,
+		},
+		{
+			name: "single part",
+			t:    ynabber.Transaction{Memo: "SALARY GROSS 10.00", Amount: 10000},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := split(rules, tt.t, tt.swap)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSplitAmount(t *testing.T) {
+	tests := []struct {
+		s    string
+		want ynabber.Milliunits
+	}{
+		{s: "32000.00", want: 32000000},
+		{s: "-6123.45", want: -6123450},
+		{s: "19,99", want: 19990},
+		{s: "1,234.56", want: 1234560},
+		{s: "1.234,56", want: 1234560},
+		{s: "-1.234.567,89", want: -1234567890},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseSplitAmount(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package ynab
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/martinohansen/ynabber"
+)
+
+// objectAPI is the part of the S3 client used to store the files of the
+// writer
+type objectAPI interface {
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// NewWriter returns a new YNAB writer. The outbox and import IDs are stored in
+// the S3 bucket if one is configured, since the data dir doesn't survive
+// between runs on Lambda. The AWS credentials and region are read from the
+// environment.
+func NewWriter(cfg *ynabber.Config) (Writer, error) {
+	w := Writer{Config: cfg}
+	if cfg.S3.Bucket == "" {
+		return w, nil
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return Writer{}, fmt.Errorf("loading AWS config: %w", err)
+	}
+	w.S3Client = s3.NewFromConfig(awsConfig)
+	return w, nil
+}
+
+// location returns where the file name is stored for logs and errors
+func (w Writer) location(name string) string {
+	if w.S3Client == nil {
+		return filepath.Join(w.Config.DataDir, name)
+	}
+	return fmt.Sprintf("s3://%s/%s%s", w.Config.S3.Bucket, w.Config.S3.Prefix, name)
+}
+
+// readFile returns the content of the file name, the error wraps
+// os.ErrNotExist if it doesn't exist
+func (w Writer) readFile(ctx context.Context, name string) ([]byte, error) {
+	if w.S3Client == nil {
+		return os.ReadFile(w.location(name))
+	}
+
+	out, err := w.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(w.Config.S3.Bucket),
+		Key:    aws.String(w.Config.S3.Prefix + name),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("%s: %w", w.location(name), os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", w.location(name), err)
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// writeFile replaces the file name with b
+func (w Writer) writeFile(ctx context.Context, name string, b []byte) error {
+	if w.S3Client == nil {
+		return os.WriteFile(w.location(name), b, 0600)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(w.Config.S3.Bucket),
+		Key:         aws.String(w.Config.S3.Prefix + name),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+	if w.Config.S3.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(w.Config.S3.ServerSideEncryption)
+	}
+	if w.Config.S3.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(w.Config.S3.KMSKeyID)
+	}
+	if _, err := w.S3Client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("uploading %s: %w", w.location(name), err)
+	}
+	return nil
+}
+
+// removeFile removes the file name, a missing file isn't an error
+func (w Writer) removeFile(ctx context.Context, name string) error {
+	if w.S3Client == nil {
+		err := os.Remove(w.location(name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	_, err := w.S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(w.Config.S3.Bucket),
+		Key:    aws.String(w.Config.S3.Prefix + name),
+	})
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", w.location(name), err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package ynab
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Yupdate is the fields of an existing YNAB transaction that are updated when
+// the bank has changed the transaction after it was imported
+type Yupdate struct {
+	ImportID  string `json:"import_id"`
+	Amount    string `json:"amount"`
+	PayeeName string `json:"payee_name"`
+	Memo      string `json:"memo"`
+}
+
+// Yupdates is multiple YNAB transaction updates
+type Yupdates struct {
+	Transactions []Yupdate `json:"transactions"`
+}
+
+// updates returns the updates for the transactions in y with an import ID in
+// importIDs
+func updates(y []Ytransaction, importIDs []string) []Yupdate {
+	wanted := make(map[string]bool, len(importIDs))
+	for _, id := range importIDs {
+		wanted[id] = true
+	}
+
+	var u []Yupdate
+	for _, v := range y {
+		if !wanted[v.ImportID] {
+			continue
+		}
+		u = append(u, Yupdate{
+			ImportID:  v.ImportID,
+			Amount:    v.Amount,
+			PayeeName: v.PayeeName,
+			Memo:      v.Memo,
+		})
+		// Only update once if the import ID is shared
+		delete(wanted, v.ImportID)
+	}
+	return u
+}
+
+// patch updates the transactions in u by import ID in a single request
+func (w Writer) patch(ctx context.Context, u []Yupdate) error {
+	res, _, err := w.request(ctx, http.MethodPatch, "transactions", Yupdates{Transactions: u})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != 209 {
+		return fmt.Errorf("failed to update transactions: %s", res.Status)
+	}
+	return nil
+}
+
+// update overwrites the amount, payee and memo of the transactions YNAB
+// reported as duplicates with the ones in y. Failures are only logged since
+// the new transactions are already imported.
+func (w Writer) update(ctx context.Context, y []Ytransaction, duplicates []string) {
+	u := updates(y, duplicates)
+	updated := w.patchAll(ctx, u)
+	slog.Info(fmt.Sprintf("Updated %d of %d already imported transactions", len(updated), len(u)))
+}
+
+// patchAll updates the transactions in u in requests within BatchSize and
+// returns the ones updated. Failures are only logged.
+func (w Writer) patchAll(ctx context.Context, u []Yupdate) []Yupdate {
+	var updated []Yupdate
+	for i := 0; i < len(u); {
+		n := len(u) - i
+		if size := w.Config.YNAB.BatchSize; size > 0 && n > size {
+			n = size
+		}
+		if err := w.patch(ctx, u[i:i+n]); err != nil {
+			slog.Error("Failed to update transactions", "count", n, "error", err)
+		} else {
+			updated = append(updated, u[i:i+n]...)
+		}
+		i += n
+	}
+	return updated
+}
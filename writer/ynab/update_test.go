@@ -0,0 +1,74 @@
+package ynab
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martinohansen/ynabber"
+)
+
+func TestUpdates(t *testing.T) {
+	y := []Ytransaction{
+		{ImportID: "1", Amount: "1", PayeeName: "A", Memo: "a", Cleared: "cleared"},
+		{ImportID: "2", Amount: "2", PayeeName: "B", Memo: "b"},
+		{ImportID: "1", Amount: "3", PayeeName: "C", Memo: "c"},
+	}
+	got := updates(y, []string{"1"})
+	want := []Yupdate{{ImportID: "1", Amount: "1", PayeeName: "A", Memo: "a"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBulkUpdate(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	cfg := ynabber.Config{DataDir: t.TempDir(), YNAB: ynabber.YNAB{
+		BudgetID:   "budget",
+		AccountMap: map[string]string{"foobar": "abc"},
+		Update:     true,
+	}}
+	w := Writer{Config: &cfg}
+
+	date := time.Now().AddDate(0, 0, -1)
+	pending := ynabber.Transaction{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: date, Amount: 1000}
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{pending}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.updated) != 0 {
+		t.Fatalf("got updated %v on first import, want none", fake.updated)
+	}
+
+	booked := pending
+	booked.Payee = "Shop"
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{booked}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{makeID(cfg, booked)}; !reflect.DeepEqual(fake.updated, want) {
+		t.Errorf("got updated %v, want %v", fake.updated, want)
+	}
+
+	// The amount finalizes which changes the import ID, the transaction is
+	// still updated instead of imported again
+	final := booked
+	final.Amount = 1250
+	if err := w.Bulk(context.Background(), []ynabber.Transaction{final}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.created) != 1 {
+		t.Errorf("got created %+v, want only the pending transaction", fake.created)
+	}
+	if want := []string{makeID(cfg, booked), makeID(cfg, pending)}; !reflect.DeepEqual(fake.updated, want) {
+		t.Errorf("got updated %v, want %v", fake.updated, want)
+	}
+	if got := fake.amounts[len(fake.amounts)-1]; got != "1250" {
+		t.Errorf("got amount %s, want 1250", got)
+	}
+}
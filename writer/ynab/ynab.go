@@ -2,24 +2,41 @@ package ynab
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/martinohansen/ynabber"
+	"golang.org/x/text/unicode/norm"
 )
 
 const maxMemoSize int = 200  // Max size of memo field in YNAB API
 const maxPayeeSize int = 100 // Max size of payee field in YNAB API
 
+const defaultImportIDLength int = 32          // Length of import IDs unless configured
+const defaultImportIDPrefix string = "YBBRTZ" // Prefix of import IDs unless configured
+const maxImportIDLength int = 36              // Max size of import_id field in YNAB API
+
+// baseURL is the YNAB API, it's a variable to allow testing against a fake
+var baseURL = "https://api.youneedabudget.com/v1"
+
 type Writer struct {
 	Config *ynabber.Config
+
+	// S3Client stores the outbox and import IDs in the S3 bucket, they are
+	// stored in the data dir if nil
+	S3Client objectAPI
 }
 
 var space = regexp.MustCompile(`\s+`) // Matches all whitespace characters
@@ -34,6 +51,11 @@ type Ytransaction struct {
 	ImportID  string `json:"import_id"`
 	Cleared   string `json:"cleared"`
 	Approved  bool   `json:"approved"`
+	FlagColor string `json:"flag_color,omitempty"`
+
+	CategoryID string `json:"category_id,omitempty"`
+
+	Subtransactions []Ysubtransaction `json:"subtransactions,omitempty"`
 }
 
 // Ytransactions is multiple YNAB transactions
@@ -44,61 +66,261 @@ type Ytransactions struct {
 // accountParser takes IBAN and returns the matching YNAB account ID in
 // accountMap
 func accountParser(iban string, accountMap map[string]string) (string, error) {
-	for from, to := range accountMap {
-		if iban == from {
-			return to, nil
-		}
+	if len(accountMap) == 0 {
+		return "", fmt.Errorf("no account for: %s, the account map is empty", iban)
+	}
+	if to, ok := accountMap[iban]; ok {
+		return to, nil
 	}
 	return "", fmt.Errorf("no account for: %s in map: %s", iban, accountMap)
 }
 
+// codeFlag returns the flag color for the bank transaction code by looking up
+// the code and then its family and domain in codeFlags
+func codeFlag(code string, codeFlags map[string]string) string {
+	parts := strings.Split(code, "-")
+	for i := len(parts); i > 0; i-- {
+		if color, ok := codeFlags[strings.Join(parts[:i], "-")]; ok {
+			return color
+		}
+	}
+	return ""
+}
+
+// category returns the category ID of the first rule in rules matching payee
+// or memo
+func category(rules []ynabber.CategoryRule, payee, memo string) string {
+	for _, rule := range rules {
+		if rule.Payee != "" && rule.Payee == payee {
+			return rule.CategoryID
+		}
+		if rule.Memo != nil && rule.Memo.MatchString(memo) {
+			return rule.CategoryID
+		}
+	}
+	return ""
+}
+
+// mapPayee returns the payee of the first rule in rules matching payee, or payee
+// itself if none match
+func mapPayee(rules []ynabber.PayeeRule, payee string) string {
+	for _, rule := range rules {
+		if rule.Match.MatchString(payee) {
+			return rule.Payee
+		}
+	}
+	return payee
+}
+
+// ruleFlag returns the color of the first rule in rules matching payee or memo
+func ruleFlag(rules []ynabber.FlagRule, payee, memo string) string {
+	for _, rule := range rules {
+		if rule.Match.MatchString(payee) || rule.Match.MatchString(memo) {
+			return rule.Color
+		}
+	}
+	return ""
+}
+
 // makeID returns a unique YNAB import ID to avoid duplicate transactions.
+// The date as read is used so clamping the date doesn't change the ID.
 func makeID(cfg ynabber.Config, t ynabber.Transaction) string {
-	date := t.Date.Format("2006-01-02")
-	amount := t.Amount.String()
+	date := t.ReadDate().Format("2006-01-02")
 
-	s := [][]byte{
+	// Version 3 replaces the transaction ID with the payee from its cutover
+	// date and onward
+	v3 := time.Time(cfg.YNAB.ImportIDV3)
+	if !v3.IsZero() && !t.ReadDate().Before(v3) {
+		return hashID(cfg,
+			[]byte(t.Account.IBAN),
+			[]byte(date),
+			[]byte(t.Payee),
+			[]byte(t.Amount.String()),
+		)
+	}
+	return hashID(cfg,
 		[]byte(t.Account.IBAN),
 		[]byte(t.ID),
 		[]byte(date),
-		[]byte(amount),
+		[]byte(t.Amount.String()),
+	)
+}
+
+// hashID returns an import ID made from the hash of parts
+func hashID(cfg ynabber.Config, parts ...[]byte) string {
+	hash := sha256.Sum256(bytes.Join(parts, []byte("")))
+
+	// The hash has a fixed length regardless of the amount and date, so
+	// cutting the ID keeps it within the limit of YNAB. It's cut by
+	// characters so a non-ASCII prefix isn't cut in the middle of one.
+	id := []rune(fmt.Sprintf("%s:%x", importIDPrefix(cfg), hash))
+	return string(id[:importIDLength(cfg)])
+}
+
+// ImportID returns the import ID the transaction is imported to YNAB with
+func ImportID(cfg ynabber.Config, t ynabber.Transaction) string {
+	return makeID(cfg, t)
+}
+
+// importIDLength returns the configured length of import IDs within the
+// limit of YNAB
+func importIDLength(cfg ynabber.Config) int {
+	length := cfg.YNAB.ImportIDLength
+	if length <= 0 {
+		return defaultImportIDLength
+	}
+	if length > maxImportIDLength {
+		return maxImportIDLength
+	}
+	return length
+}
+
+// importIDPrefix returns the configured prefix of import IDs
+func importIDPrefix(cfg ynabber.Config) string {
+	if cfg.YNAB.ImportIDPrefix == "" {
+		return defaultImportIDPrefix
+	}
+	return cfg.YNAB.ImportIDPrefix
+}
+
+// collisions returns the import IDs shared by different transactions in t
+func collisions(t []ynabber.Transaction, y []Ytransaction) map[string][]ynabber.Transaction {
+	seen := make(map[string]ynabber.Transaction, len(y))
+	found := make(map[string][]ynabber.Transaction)
+	for i, v := range y {
+		first, ok := seen[v.ImportID]
+		if !ok {
+			seen[v.ImportID] = t[i]
+			continue
+		}
+		if first.Account.IBAN == t[i].Account.IBAN && first.ID == t[i].ID {
+			continue
+		}
+		if len(found[v.ImportID]) == 0 {
+			found[v.ImportID] = []ynabber.Transaction{first}
+		}
+		found[v.ImportID] = append(found[v.ImportID], t[i])
 	}
-	hash := sha256.Sum256(bytes.Join(s, []byte("")))
-	return fmt.Sprintf("YBBRTZ:%x", hash)[:32]
+	return found
+}
+
+// cleanPayee replaces all kinds of whitespace with a single space and removes
+// non-printable characters, like the zero-width spaces some banks add, so
+// payees looking the same are the same. With nfkc the payee is also normalized
+// to NFKC first, which turns for example full-width letters and ligatures into
+// their plain forms.
+func cleanPayee(payee string, nfkc bool) string {
+	if nfkc {
+		payee = norm.NFKC.String(payee)
+	}
+	payee = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case !unicode.IsPrint(r):
+			return -1
+		}
+		return r
+	}, payee)
+	return strings.TrimSpace(space.ReplaceAllString(payee, " "))
+}
+
+// truncate shortens s to at most n characters. The cut is moved back to the
+// last space if there is one in the second half, so words are kept whole
+// unless that would throw away most of s.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	n = max(n, 0)
+	if r[n] != ' ' {
+		for i := n - 1; i > n/2; i-- {
+			if r[i] == ' ' {
+				n = i
+				break
+			}
+		}
+	}
+	return strings.TrimSpace(string(r[:n]))
 }
 
 func ynabberToYNAB(cfg ynabber.Config, t ynabber.Transaction) (Ytransaction, error) {
 	accountID, err := accountParser(t.Account.IBAN, cfg.YNAB.AccountMap)
 	if err != nil {
-		return Ytransaction{}, err
+		return Ytransaction{}, fmt.Errorf("no mapping for account '%s': %w", t.Account.Name, err)
 	}
 
 	date := t.Date.Format("2006-01-02")
 
+	// Append the institution to the memo if configured to do so, the memo is
+	// truncated to make room for it
+	suffix := ""
+	if cfg.YNAB.MemoInstitution && t.Account.Institution != "" {
+		suffix = fmt.Sprintf(" (%s)", t.Account.Institution)
+	}
+
 	// Trim consecutive spaces from memo and truncate if too long
 	memo := strings.TrimSpace(space.ReplaceAllString(t.Memo, " "))
-	if len(memo) > maxMemoSize {
-		log.Printf("Memo on account %s on date %s is too long - truncated to %d characters",
-			t.Account.Name, date, maxMemoSize)
-		memo = memo[0:(maxMemoSize - 1)]
-	}
-
-	// Trim consecutive spaces from payee and truncate if too long
-	payee := strings.TrimSpace(space.ReplaceAllString(string(t.Payee), " "))
-	if len(payee) > maxPayeeSize {
-		log.Printf("Payee on account %s on date %s is too long - truncated to %d characters",
-			t.Account.Name, date, maxPayeeSize)
-		payee = payee[0:(maxPayeeSize - 1)]
-	}
-
-	// If SwapFlow is defined check if the account is configured to swap inflow
-	// to outflow. If so swap it by using the Negate method.
-	if cfg.YNAB.SwapFlow != nil {
-		for _, account := range cfg.YNAB.SwapFlow {
-			if account == t.Account.IBAN {
-				t.Amount = t.Amount.Negate()
-			}
-		}
+
+	// The memo tag of the account is kept in front of the suffix so
+	// truncating never cuts it
+	if tag := cfg.Transform.MemoTags[t.Account.IBAN]; tag != "" && strings.HasSuffix(memo, tag) {
+		memo = strings.TrimSpace(strings.TrimSuffix(memo, tag))
+		suffix = " " + tag + suffix
+	}
+	if memoSize := maxMemoSize - utf8.RuneCountInString(suffix); utf8.RuneCountInString(memo) > memoSize {
+		slog.Info("Memo is too long, truncated",
+			"account", t.Account.Name, "date", date, "characters", memoSize)
+		memo = truncate(memo, memoSize)
+	}
+	memo = strings.TrimSpace(memo + suffix)
+
+	// Clean up whitespace and invisible characters in payee and truncate if
+	// too long
+	payee := cleanPayee(string(t.Payee), cfg.YNAB.NormalizePayee)
+	payee = mapPayee(cfg.YNAB.PayeeMap, payee)
+	if utf8.RuneCountInString(payee) > maxPayeeSize {
+		slog.Info("Payee is too long, truncated",
+			"account", t.Account.Name, "date", date, "characters", maxPayeeSize)
+		payee = truncate(payee, maxPayeeSize)
+	}
+
+	// If the account is configured to swap inflow to outflow swap it by using
+	// the Negate method.
+	swap := cfg.SwapFlow(t.Account.IBAN)
+
+	// Split the transaction before swapping the amount since the parts are
+	// read from the memo as reported by the bank
+	subtransactions := split(cfg.YNAB.SplitRules, t, swap)
+	if swap {
+		t.Amount = t.Amount.Negate()
+	}
+
+	// A split transaction is categorized by its subtransactions
+	categoryID := ""
+	if len(subtransactions) == 0 {
+		categoryID = category(cfg.CategoryMap(t.Account.IBAN), payee, t.Memo)
+	}
+
+	// Flag by rules, then by bank transaction code and fall back to flag by
+	// institution
+	flagColor := ruleFlag(cfg.YNAB.FlagRules, payee, t.Memo)
+	if flagColor == "" {
+		flagColor = codeFlag(t.Code, cfg.YNAB.CodeFlags)
+	}
+	if flagColor == "" {
+		flagColor = cfg.YNAB.InstitutionFlags[t.Account.Institution]
+	}
+
+	// Transactions are only reconciled once the balance is confirmed and
+	// pending transactions are never cleared since the bank hasn't booked them
+	cleared := cfg.Cleared(t.Account.IBAN)
+	if cfg.YNAB.ReconcileMatched {
+		cleared = "cleared"
+	}
+	if t.Pending {
+		cleared = "uncleared"
 	}
 
 	return Ytransaction{
@@ -108,30 +330,185 @@ func ynabberToYNAB(cfg ynabber.Config, t ynabber.Transaction) (Ytransaction, err
 		Amount:    t.Amount.String(),
 		PayeeName: payee,
 		Memo:      memo,
-		Cleared:   cfg.YNAB.Cleared,
-		Approved:  false,
+		Cleared:   cleared,
+		Approved:  cfg.YNAB.Approved,
+		FlagColor: flagColor,
+
+		CategoryID: categoryID,
+
+		Subtransactions: subtransactions,
 	}, nil
 }
 
+// Accepts reports whether t is sent to YNAB. Transactions outside the date
+// range, zero amounts when skipped and transactions of unmapped accounts
+// aren't.
+func (w Writer) Accepts(t ynabber.Transaction) bool {
+	if !w.validTransaction(t.Date) || (w.Config.YNAB.SkipZero && t.Amount == 0) {
+		return false
+	}
+	_, err := accountParser(t.Account.IBAN, w.Config.YNAB.AccountMap)
+	return err == nil
+}
+
 // validTransaction checks if date is within the limits of YNAB and w.Config.
 func (w Writer) validTransaction(date time.Time) bool {
-	fiveYearsAgo := time.Now().AddDate(-5, 0, 0)
-	return !date.Before(fiveYearsAgo) &&
+	return !w.tooOld(date) &&
 		!date.Before(time.Time(w.Config.YNAB.FromDate)) &&
 		!date.After(time.Now())
 }
 
-func (w Writer) Bulk(t []ynabber.Transaction) error {
-	// skipped and failed counters
+// tooOld checks if date is older than the five years YNAB accepts, unless
+// backfilling
+func (w Writer) tooOld(date time.Time) bool {
+	fiveYearsAgo := time.Now().AddDate(-5, 0, 0)
+	return !w.Config.YNAB.Backfill && date.Before(fiveYearsAgo)
+}
+
+// do sends payload to url in a single request and returns the response with
+// the body read
+func (w Writer) do(ctx context.Context, method, url string, payload []byte) (*http.Response, []byte, error) {
+	client := &http.Client{Timeout: w.Config.YNAB.Timeout}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", w.Config.YNAB.Token))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		b, _ := httputil.DumpResponse(res, true)
+		slog.Debug("Response from YNAB", "response", string(b))
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, b, nil
+}
+
+// postResponse is the part of the response to creating transactions that
+// ynabber uses
+type postResponse struct {
+	Data struct {
+		// DuplicateImportIDs is the import IDs YNAB ignored because a
+		// transaction with the same import ID already exists
+		DuplicateImportIDs []string `json:"duplicate_import_ids"`
+	} `json:"data"`
+}
+
+// post sends t to YNAB in a single request and returns the import IDs YNAB
+// ignored as duplicates
+func (w Writer) post(ctx context.Context, t []Ytransaction) ([]string, error) {
+	res, body, err := w.request(ctx, http.MethodPost, "transactions", Ytransactions{Transactions: t})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("failed to send request: %s", res.Status)
+	}
+
+	var response postResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		slog.Warn("Failed to parse response from YNAB", "error", err)
+		return nil, nil
+	}
+	return response.Data.DuplicateImportIDs, nil
+}
+
+// sendOrder returns a copy of t in the order configured by SendOrder
+func sendOrder(cfg ynabber.Config, t []ynabber.Transaction) ([]ynabber.Transaction, error) {
+	sorted := make([]ynabber.Transaction, len(t))
+	copy(sorted, t)
+	ynabber.Sort(sorted)
+
+	switch cfg.YNAB.SendOrder {
+	case "", "oldest":
+	case "newest":
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	default:
+		return nil, fmt.Errorf("unknown send order: %s", cfg.YNAB.SendOrder)
+	}
+	return sorted, nil
+}
+
+// send posts t to YNAB in chunks within BatchSize and MaxPayloadSize and
+// returns the import IDs YNAB ignored as duplicates. A failing
+// chunk doesn't stop the others, instead the transactions not sent are
+// returned with the errors joined.
+func (w Writer) send(ctx context.Context, t []Ytransaction) ([]Ytransaction, []string, error) {
+	chunks, err := batch(t, w.Config.YNAB.MaxPayloadSize, w.Config.YNAB.BatchSize)
+	if err != nil {
+		return t, nil, err
+	}
+
+	var unsent []Ytransaction
+	var errs []error
+	var duplicates []string
+	for i, chunk := range chunks {
+		d, err := w.post(ctx, chunk)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to send request %d of %d", i+1, len(chunks)),
+				"count", len(chunk), "error", err)
+			unsent = append(unsent, chunk...)
+			errs = append(errs, fmt.Errorf("request %d of %d: %w", i+1, len(chunks), err))
+			continue
+		}
+		duplicates = append(duplicates, d...)
+	}
+	return unsent, duplicates, errors.Join(errs...)
+}
+
+func (w Writer) Bulk(ctx context.Context, t []ynabber.Transaction) error {
+	// skipped, failed and too old counters
 	skipped := 0
 	failed := 0
+	old := 0
+
+	t, err := sendOrder(*w.Config, t)
+	if err != nil {
+		return err
+	}
+
+	// Transactions imported before are recognized by their secondary import
+	// ID if their amount is corrected, updates need it since the import ID
+	// changes with the amount
+	var index map[string]imported
+	if w.Config.YNAB.ImportIDSecondary || w.Config.YNAB.Update {
+		index, err = w.loadImportIDs(ctx)
+		if err != nil {
+			return fmt.Errorf("loading import IDs: %w", err)
+		}
+	}
+	fixes := map[string]imported{}
+	var keys []string
 
 	// Build array of transactions to send to YNAB
 	y := new(Ytransactions)
+	var report []reportEntry
+	var sent []ynabber.Transaction
 	for _, v := range t {
 
 		// Skip transactions that are not within the valid date range.
 		if !w.validTransaction(v.Date) {
+			if w.tooOld(v.Date) {
+				old += 1
+			}
+			skipped += 1
+			continue
+		}
+
+		if w.Config.YNAB.SkipZero && v.Amount == 0 {
 			skipped += 1
 			continue
 		}
@@ -140,58 +517,92 @@ func (w Writer) Bulk(t []ynabber.Transaction) error {
 		if err != nil {
 			// If we fail to parse a single transaction we log it but move on so
 			// we don't halt the entire program.
-			log.Printf("Failed to parse transaction: %s: %s", v, err)
+			slog.Error("Failed to parse transaction",
+				"account", v.Account.Name, "date", v.Date.Format("2006-01-02"), "error", err)
 			failed += 1
 			continue
 		}
+
+		if index != nil {
+			key := secondaryID(*w.Config, v)
+			if prev, ok := index[key]; ok && prev.ImportID != transaction.ImportID {
+				if u, changed := correction(prev, transaction); changed {
+					fixes[key] = imported{Yupdate: u, Date: transaction.Date}
+				}
+				continue
+			}
+			keys = append(keys, key)
+		}
 		y.Transactions = append(y.Transactions, transaction)
+		report = append(report, newReportEntry(v, transaction))
+		sent = append(sent, v)
 	}
 
-	if len(t) == 0 || len(y.Transactions) == 0 {
-		log.Println("No transactions to write")
-		return nil
+	if old > 0 {
+		slog.Warn("Skipped transactions older than five years, YNAB rejects them unless the budget accepts them, set YNAB_BACKFILL to send them anyway",
+			"count", old)
 	}
 
-	if w.Config.Debug {
-		log.Printf("Request to YNAB: %+v", y)
+	// YNAB drops all but the first transaction with the same import ID
+	for id, c := range collisions(sent, y.Transactions) {
+		slog.Warn("Import ID is shared by different transactions, only one will be imported",
+			"import_id", id, "count", len(c), "transactions", fmt.Sprintf("%+v", c))
 	}
 
-	url := fmt.Sprintf("https://api.youneedabudget.com/v1/budgets/%s/transactions", w.Config.YNAB.BudgetID)
+	if index != nil {
+		w.correct(ctx, index, fixes)
+	}
 
-	payload, err := json.Marshal(y)
-	if err != nil {
-		return err
+	if len(t) == 0 || len(y.Transactions) == 0 {
+		slog.Info("No transactions to write")
+		w.markSynced(ctx)
+		return nil
 	}
 
-	client := &http.Client{}
+	slog.Debug("Request to YNAB", "request", y)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return err
+	if w.Config.YNAB.DryRun {
+		for _, entry := range report {
+			slog.Info(fmt.Sprintf("Dry run: would send %s", entry))
+		}
+		slog.Info("Dry run: would have sent transactions to YNAB",
+			"sent", len(y.Transactions), "skipped", skipped, "failed", failed)
+		if w.Config.YNAB.DryRunReport != "" {
+			file, err := w.writeReport(report)
+			if err != nil {
+				return fmt.Errorf("writing dry-run report: %w", err)
+			}
+			slog.Info("Dry run report written", "file", file)
+		}
+		return nil
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", w.Config.YNAB.Token))
 
-	res, err := client.Do(req)
+	unsent, duplicates, err := w.send(ctx, y.Transactions)
+	if index != nil {
+		w.recordImportIDs(ctx, index, y.Transactions, keys, unsent)
+	}
 	if err != nil {
+		slog.Error(fmt.Sprintf("Sent %d of %d transactions to YNAB", len(y.Transactions)-len(unsent), len(y.Transactions)),
+			"skipped", skipped, "failed", failed+len(unsent), "duplicates", len(duplicates))
+		if w.Config.YNAB.Outbox {
+			if err := w.addToOutbox(ctx, unsent); err != nil {
+				slog.Error("Failed to add transactions to outbox", "error", err)
+			} else {
+				slog.Info("Added unsent transactions to outbox", "count", len(unsent))
+			}
+		}
 		return err
 	}
-	defer res.Body.Close()
 
-	if w.Config.Debug {
-		b, _ := httputil.DumpResponse(res, true)
-		log.Printf("Response from YNAB: %s", b)
-	}
+	slog.Info("Sent transactions to YNAB",
+		"sent", len(y.Transactions), "skipped", skipped, "failed", failed, "duplicates", len(duplicates))
 
-	if res.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to send request: %s", res.Status)
-	} else {
-		log.Printf(
-			"Successfully sent %v transaction(s) to YNAB. %d got skipped and %d failed.",
-			len(y.Transactions),
-			skipped,
-			failed,
-		)
+	if w.Config.YNAB.Update && len(duplicates) > 0 {
+		w.update(ctx, y.Transactions, duplicates)
+	}
+	if w.Config.YNAB.ReconcileMatched {
+		w.reconcile(ctx, reconciliations(*w.Config, sent, y.Transactions))
 	}
+	w.markSynced(ctx)
 	return nil
 }
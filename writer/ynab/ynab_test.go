@@ -1,9 +1,15 @@
 package ynab
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/martinohansen/ynabber"
@@ -32,11 +38,27 @@ func TestMakeID(t *testing.T) {
 		{
 			name: "v2",
 			args: args{
-				ynabber.Config{},
+				ynabber.Config{YNAB: ynabber.YNAB{ImportIDPrefix: "YBBR"}},
 				ynabber.Transaction{Date: time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)},
 			},
 			want: "YBBR:5ca3430298b7fb93d2f4fe1e302",
 		},
+		{
+			name: "default prefix",
+			args: args{
+				ynabber.Config{},
+				ynabber.Transaction{Date: time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)},
+			},
+			want: "YBBRTZ:5ca3430298b7fb93d2f4fe1e3",
+		},
+		{
+			name: "custom prefix",
+			args: args{
+				ynabber.Config{YNAB: ynabber.YNAB{ImportIDPrefix: "HOME"}},
+				ynabber.Transaction{Date: time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC)},
+			},
+			want: "HOME:5ca3430298b7fb93d2f4fe1e302",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,6 +75,24 @@ func TestMakeID(t *testing.T) {
 	}
 }
 
+func TestMakeIDReadDate(t *testing.T) {
+	tx := ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "DK1"},
+		ID:      "abc",
+		Date:    time.Date(2023, 2, 26, 0, 0, 0, 0, time.UTC),
+		Amount:  -1000,
+	}
+	clamped := tx
+	clamped.Date = time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC)
+	clamped.Original = &ynabber.Original{Date: tx.Date}
+
+	// The import ID doesn't change when the date is clamped
+	cfg := ynabber.Config{}
+	if got, want := makeID(cfg, clamped), makeID(cfg, tx); got != want {
+		t.Errorf("got %s for the clamped transaction, want %s", got, want)
+	}
+}
+
 func TestAccountParser(t *testing.T) {
 	type args struct {
 		account    string
@@ -74,6 +114,11 @@ func TestAccountParser(t *testing.T) {
 			want:    "",
 			wantErr: true,
 		},
+		{name: "emptyMap",
+			args:    args{account: "N1", accountMap: map[string]string{}},
+			want:    "",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -90,6 +135,11 @@ func TestAccountParser(t *testing.T) {
 }
 
 func TestYnabberToYNAB(t *testing.T) {
+	var swap ynabber.AccountMatcher
+	if err := swap.Decode("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
 	type args struct {
 		cfg ynabber.Config
 		t   ynabber.Transaction
@@ -105,7 +155,8 @@ func TestYnabberToYNAB(t *testing.T) {
 			args: args{
 				cfg: ynabber.Config{
 					YNAB: ynabber.YNAB{
-						AccountMap: map[string]string{"foobar": "abc"},
+						AccountMap:     map[string]string{"foobar": "abc"},
+						ImportIDPrefix: "YBBR",
 					},
 				},
 				t: ynabber.Transaction{
@@ -127,8 +178,9 @@ func TestYnabberToYNAB(t *testing.T) {
 			args: args{
 				cfg: ynabber.Config{
 					YNAB: ynabber.YNAB{
-						SwapFlow:   []string{"foobar"},
-						AccountMap: map[string]string{"foobar": "abc"},
+						SwapFlow:       swap,
+						AccountMap:     map[string]string{"foobar": "abc"},
+						ImportIDPrefix: "YBBR",
 					},
 				},
 				t: ynabber.Transaction{
@@ -160,6 +212,42 @@ func TestYnabberToYNAB(t *testing.T) {
 	}
 }
 
+func TestYnabberToYNABApproved(t *testing.T) {
+	for _, approved := range []bool{false, true} {
+		cfg := ynabber.Config{YNAB: ynabber.YNAB{
+			AccountMap: map[string]string{"foobar": "abc"},
+			Approved:   approved,
+		}}
+		got, err := ynabberToYNAB(cfg, ynabber.Transaction{Account: ynabber.Account{IBAN: "foobar"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Approved != approved {
+			t.Errorf("got approved %t, want %t", got.Approved, approved)
+		}
+	}
+}
+
+func TestYnabberToYNABPending(t *testing.T) {
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		AccountMap: map[string]string{"foobar": "abc"},
+		Cleared:    "cleared",
+	}}
+	for _, pending := range []bool{false, true} {
+		got, err := ynabberToYNAB(cfg, ynabber.Transaction{Account: ynabber.Account{IBAN: "foobar"}, Pending: pending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "cleared"
+		if pending {
+			want = "uncleared"
+		}
+		if got.Cleared != want {
+			t.Errorf("pending %t: got cleared %s, want %s", pending, got.Cleared, want)
+		}
+	}
+}
+
 func TestValidTransaction(t *testing.T) {
 	fromDate := time.Now().AddDate(-1, 0, 0)
 	mockFromDate := ynabber.Date(fromDate)
@@ -204,4 +292,538 @@ func TestValidTransaction(t *testing.T) {
 			}
 		})
 	}
+
+	backfill := Writer{Config: &ynabber.Config{YNAB: ynabber.YNAB{Backfill: true}}}
+	if !backfill.validTransaction(time.Now().AddDate(-10, 0, 0)) {
+		t.Error("want transactions older than five years to be valid when backfilling")
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	writer := Writer{Config: &ynabber.Config{YNAB: ynabber.YNAB{
+		AccountMap: map[string]string{"DK1": "abc"},
+		SkipZero:   true,
+	}}}
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	tests := []struct {
+		name string
+		t    ynabber.Transaction
+		want bool
+	}{
+		{name: "accepted", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK1"}, Date: yesterday, Amount: -1000}, want: true},
+		{name: "future", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK1"}, Date: time.Now().AddDate(0, 0, 1), Amount: -1000}},
+		{name: "zero", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK1"}, Date: yesterday}},
+		{name: "unmapped", t: ynabber.Transaction{Account: ynabber.Account{IBAN: "DK2"}, Date: yesterday, Amount: -1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writer.Accepts(tt.t); got != tt.want {
+				t.Errorf("Accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeFlag(t *testing.T) {
+	codeFlags := map[string]string{
+		"PMNT-ICDT":      "blue",
+		"PMNT-CCRD":      "green",
+		"PMNT-CCRD-POSD": "purple",
+	}
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "PMNT-ICDT-ESCT", want: "blue"},
+		{code: "PMNT-CCRD-CWDL", want: "green"},
+		{code: "PMNT-CCRD-POSD", want: "purple"},
+		{code: "PMNT-RCDT-ESCT", want: ""},
+		{code: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := codeFlag(tt.code, codeFlags); got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYnabberToYNABFlagRules(t *testing.T) {
+	var rules ynabber.FlagRules
+	if err := rules.Decode(`[{"match": "REFUND", "color": "orange"}]`); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		AccountMap: map[string]string{"foobar": "abc"},
+		CodeFlags:  ynabber.FlagColorMap{"PMNT": "blue"},
+		FlagRules:  rules,
+	}}
+
+	tests := []struct {
+		name string
+		t    ynabber.Transaction
+		want string
+	}{
+		{name: "payee", t: ynabber.Transaction{Payee: "REFUND Shop", Code: "PMNT"}, want: "orange"},
+		{name: "memo", t: ynabber.Transaction{Memo: "Card REFUND"}, want: "orange"},
+		{name: "code", t: ynabber.Transaction{Payee: "Shop", Code: "PMNT"}, want: "blue"},
+		{name: "none", t: ynabber.Transaction{Payee: "Shop"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.t.Account.IBAN = "foobar"
+			got, err := ynabberToYNAB(cfg, tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.FlagColor != tt.want {
+				t.Errorf("got = %s, want %s", got.FlagColor, tt.want)
+			}
+		})
+	}
+}
+
+func TestYnabberToYNABPayeeMap(t *testing.T) {
+	var rules ynabber.PayeeRules
+	if err := rules.Decode(`[{"match": "^AMZN", "payee": "Amazon"}, {"match": "(?i)amazon", "payee": "Amazon Prime"}]`); err != nil {
+		t.Fatal(err)
+	}
+	var categories ynabber.CategoryRules
+	if err := categories.Decode(`[{"payee": "Amazon", "category_id": "shopping"}]`); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{
+		AccountMap:  map[string]string{"foobar": "abc"},
+		PayeeMap:    rules,
+		CategoryMap: categories,
+	}}
+
+	tests := []struct {
+		payee    ynabber.Payee
+		want     string
+		category string
+	}{
+		{payee: "AMZN Mktp DE*1A2B3", want: "Amazon", category: "shopping"},
+		{payee: "AMZN  Mktp  DE*9Z8Y7", want: "Amazon", category: "shopping"},
+		{payee: "Amazon Prime*123", want: "Amazon Prime"},
+		{payee: "Netto", want: "Netto"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.payee), func(t *testing.T) {
+			got, err := ynabberToYNAB(cfg, ynabber.Transaction{Account: ynabber.Account{IBAN: "foobar"}, Payee: tt.payee})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PayeeName != tt.want {
+				t.Errorf("got payee %s, want %s", got.PayeeName, tt.want)
+			}
+			if got.CategoryID != tt.category {
+				t.Errorf("got category %s, want %s", got.CategoryID, tt.category)
+			}
+		})
+	}
+}
+
+func TestCleanPayee(t *testing.T) {
+	tests := []struct {
+		name  string
+		payee string
+		nfkc  bool
+		want  string
+	}{
+		{name: "zero-width space", payee: "NETTO\u200b FOTEX", want: "NETTO FOTEX"},
+		{name: "zero-width joiners", payee: "\ufeffREMA\u200d 1000\u2060", want: "REMA 1000"},
+		{name: "control characters", payee: "7-ELEVEN\x00\x1b 123\r\n", want: "7-ELEVEN 123"},
+		{name: "no-break space", payee: "SQ\u00a0*COFFEE\u2007SHOP", want: "SQ *COFFEE SHOP"},
+		{name: "full-width", payee: "ＡＭＺＮ Mktp", want: "ＡＭＺＮ Mktp"},
+		{name: "full-width nfkc", payee: "ＡＭＺＮ Mktp", nfkc: true, want: "AMZN Mktp"},
+		{name: "ligature nfkc", payee: "Ca\ufb00e Nero", nfkc: true, want: "Caffe Nero"},
+		{name: "accents nfkc", payee: "Cafe\u0301 Ole\u0301", nfkc: true, want: "Café Olé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanPayee(tt.payee, tt.nfkc); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "short", s: "Føtex", n: 10, want: "Føtex"},
+		{name: "word boundary", s: "Bäckerei Müller Straße", n: 18, want: "Bäckerei Müller"},
+		{name: "cut at space", s: "Bäckerei Müller Straße", n: 15, want: "Bäckerei Müller"},
+		{name: "no space", s: "Ærøskøbingæøå", n: 5, want: "Ærøsk"},
+		{name: "space too early", s: "A Ærøskøbingæøå", n: 10, want: "A Ærøskøbi"},
+		{name: "emoji", s: "🍕🍕🍕 🍺🍺🍺", n: 5, want: "🍕🍕🍕"},
+		{name: "emoji no space", s: "🍕🍕🍕🍺🍺🍺", n: 4, want: "🍕🍕🍕🍺"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("got = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8: %q", got)
+			}
+		})
+	}
+}
+
+func TestYnabberToYNABTruncate(t *testing.T) {
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{AccountMap: map[string]string{"foobar": "abc"}}}
+	got, err := ynabberToYNAB(cfg, ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "foobar"},
+		Payee:   ynabber.Payee(strings.Repeat("Ø", maxPayeeSize+1)),
+		Memo:    strings.Repeat("😀 ", maxMemoSize),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(got.PayeeName); !utf8.ValidString(got.PayeeName) || n != maxPayeeSize {
+		t.Errorf("got payee of %d characters, want %d valid characters", n, maxPayeeSize)
+	}
+	if n := utf8.RuneCountInString(got.Memo); !utf8.ValidString(got.Memo) || n > maxMemoSize {
+		t.Errorf("got memo of %d characters, want at most %d valid characters", n, maxMemoSize)
+	}
+}
+
+func TestSwapFlow(t *testing.T) {
+	cfg := ynabber.Config{}
+	if err := cfg.YNAB.SwapFlow.Decode("DK9520000123456789, SE45*,/^FI.*01$/"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{iban: "DK9520000123456789", want: true},
+		{iban: "DK9520000123456780", want: false},
+		{iban: "SE4550000000058398257466", want: true},
+		{iban: "NO4550000000058398257466", want: false},
+		{iban: "FI2112345600000701", want: true},
+		{iban: "FI2112345600000785", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iban, func(t *testing.T) {
+			if got := cfg.SwapFlow(tt.iban); got != tt.want {
+				t.Errorf("got = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategory(t *testing.T) {
+	var rules ynabber.CategoryRules
+	if err := rules.Decode(`[
+		{"payee": "Netflix", "category_id": "streaming"},
+		{"memo": "(?i)rent", "category_id": "housing"}
+	]`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		payee string
+		memo  string
+		want  string
+	}{
+		{payee: "Netflix", memo: "Rent", want: "streaming"},
+		{payee: "Landlord", memo: "RENT MARCH", want: "housing"},
+		{payee: "netflix", memo: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.payee, func(t *testing.T) {
+			if got := category(rules, tt.payee, tt.memo); got != tt.want {
+				t.Errorf("got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeIDV3(t *testing.T) {
+	cutover := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{ImportIDV3: ynabber.Date(cutover)}}
+
+	pending := ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "foobar"},
+		ID:      "pending-1",
+		Date:    cutover,
+		Payee:   "Netto",
+		Amount:  -1000,
+	}
+	booked := pending
+	booked.ID = "booked-1"
+	if makeID(cfg, pending) != makeID(cfg, booked) {
+		t.Error("want same ID when only the transaction ID changes")
+	}
+
+	other := booked
+	other.Payee = "Rema 1000"
+	if makeID(cfg, booked) == makeID(cfg, other) {
+		t.Error("want different ID for different payees")
+	}
+
+	// Before the cutover the ID is still made from the transaction ID
+	pending.Date = cutover.AddDate(0, 0, -1)
+	if got, want := makeID(cfg, pending), makeID(ynabber.Config{}, pending); got != want {
+		t.Errorf("got %s before cutover, want %s", got, want)
+	}
+}
+
+func TestYnabberToYNABInstitution(t *testing.T) {
+	cfg := ynabber.Config{
+		YNAB: ynabber.YNAB{
+			AccountMap:       map[string]string{"foobar": "abc"},
+			InstitutionFlags: ynabber.FlagColorMap{"Nordea": "blue"},
+			MemoInstitution:  true,
+		},
+	}
+	account := ynabber.Account{IBAN: "foobar", Institution: "Nordea"}
+
+	tests := []struct {
+		name     string
+		memo     string
+		wantMemo string
+	}{
+		{name: "short", memo: "Netto", wantMemo: "Netto (Nordea)"},
+		{name: "empty", memo: "", wantMemo: "(Nordea)"},
+		{
+			// The institution must survive truncation of the memo
+			name:     "long",
+			memo:     strings.Repeat("x", 300),
+			wantMemo: strings.Repeat("x", maxMemoSize-len(" (Nordea)")) + " (Nordea)",
+		},
+		{
+			// The memo tag must survive too, in front of the institution
+			name:     "tagged",
+			memo:     strings.Repeat("x", maxMemoSize-len(" [50/50]")) + " [50/50]",
+			wantMemo: strings.Repeat("x", maxMemoSize-len(" [50/50] (Nordea)")) + " [50/50] (Nordea)",
+		},
+	}
+	cfg.Transform.MemoTags = ynabber.AccountMap{"foobar": "[50/50]"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ynabberToYNAB(cfg, ynabber.Transaction{Account: account, Memo: tt.memo})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Memo != tt.wantMemo {
+				t.Errorf("got memo = %q, want %q", got.Memo, tt.wantMemo)
+			}
+			if got.FlagColor != "blue" {
+				t.Errorf("got flag = %q, want blue", got.FlagColor)
+			}
+		})
+	}
+}
+
+func TestImportIDLength(t *testing.T) {
+	// Generate many distinct transactions and count how many share an import
+	// ID at a given length. Short IDs collide while the longer ones don't.
+	transactions := make([]ynabber.Transaction, 2000)
+	for i := range transactions {
+		transactions[i] = ynabber.Transaction{
+			Account: ynabber.Account{IBAN: "foobar"},
+			ID:      ynabber.ID(fmt.Sprint(i)),
+			Amount:  -10000,
+		}
+	}
+
+	count := func(length int) int {
+		cfg := ynabber.Config{YNAB: ynabber.YNAB{ImportIDLength: length}}
+		y := make([]Ytransaction, len(transactions))
+		for i, v := range transactions {
+			y[i] = Ytransaction{ImportID: makeID(cfg, v)}
+			if len(y[i].ImportID) > maxImportIDLength {
+				t.Fatalf("length %d: import ID %s is too long", length, y[i].ImportID)
+			}
+		}
+		return len(collisions(transactions, y))
+	}
+
+	// 2 hex characters of hash
+	if got := count(9); got == 0 {
+		t.Errorf("length 9: got no collisions")
+	}
+	for _, length := range []int{0, 32, 36, 100} {
+		if got := count(length); got != 0 {
+			t.Errorf("length %d: got %d collisions", length, got)
+		}
+	}
+}
+
+func TestImportIDMaxLength(t *testing.T) {
+	v3 := ynabber.Date(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	transaction := ynabber.Transaction{
+		Account: ynabber.Account{IBAN: "NO8330001234567"},
+		ID:      "H00000000000000000000000000000000000001",
+		Date:    time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+		Payee:   "Very Expensive Things Ltd",
+		Amount:  math.MinInt64,
+	}
+
+	tests := []struct {
+		name string
+		cfg  ynabber.YNAB
+	}{
+		{name: "v2", cfg: ynabber.YNAB{ImportIDLength: 100}},
+		{name: "v3", cfg: ynabber.YNAB{ImportIDLength: 100, ImportIDV3: v3}},
+		{name: "long prefix", cfg: ynabber.YNAB{ImportIDLength: 36, ImportIDPrefix: strings.Repeat("P", 40)}},
+		{name: "non-ASCII prefix", cfg: ynabber.YNAB{ImportIDLength: 35, ImportIDPrefix: strings.Repeat("Æ", 35)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := makeID(ynabber.Config{YNAB: tt.cfg}, transaction)
+			if n := utf8.RuneCountInString(got); n > maxImportIDLength {
+				t.Errorf("got %d characters, want at most %d: %s", n, maxImportIDLength, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("got invalid UTF-8: %q", got)
+			}
+		})
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	a := ynabber.Transaction{Account: ynabber.Account{IBAN: "foo"}, ID: "a"}
+	b := ynabber.Transaction{Account: ynabber.Account{IBAN: "foo"}, ID: "b"}
+
+	// The same transaction read twice is not a collision
+	got := collisions(
+		[]ynabber.Transaction{a, a, b},
+		[]Ytransaction{{ImportID: "1"}, {ImportID: "1"}, {ImportID: "1"}},
+	)
+	want := map[string][]ynabber.Transaction{"1": {a, b}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendOrder(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	today := time.Now().Truncate(24 * time.Hour)
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "2", Date: today.AddDate(0, 0, -2)},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "3", Date: today.AddDate(0, 0, -1)},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: today.AddDate(0, 0, -3)},
+	}
+
+	tests := []struct {
+		order string
+		want  []ynabber.ID
+	}{
+		{order: "oldest", want: []ynabber.ID{"1", "2", "3"}},
+		{order: "newest", want: []ynabber.ID{"3", "2", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			fake.created = nil
+			cfg := ynabber.Config{YNAB: ynabber.YNAB{
+				BudgetID:   "budget",
+				AccountMap: map[string]string{"foobar": "abc"},
+				SendOrder:  tt.order,
+			}}
+			if err := (Writer{Config: &cfg}).Bulk(context.Background(), transactions); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := map[string]ynabber.ID{}
+			for _, v := range transactions {
+				ids[makeID(cfg, v)] = v.ID
+			}
+			var got []ynabber.ID
+			for _, v := range fake.created {
+				got = append(got, ids[v.ImportID])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got order %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cfg := ynabber.Config{YNAB: ynabber.YNAB{SendOrder: "random"}}
+	if err := (Writer{Config: &cfg}).Bulk(context.Background(), transactions); err == nil {
+		t.Error("want error for unknown send order")
+	}
+}
+
+func TestSkipZero(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	today := time.Now().Truncate(24 * time.Hour)
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: today, Amount: 0},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "2", Date: today, Amount: -1000},
+	}
+
+	for _, skip := range []bool{false, true} {
+		fake.created = nil
+		cfg := ynabber.Config{YNAB: ynabber.YNAB{
+			BudgetID:   "budget",
+			AccountMap: map[string]string{"foobar": "abc"},
+			SkipZero:   skip,
+		}}
+		if err := (Writer{Config: &cfg}).Bulk(context.Background(), transactions); err != nil {
+			t.Fatal(err)
+		}
+		want := 2
+		if skip {
+			want = 1
+		}
+		if len(fake.created) != want {
+			t.Errorf("SkipZero=%v: got %d transactions, want %d", skip, len(fake.created), want)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	fake := &fakeYNAB{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	defer func(url string) { baseURL = url }(baseURL)
+	baseURL = server.URL
+
+	transactions := []ynabber.Transaction{
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "1", Date: time.Now().AddDate(-6, 0, 0)},
+		{Account: ynabber.Account{IBAN: "foobar"}, ID: "2", Date: time.Now().AddDate(0, 0, -1)},
+	}
+
+	for _, backfill := range []bool{false, true} {
+		fake.created = nil
+		cfg := ynabber.Config{YNAB: ynabber.YNAB{
+			BudgetID:   "budget",
+			AccountMap: map[string]string{"foobar": "abc"},
+			Backfill:   backfill,
+		}}
+		if err := (Writer{Config: &cfg}).Bulk(context.Background(), transactions); err != nil {
+			t.Fatal(err)
+		}
+		want := 1
+		if backfill {
+			want = 2
+		}
+		if len(fake.created) != want {
+			t.Errorf("Backfill=%v: got %d transactions, want %d", backfill, len(fake.created), want)
+		}
+	}
 }
@@ -1,43 +1,114 @@
 package ynabber
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Ynabber struct {
-	Readers []Reader
-	Writers []Writer
+	Readers      []Reader
+	Transformers []Transformer
+	Writers      []Writer
+	Notifiers    Notifiers
+
+	// Pipelines replaces the flow from all readers through all transformers
+	// to all writers above when defined
+	Pipelines []Pipeline
+}
+
+// Pipeline routes the transactions from its readers through its transformers
+// to its writers
+type Pipeline struct {
+	Readers      []Reader
+	Transformers []Transformer
+	Writers      []Writer
 }
 
+// Reader reads transactions. The context is cancelled when the run must stop,
+// like for Writer.
 type Reader interface {
-	Bulk() ([]Transaction, error)
+	Bulk(context.Context) ([]Transaction, error)
+}
+
+// Committer is implemented by readers keeping track of what they have read,
+// like the files already imported. Commit is called once all writers wrote
+// what the reader read without error.
+type Committer interface {
+	Commit(context.Context) error
+}
+
+// Transformer modifies transactions after they are read and before they are
+// written
+type Transformer interface {
+	Transform([]Transaction) []Transaction
 }
 
+// ContextTransformer is implemented by transformers doing I/O, like looking
+// up transactions in a database, so the run can pass its context
+type ContextTransformer interface {
+	TransformContext(context.Context, []Transaction) []Transaction
+}
+
+// Writer writes transactions. The context is cancelled when the run must
+// stop, for example when Lambda is about to time out.
 type Writer interface {
-	Bulk([]Transaction) error
+	Bulk(context.Context, []Transaction) error
+}
+
+// Flusher is implemented by writers that keep transactions they failed to
+// write. Flush retries them and is called before new transactions are read.
+type Flusher interface {
+	Flush(context.Context) error
+}
+
+// Accepter is implemented by writers that only write some of the
+// transactions given to them, for example the YNAB writer skipping future
+// transactions. Accepts reports whether t is written.
+type Accepter interface {
+	Accepts(t Transaction) bool
+}
+
+// Notifier sends a message to the user, for example when an action is
+// required
+type Notifier interface {
+	Notify(message string) error
+}
+
+// Notifiers sends messages to multiple notifiers
+type Notifiers []Notifier
+
+// Notify sends message to all notifiers. A failing notifier doesn't stop the
+// message from being sent to the others, instead the errors are joined.
+func (n Notifiers) Notify(message string) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.Notify(message); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", notifier, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 type Account struct {
 	ID   ID
 	Name string
 	IBAN string
+	// Institution is the name of the bank holding the account
+	Institution string
+	// Balance is the booked balance reported by the bank, nil if unknown
+	Balance *Milliunits
 }
 
 type ID string
 
 type Payee string
 
-// Strip removes the elements from s from the payee
-func (p Payee) Strip(s []string) Payee {
-	x := string(p)
-	for _, strip := range s {
-		x = strings.ReplaceAll(x, strip, "")
-	}
-	return Payee(strings.TrimSpace(x))
-}
-
 type Milliunits int64
 
 // Negate changes the sign of m to the opposite
@@ -49,17 +120,189 @@ type Transaction struct {
 	Account Account `json:"account"`
 	ID      ID      `json:"id"`
 	// Date is the date of the transaction in UTC time
-	Date   time.Time  `json:"date"`
+	Date time.Time `json:"date"`
+	// Time is the time of the transaction in UTC time if the reader knows it,
+	// otherwise it's the same as Date. It's used to order transactions on
+	// the same date.
+	Time   time.Time  `json:"time"`
 	Payee  Payee      `json:"payee"`
 	Memo   string     `json:"memo"`
 	Amount Milliunits `json:"amount"`
+	// Code is the bank transaction code classifying the transaction, for
+	// example PMNT-RCDT-ESCT
+	Code string `json:"code"`
+	// Pending is true if the bank hasn't booked the transaction yet, the
+	// amount and description may still change
+	Pending bool `json:"pending"`
+	// Currency is the ISO 4217 code of the currency of Amount if the reader
+	// knows it, for example EUR
+	Currency string `json:"currency"`
+	// Original is the payee, memo and date as read, before the transformers
+	// changed them. It's nil if the transaction wasn't transformed.
+	Original *Original `json:"-"`
+}
+
+// Original is the payee, memo and date of a transaction as read
+type Original struct {
+	Payee Payee
+	Memo  string
+	Date  time.Time
+}
+
+// ReadDate returns the date of t as read. Import IDs are made from it so a
+// transaction whose date is changed, like clamped to today, keeps its ID.
+func (t Transaction) ReadDate() time.Time {
+	if t.Original != nil && !t.Original.Date.IsZero() {
+		return t.Original.Date
+	}
+	return t.Date
+}
+
+// Sort orders t oldest first by date and then time, transactions with the
+// same date and time are ordered by account and ID to keep the order
+// deterministic
+func Sort(t []Transaction) {
+	sort.SliceStable(t, func(i, j int) bool {
+		if !t[i].Date.Equal(t[j].Date) {
+			return t[i].Date.Before(t[j].Date)
+		}
+		if !t[i].Time.Equal(t[j].Time) {
+			return t[i].Time.Before(t[j].Time)
+		}
+		if t[i].Account.IBAN != t[j].Account.IBAN {
+			return t[i].Account.IBAN < t[j].Account.IBAN
+		}
+		return t[i].ID < t[j].ID
+	})
 }
 
+// Dedup returns t without the transactions already seen on the same account
+// with the same ID, keeping the first, and the number of transactions removed.
+// Transactions without an ID are all kept since they can't be told apart.
+func Dedup(t []Transaction) ([]Transaction, int) {
+	type key struct {
+		IBAN string
+		ID   ID
+	}
+	seen := make(map[key]bool, len(t))
+	unique := make([]Transaction, 0, len(t))
+	for _, v := range t {
+		k := key{IBAN: v.Account.IBAN, ID: v.ID}
+		if v.ID != "" && seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, v)
+	}
+	return unique, len(t) - len(unique)
+}
+
+// String returns m as an integer, since m is an integer zero never carries a
+// sign
 func (m Milliunits) String() string {
 	return strconv.FormatInt(int64(m), 10)
 }
 
-// MilliunitsFromAmount returns a transaction amount in YNABs milliunits format
+// MajorString returns m in major units with two decimals, for example
+// "-19.99". Milliunits beyond the cents are rounded half away from zero.
+func (m Milliunits) MajorString() string {
+	sign, abs := "", int64(m)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	cents := (abs + 5) / 10
+	if cents == 0 {
+		sign = ""
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON implements `json.Marshaler` for Milliunits to write the amount
+// as a number in major units, for example -19.99. Amounts with milliunits
+// beyond the cents keep all three decimals so nothing is lost.
+func (m Milliunits) MarshalJSON() ([]byte, error) {
+	if m%10 == 0 {
+		return []byte(m.MajorString()), nil
+	}
+	sign, abs := "", int64(m)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return []byte(fmt.Sprintf("%s%d.%03d", sign, abs/1000, abs%1000)), nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler` for Milliunits to read the
+// amounts written by MarshalJSON. Amounts used to be written as integer
+// milliunits, and since MarshalJSON always writes a decimal point an amount
+// without one is read as milliunits so older files keep their amounts.
+func (m *Milliunits) UnmarshalJSON(data []byte) error {
+	if !strings.Contains(string(data), ".") {
+		x, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount: %q: %w", data, err)
+		}
+		*m = Milliunits(x)
+		return nil
+	}
+	x, err := MilliunitsFromString(string(data))
+	if err != nil {
+		return err
+	}
+	*m = x
+	return nil
+}
+
+// MilliunitsFromString parses a decimal amount in major units like "-19.99"
+// without going through a float, so the result is exact. Digits beyond the
+// third decimal are rounded half away from zero.
+func MilliunitsFromString(s string) (Milliunits, error) {
+	s = strings.TrimSpace(s)
+	sign := int64(1)
+	number := s
+	if strings.HasPrefix(number, "-") {
+		sign = -1
+		number = number[1:]
+	} else {
+		number = strings.TrimPrefix(number, "+")
+	}
+
+	whole, fraction, _ := strings.Cut(number, ".")
+	if whole == "" && fraction == "" || !digits(whole) || !digits(fraction) {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	// Pad or cut the fraction to exactly three digits, the first digit cut
+	// off decides the rounding
+	round := int64(0)
+	if len(fraction) > 3 {
+		if fraction[3] >= '5' {
+			round = 1
+		}
+		fraction = fraction[:3]
+	}
+	fraction += strings.Repeat("0", 3-len(fraction))
+
+	m, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %q: %w", s, err)
+	}
+	return Milliunits(sign * (m + round)), nil
+}
+
+// digits reports whether s only contains the digits 0-9
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MilliunitsFromAmount returns a transaction amount in YNABs milliunits format.
+// The amount is rounded to the nearest milliunit so float errors like
+// 524.18*1000=524179.99... don't lose a milliunit, and amounts closer to zero
+// than half a milliunit become zero without a sign.
 func MilliunitsFromAmount(amount float64) Milliunits {
-	return Milliunits(amount * 1000)
+	return Milliunits(math.Round(amount * 1000))
 }
@@ -1,7 +1,13 @@
 package ynabber
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMilliunitsFromAmount(t *testing.T) {
@@ -40,34 +46,218 @@ func TestMilliunitsFromAmount(t *testing.T) {
 	if want != got {
 		t.Fatalf("amount with no separator: %s != %s", want, got)
 	}
+
+	want = Milliunits(-524180)
+	got = MilliunitsFromAmount(-524.18)
+	if want != got {
+		t.Fatalf("amount with float error: %s != %s", want, got)
+	}
 }
 
-func TestPayee_Strip(t *testing.T) {
-	type args struct {
-		s []string
+func TestMilliunitsFromString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Milliunits
+	}{
+		{s: "19.99", want: 19990},
+		{s: "0.1", want: 100},
+		{s: "0.3", want: 300},
+		{s: "-524.18", want: -524180},
+		{s: "1.005", want: 1005},
+		{s: "4.35", want: 4350},
+		{s: "-3899", want: -3899000},
+		{s: "+12.5", want: 12500},
+		{s: ".5", want: 500},
+		{s: "7.", want: 7000},
+		{s: " 42.42 ", want: 42420},
+		{s: "0.0005", want: 1},
+		{s: "-0.0005", want: -1},
+		{s: "-0.0004", want: 0},
+		{s: "99999999.999", want: 99999999999},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := MilliunitsFromString(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "-", ".", "1,5", "1.2.3", "1e3", "abc", "--1"} {
+		if _, err := MilliunitsFromString(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestMajorString(t *testing.T) {
+	tests := map[Milliunits]string{
+		-99500:   "-99.50",
+		-19990:   "-19.99",
+		-1:       "0.00",
+		-5:       "-0.01",
+		1000:     "1.00",
+		0:        "0.00",
+		12345:    "12.35",
+		-12345:   "-12.35",
+		-1234567: "-1234.57",
+	}
+	for m, want := range tests {
+		if got := m.MajorString(); got != want {
+			t.Errorf("Milliunits(%d).MajorString() = %s, want %s", m, got, want)
+		}
+	}
+}
+
+func TestMilliunitsJSON(t *testing.T) {
+	tests := map[Milliunits]string{
+		-99500: "-99.50",
+		-19990: "-19.99",
+		-12345: "-12.345",
+		-1:     "-0.001",
+		0:      "0.00",
+		1000:   "1.00",
+	}
+	for m, want := range tests {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("json.Marshal(%d) = %s, want %s", m, b, want)
+		}
+
+		var got Milliunits
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != m {
+			t.Errorf("json.Unmarshal(%s) = %d, want %d", b, got, m)
+		}
+	}
+
+	// Amounts were written as integer milliunits before
+	var legacy Milliunits
+	if err := json.Unmarshal([]byte("-12400"), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy != -12400 {
+		t.Errorf("json.Unmarshal(-12400) = %d, want -12400", legacy)
+	}
+
+	// The amount of a transaction uses the same format
+	b, err := json.Marshal(Transaction{Amount: -19990})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"amount":-19.99`) {
+		t.Errorf("got %s, want amount -19.99", b)
 	}
+}
+
+func TestMilliunitsZero(t *testing.T) {
 	tests := []struct {
-		name string
-		p    Payee
-		args args
-		want Payee
+		amount float64
+		want   string
 	}{
-		{name: "single",
-			p:    Payee("Im not here"),
-			args: args{s: []string{"not "}},
-			want: "Im here",
-		},
-		{name: "multiple",
-			p:    Payee("Im not really here"),
-			args: args{s: []string{"not ", "really "}},
-			want: "Im here",
-		},
+		{amount: -0.0004, want: "0"},
+		{amount: 0.0004, want: "0"},
+		{amount: math.Copysign(0, -1), want: "0"},
+		{amount: -0.0005, want: "-1"},
+		{amount: 0.0005, want: "1"},
+		{amount: -0.0009, want: "-1"},
 	}
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.p.Strip(tt.args.s); got != tt.want {
-				t.Errorf("Payee.Strip() = %v, want %v", got, tt.want)
+		t.Run(tt.want, func(t *testing.T) {
+			m := MilliunitsFromAmount(tt.amount)
+			if got := m.String(); got != tt.want {
+				t.Errorf("MilliunitsFromAmount(%v) = %s, want %s", tt.amount, got, tt.want)
 			}
 		})
 	}
+
+	if got := Milliunits(0).Negate().String(); got != "0" {
+		t.Errorf("negated zero = %s, want 0", got)
+	}
+}
+
+func TestSort(t *testing.T) {
+	date := time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC)
+	morning := Transaction{ID: "b", Date: date, Time: date.Add(9 * time.Hour)}
+	evening := Transaction{ID: "a", Date: date, Time: date.Add(21 * time.Hour)}
+	noTime := Transaction{ID: "c", Date: date, Time: date}
+	sameTime := Transaction{ID: "d", Date: date, Time: date}
+	yesterday := Transaction{ID: "e", Date: date.AddDate(0, 0, -1), Time: date.AddDate(0, 0, -1)}
+
+	got := []Transaction{evening, sameTime, morning, noTime, yesterday}
+	Sort(got)
+
+	want := []Transaction{yesterday, noTime, sameTime, morning, evening}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %+v, want %+v", got, want)
+	}
+}
+
+type fakeNotifier struct {
+	err      error
+	messages *[]string
+}
+
+func (n fakeNotifier) Notify(message string) error {
+	*n.messages = append(*n.messages, message)
+	return n.err
+}
+
+func TestDedup(t *testing.T) {
+	a := Account{IBAN: "a"}
+	b := Account{IBAN: "b"}
+	got, removed := Dedup([]Transaction{
+		{Account: a, ID: "1", Payee: "first"},
+		{Account: b, ID: "1"},
+		{Account: a, ID: "1", Payee: "second"},
+		{Account: a, ID: "2"},
+		{Account: a, Payee: "no ID"},
+		{Account: a, Payee: "no ID either"},
+	})
+	want := []Transaction{
+		{Account: a, ID: "1", Payee: "first"},
+		{Account: b, ID: "1"},
+		{Account: a, ID: "2"},
+		{Account: a, Payee: "no ID"},
+		{Account: a, Payee: "no ID either"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if removed != 1 {
+		t.Errorf("got %d removed, want 1", removed)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var failed, ok []string
+	errFailed := errors.New("failed")
+	n := Notifiers{
+		fakeNotifier{err: errFailed, messages: &failed},
+		fakeNotifier{messages: &ok},
+	}
+
+	err := n.Notify("hello")
+	if !errors.Is(err, errFailed) {
+		t.Errorf("got error = %v, want %v", err, errFailed)
+	}
+
+	// The failing notifier must not stop the other one
+	want := []string{"hello"}
+	if !reflect.DeepEqual(failed, want) || !reflect.DeepEqual(ok, want) {
+		t.Errorf("got failed = %v and ok = %v, want both %v", failed, ok, want)
+	}
+
+	if err := (Notifiers{}).Notify("hello"); err != nil {
+		t.Errorf("no notifiers: got error = %v", err)
+	}
 }